@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -15,62 +19,98 @@ type A1CEClient struct {
 	HTTPClient     *http.Client
 	JWTToken       string
 	UniversityCode string
+
+	// CatalogConcurrency bounds parallel subdomain requests in GetCourseCatalog;
+	// SubdomainTimeout bounds each of them.
+	CatalogConcurrency int
+	SubdomainTimeout   time.Duration
+
+	// Retry governs how 5xx answers and timeouts are retried; Breaker, shared
+	// by every client of BaseURL, fails calls fast while A1CE is down.
+	Retry   RetryPolicy
+	Breaker *CircuitBreaker
 }
 
-func NewA1CEClient() *A1CEClient {
+func NewA1CEClient(baseURL string) *A1CEClient {
 	return &A1CEClient{
-		BaseURL:    "https://a1ce.cmkl.ac.th/api",
-		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		BaseURL:            strings.TrimRight(baseURL, "/"),
+		HTTPClient:         &http.Client{Timeout: 10 * time.Second},
+		CatalogConcurrency: 4,
+		SubdomainTimeout:   8 * time.Second,
+		Retry:              RetryPolicy{MaxRetries: 2, BaseDelay: 200 * time.Millisecond, MaxDelay: 2 * time.Second},
+	}
+}
+
+// GetStudentProfile fails only when the identity cannot be read. Failures to
+// load the cards or graduation status are recorded in the profile's
+// Completeness so the caller can decide whether a partial profile will do.
+func (c *A1CEClient) GetStudentProfile(ctx context.Context, studentID string) (*StudentProfile, error) {
+	identity, err := c.getStudentIdentity(ctx, studentID)
+	if err != nil {
+		return nil, err
 	}
+	c.UniversityCode = identity.UniversityCode
+
+	cards, cardsErr := c.getStudentCompetencies(ctx, studentID)
+	if cardsErr != nil {
+		log.Printf("(!) WARNING: Could not load competencies for %s: %v", studentID, cardsErr)
+	}
+	gradStatus, gradErr := c.GetGraduationStatus(ctx, studentID)
+	if gradErr != nil {
+		log.Printf("(!) WARNING: Could not load graduation status for %s: %v", studentID, gradErr)
+	}
+
+	profile := buildStudentProfile(studentID, identity, cards, gradStatus)
+	profile.Completeness.Record(ProfilePartHistory, cardsErr)
+	profile.Completeness.Record(ProfilePartGraduation, gradErr)
+	return profile, nil
 }
 
-func (c *A1CEClient) GetStudentProfile(studentID string) (*StudentProfile, error) {
+// buildStudentProfile assembles a profile from A1CE-shaped records so every
+// data source derives completion and credits the same way. A nil gradStatus
+// leaves the graduation fields empty. The identity is recorded as loaded;
+// callers record the other parts.
+func buildStudentProfile(studentID string, identity *A1CEStudentIdentity, cards []A1CECompetencyCard, gradStatus *A1CEGraduationStatus) *StudentProfile {
 	profile := &StudentProfile{
 		StudentID:           studentID,
+		UniversityCode:      identity.UniversityCode,
+		CurriculumVersion:   identity.CurriculumVersion,
 		Competencies:        make(map[string]float64),
 		CourseSemesters:     make(map[string]string),
 		CompletedCourses:    []string{},
 		DistributionCredits: make(map[string]A1CECredit),
+		InProgressCourses:   make(map[string]bool),
 	}
+	profile.Completeness.Record(ProfilePartIdentity, nil)
 
-	identity, err := c.getStudentIdentity(studentID)
-	if err != nil {
-		return nil, err
-	}
-
-	profile.UniversityCode = identity.UniversityCode
-	c.UniversityCode = identity.UniversityCode
-	profile.CurriculumVersion = identity.CurriculumVersion
-
-	cards, err := c.getStudentCompetencies(studentID)
-	if err == nil {
-		for _, card := range cards {
-			profile.Competencies[card.CourseCode] = card.Grade
-			if card.Semester != "" {
-				profile.CourseSemesters[card.CourseCode] = card.Semester
-			}
-			if card.Status == "Recorded" || card.Status == "Completed" || card.Grade >= 1.0 {
-				// Add basic code
-				profile.CompletedCourses = append(profile.CompletedCourses, card.CourseCode)
-				// Add TemplateID if available (Crucial for Identity Match)
-				if card.TemplateID != "" {
-					profile.CompletedCourses = append(profile.CompletedCourses, card.TemplateID)
-				}
+	for _, card := range cards {
+		profile.Competencies[card.CourseCode] = card.Grade
+		if card.Semester != "" {
+			profile.CourseSemesters[card.CourseCode] = card.Semester
+		}
+		if card.Status == "In Progress" {
+			profile.InProgressCourses[normalizeCode(card.CourseCode)] = true
+		}
+		if card.Status == "Recorded" || card.Status == "Completed" || card.Grade >= passingGrade {
+			// Add basic code
+			profile.CompletedCourses = append(profile.CompletedCourses, card.CourseCode)
+			// Add TemplateID if available (Crucial for Identity Match)
+			if card.TemplateID != "" {
+				profile.CompletedCourses = append(profile.CompletedCourses, card.TemplateID)
 			}
 		}
 	}
 
-	gradStatus, err := c.getGraduationStatus(studentID)
-	if err == nil {
+	if gradStatus != nil {
 		profile.RequiredCompetencies = gradStatus.RequiredCompetencies
 		profile.DistributionCredits = gradStatus.DistributionCredits
 		profile.TotalCredits = gradStatus.TotalCredits
 	}
 
-	return profile, nil
+	return profile
 }
 
-func (c *A1CEClient) GetSemesterCompetencies(studentID, semester string) ([]A1CECompetencyCard, error) {
+func (c *A1CEClient) GetSemesterCompetencies(ctx context.Context, studentID, semester string) ([]A1CECompetencyCard, error) {
 	safeSemester := url.QueryEscape(semester)
 	if strings.Contains(semester, " ") && !strings.Contains(safeSemester, "%20") {
 		safeSemester = strings.ReplaceAll(semester, " ", "%20")
@@ -83,14 +123,18 @@ func (c *A1CEClient) GetSemesterCompetencies(studentID, semester string) ([]A1CE
 			Cards []A1CECompetencyCard `json:"cards"`
 		} `json:"card_info"`
 	}
-	if err := c.makeRequest("GET", url, &input); err != nil {
+	if err := c.makeRequest(ctx, "GET", url, &input); err != nil {
 		return nil, err
 	}
 	return input.Info.Cards, nil
 }
 
-func (c *A1CEClient) GetCourseCatalog(semester string, curriculumVersion int) (*CourseCatalogResponse, error) {
-	subdomains, err := c.getSubdomains(curriculumVersion)
+// GetCourseCatalog fetches every subdomain's courses with up to
+// CatalogConcurrency requests in flight, each bounded by SubdomainTimeout.
+// Subdomains that fail are listed in FailedSubdomains and the catalog is
+// marked "partial"; it is an error only when every subdomain fails.
+func (c *A1CEClient) GetCourseCatalog(ctx context.Context, semester string, curriculumVersion int) (*CourseCatalogResponse, error) {
+	subdomains, err := c.getSubdomains(ctx, curriculumVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -99,16 +143,51 @@ func (c *A1CEClient) GetCourseCatalog(semester string, curriculumVersion int) (*
 		Status: "success", Semester: semester, CurriculumVersion: curriculumVersion, Courses: []Course{},
 	}
 
-	seenIDs := make(map[string]bool)
+	ids := make([]string, 0, len(subdomains))
+	for id := range subdomains {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
 
-	for subdomainID, isCore := range subdomains {
-		courses, err := c.getCoursesForSubdomain(subdomainID, semester, curriculumVersion, isCore)
-		if err != nil {
-			fmt.Printf("Warning: Failed to fetch subdomain %s: %v\n", subdomainID, err)
+	type subdomainResult struct {
+		courses []Course
+		err     error
+	}
+	results := make([]subdomainResult, len(ids))
+
+	workers := c.CatalogConcurrency
+	if workers <= 0 {
+		workers = 1
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(ids); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				subCtx, cancel := c.subdomainContext(ctx)
+				courses, err := c.getCoursesForSubdomain(subCtx, ids[i], semester, curriculumVersion, subdomains[ids[i]])
+				cancel()
+				results[i] = subdomainResult{courses: courses, err: err}
+			}
+		}()
+	}
+	for i := range ids {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	seenIDs := make(map[string]bool)
+	for i, res := range results {
+		if res.err != nil {
+			log.Printf("(!) WARNING: Failed to fetch subdomain %s: %v", ids[i], res.err)
+			catalog.FailedSubdomains = append(catalog.FailedSubdomains, SubdomainFailure{SubdomainID: ids[i], Error: res.err.Error()})
 			continue
 		}
 
-		for _, course := range courses {
+		for _, course := range res.courses {
 			if !seenIDs[course.CourseID] {
 				seenIDs[course.CourseID] = true
 				catalog.Courses = append(catalog.Courses, course)
@@ -116,37 +195,51 @@ func (c *A1CEClient) GetCourseCatalog(semester string, curriculumVersion int) (*
 		}
 	}
 
+	if len(ids) > 0 && len(catalog.FailedSubdomains) == len(ids) {
+		return nil, fmt.Errorf("all %d subdomains failed, first error: %w", len(ids), results[0].err)
+	}
+	if len(catalog.FailedSubdomains) > 0 {
+		catalog.Status = "partial"
+	}
+
 	catalog.TotalCourses = len(catalog.Courses)
 	return catalog, nil
 }
 
+func (c *A1CEClient) subdomainContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.SubdomainTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.SubdomainTimeout)
+}
+
 // --- API CALLS ---
 
-func (c *A1CEClient) getStudentIdentity(studentID string) (*A1CEStudentIdentity, error) {
+func (c *A1CEClient) getStudentIdentity(ctx context.Context, studentID string) (*A1CEStudentIdentity, error) {
 	url := fmt.Sprintf("%s/student/identity?student_id=%s", c.BaseURL, studentID)
 	var input struct {
 		Student A1CEStudentIdentity `json:"student"`
 	}
-	if err := c.makeRequest("GET", url, &input); err != nil {
+	if err := c.makeRequest(ctx, "GET", url, &input); err != nil {
 		return nil, err
 	}
 	return &input.Student, nil
 }
 
-func (c *A1CEClient) getStudentCompetencies(studentID string) ([]A1CECompetencyCard, error) {
+func (c *A1CEClient) getStudentCompetencies(ctx context.Context, studentID string) ([]A1CECompetencyCard, error) {
 	url := fmt.Sprintf("%s/student/cards?student_id=%s", c.BaseURL, studentID)
 	var input struct {
 		Info struct {
 			Cards []A1CECompetencyCard `json:"cards"`
 		} `json:"card_info"`
 	}
-	if err := c.makeRequest("GET", url, &input); err != nil {
+	if err := c.makeRequest(ctx, "GET", url, &input); err != nil {
 		return nil, err
 	}
 	return input.Info.Cards, nil
 }
 
-func (c *A1CEClient) getGraduationStatus(studentID string) (*A1CEGraduationStatus, error) {
+func (c *A1CEClient) GetGraduationStatus(ctx context.Context, studentID string) (*A1CEGraduationStatus, error) {
 	url := fmt.Sprintf("%s/student/graduation/status?student_id=%s", c.BaseURL, studentID)
 	var input struct {
 		Status struct {
@@ -155,7 +248,7 @@ func (c *A1CEClient) getGraduationStatus(studentID string) (*A1CEGraduationStatu
 		} `json:"graduationstatus"`
 	}
 
-	if err := c.makeRequest("GET", url, &input); err != nil {
+	if err := c.makeRequest(ctx, "GET", url, &input); err != nil {
 		return nil, err
 	}
 
@@ -182,7 +275,7 @@ func (c *A1CEClient) getGraduationStatus(studentID string) (*A1CEGraduationStatu
 	return &status, nil
 }
 
-func (c *A1CEClient) getSubdomains(curriculumVersion int) (map[string]bool, error) {
+func (c *A1CEClient) getSubdomains(ctx context.Context, curriculumVersion int) (map[string]bool, error) {
 	url := fmt.Sprintf("%s/subdomain?curriculum_version=%d", c.BaseURL, curriculumVersion)
 	if c.UniversityCode != "" {
 		url += "&university_code=" + c.UniversityCode
@@ -196,7 +289,7 @@ func (c *A1CEClient) getSubdomains(curriculumVersion int) (map[string]bool, erro
 			} `json:"subdomains"`
 		} `json:"pillars"`
 	}
-	if err := c.makeRequest("GET", url, &response); err != nil {
+	if err := c.makeRequest(ctx, "GET", url, &response); err != nil {
 		return nil, err
 	}
 
@@ -211,7 +304,7 @@ func (c *A1CEClient) getSubdomains(curriculumVersion int) (map[string]bool, erro
 	return subdomains, nil
 }
 
-func (c *A1CEClient) getCoursesForSubdomain(subdomainID, semester string, curriculumVersion int, isPillarCore bool) ([]Course, error) {
+func (c *A1CEClient) getCoursesForSubdomain(ctx context.Context, subdomainID, semester string, curriculumVersion int, isPillarCore bool) ([]Course, error) {
 	safeSemester := url.QueryEscape(semester)
 	if strings.Contains(semester, " ") && !strings.Contains(safeSemester, "%20") {
 		safeSemester = strings.ReplaceAll(semester, " ", "%20")
@@ -238,7 +331,7 @@ func (c *A1CEClient) getCoursesForSubdomain(subdomainID, semester string, curric
 	var response struct {
 		Competencies []APICourse `json:"competencies"`
 	}
-	if err := c.makeRequest("GET", url, &response); err != nil {
+	if err := c.makeRequest(ctx, "GET", url, &response); err != nil {
 		return nil, err
 	}
 
@@ -270,10 +363,42 @@ func (c *A1CEClient) getCoursesForSubdomain(subdomainID, semester string, curric
 	return courses, nil
 }
 
-func (c *A1CEClient) makeRequest(method, url string, result interface{}) error {
-	req, err := http.NewRequest(method, url, nil)
+// makeRequest performs one A1CE call, retrying 5xx answers and timeouts with
+// backoff. Failures are *UpstreamError values classified as ErrUnauthorized,
+// ErrNotFound or ErrUpstreamUnavailable where the status allows.
+func (c *A1CEClient) makeRequest(ctx context.Context, method, url string, result interface{}) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		if c.Breaker != nil {
+			if err := c.Breaker.Allow(); err != nil {
+				return &UpstreamError{URL: url, Kind: ErrUpstreamUnavailable, Err: err}
+			}
+		}
+
+		var body []byte
+		body, err = c.doRequest(ctx, method, url)
+		if c.Breaker != nil {
+			c.Breaker.Record(err)
+		}
+		if err == nil {
+			return json.Unmarshal(body, result)
+		}
+		if !retryable(err) || attempt >= c.Retry.MaxRetries || ctx.Err() != nil {
+			return err
+		}
+
+		delay := c.Retry.backoff(attempt)
+		debugf("A1CE %s %s failed (%v), retry %d in %s", method, url, err, attempt+1, delay)
+		if sleepErr := sleepContext(ctx, delay); sleepErr != nil {
+			return err
+		}
+	}
+}
+
+func (c *A1CEClient) doRequest(ctx context.Context, method, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if c.JWTToken != "" {
 		req.AddCookie(&http.Cookie{Name: "jwt", Value: c.JWTToken})
@@ -281,14 +406,21 @@ func (c *A1CEClient) makeRequest(method, url string, result interface{}) error {
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return err
+		// Network failures and timeouts are worth retrying; a cancelled
+		// caller is not.
+		if ctx.Err() == context.Canceled {
+			return nil, err
+		}
+		return nil, &UpstreamError{URL: url, Kind: ErrUpstreamUnavailable, Err: err}
 	}
 	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &UpstreamError{URL: url, StatusCode: resp.StatusCode, Kind: ErrUpstreamUnavailable, Err: fmt.Errorf("read body: %w", err)}
 	}
-
-	return json.Unmarshal(body, result)
+	if resp.StatusCode != http.StatusOK {
+		return nil, &UpstreamError{URL: url, StatusCode: resp.StatusCode, Body: string(body), Kind: classifyStatus(resp.StatusCode)}
+	}
+	return body, nil
 }
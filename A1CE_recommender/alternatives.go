@@ -0,0 +1,222 @@
+package main
+
+import (
+	"log"
+	"sort"
+)
+
+// maxSetOverlap is the largest share of a plan's courses that may also appear
+// in an earlier plan, measured against the smaller of the two.
+const maxSetOverlap = 0.5
+
+// planStrategy re-ranks the scored candidates to give a plan its emphasis.
+// profile names the weight profile whose fit weights re-rank the candidates;
+// empty keeps FitScore. requirementsFirst ranks graduation requirements
+// above every other course. creditShare scales the credit load.
+type planStrategy struct {
+	label             string
+	emphasis          string
+	profile           string
+	requirementsFirst bool
+	creditShare       float64
+}
+
+// planStrategies are tried in order; the first is the main set.
+var planStrategies = []planStrategy{
+	{
+		label:       "best-fit",
+		emphasis:    "Best overall fit of competencies, interests and program progress",
+		creditShare: 1,
+	},
+	{
+		label:             "requirement-heavy",
+		emphasis:          "Clears outstanding graduation requirements first",
+		profile:           "graduation-first",
+		requirementsFirst: true,
+		creditShare:       1,
+	},
+	{
+		label:       "interest-heavy",
+		emphasis:    "Follows the subdomains the student has done best in",
+		profile:     "explore",
+		creditShare: 1,
+	},
+	{
+		label:       "light-load",
+		emphasis:    "A lighter semester at about two thirds of the credit load",
+		creditShare: 2.0 / 3,
+	},
+}
+
+// optimizeSet places the pinned courses first and fills the remaining load
+// with the named optimizer; complete is false when the exact search stopped
+// at its budget.
+func optimizeSet(optimizer string, pinned, scored []RecommendedCourse, profile *StudentProfile, requirements *CurriculumRequirements, maxCreditLoad float64) ([]RecommendedCourse, bool) {
+	selected := append([]RecommendedCourse(nil), pinned...)
+	load := maxCreditLoad - calculateTotalCredits(pinned)
+
+	var rest []RecommendedCourse
+	complete := true
+	if optimizer == OptimizerExact {
+		rest, complete = OptimizeCourseSetExact(scored, profile, requirements, load)
+	} else {
+		rest = OptimizeCourseSet(scored, profile, requirements, load)
+	}
+	return append(selected, rest...), complete
+}
+
+// buildAlternativePlans returns up to count plans after main, each from the
+// next strategy. Every plan starts with the pinned courses, which do not count
+// towards overlap. A plan that cannot be kept within maxSetOverlap of the
+// plans before it, even after dropping the shared courses from its
+// candidates, is skipped. Only the first attempt of each strategy uses
+// optimizer; the retries use the greedy one, so a request runs the exact
+// search at most once per plan.
+func buildAlternativePlans(
+	main []RecommendedCourse,
+	count int,
+	optimizer string,
+	pinned []RecommendedCourse,
+	scored []RecommendedCourse,
+	profile *StudentProfile,
+	requirements *CurriculumRequirements,
+	maxCreditLoad float64,
+	weights WeightProfile,
+	profiles WeightProfiles,
+) []AlternativePlan {
+	graduationReqMap := graduationRequirementSet(requirements)
+	previous := [][]RecommendedCourse{main[len(pinned):]}
+	plans := []AlternativePlan{}
+
+	for _, strategy := range planStrategies[1:] {
+		if len(plans) >= count {
+			break
+		}
+
+		candidates := rankForStrategy(strategy, scored, graduationReqMap, weights.Fit, profiles)
+		planPinned := append([]RecommendedCourse(nil), pinned...)
+		load := maxCreditLoad * strategy.creditShare
+		for calculateTotalCredits(planPinned) > load {
+			planPinned = planPinned[:len(planPinned)-1]
+		}
+		load -= calculateTotalCredits(planPinned)
+
+		var set []RecommendedCourse
+		attemptOptimizer := optimizer
+		for len(candidates) > 0 {
+			set, _ = optimizeSet(attemptOptimizer, nil, candidates, profile, requirements, load)
+			if overlapWithin(set, previous) {
+				break
+			}
+			// Drop the shared course this strategy values least and retry.
+			shared := sharedCourses(set, previous)
+			candidates = withoutCourse(candidates, shared[len(shared)-1])
+			attemptOptimizer = OptimizerGreedy
+			set = nil
+		}
+		if len(set) == 0 {
+			continue
+		}
+
+		set = restoreScores(set, scored)
+		previous = append(previous, set)
+		set = append(append([]RecommendedCourse(nil), planPinned...), set...)
+		plans = append(plans, AlternativePlan{
+			Label:                strategy.label,
+			Emphasis:             strategy.emphasis,
+			RecommendedSet:       set,
+			TotalCredits:         calculateTotalCredits(set),
+			Metrics:              *EvaluateRecommendationSet(set, profile, requirements, weights.Goodness),
+			DistributionCoverage: calculateDistributionCoverage(set),
+		})
+	}
+	return plans
+}
+
+// rankForStrategy returns the candidates with FitScore replaced by the
+// strategy's score, best first, which is the order the greedy optimizer
+// expects. The score weighs the three course scores by the fit weights of
+// the strategy's profile, plus 1 for a graduation requirement when the
+// strategy puts requirements first. The preference adjustments on FitScore,
+// its difference from the fit under base, carry over to the strategy score.
+func rankForStrategy(strategy planStrategy, scored []RecommendedCourse, graduationReqMap map[string]bool, base FitWeights, profiles WeightProfiles) []RecommendedCourse {
+	ranked := append([]RecommendedCourse(nil), scored...)
+	if strategy.profile == "" {
+		return ranked
+	}
+	p, err := profiles.Get(strategy.profile)
+	if err != nil {
+		log.Printf("(!) WARNING: Plan %s keeps the fit score: %v", strategy.label, err)
+		return ranked
+	}
+	for i := range ranked {
+		rec := ranked[i]
+		preferences := rec.FitScore - combineFit(base, rec.CompetencyMatchScore, rec.InterestAlignmentScore, rec.ProgramProgressScore, rec.UnlockScore)
+		score := combineFit(p.Fit, rec.CompetencyMatchScore, rec.InterestAlignmentScore, rec.ProgramProgressScore, rec.UnlockScore) + preferences
+		if strategy.requirementsFirst && isGraduationRequirement(rec.Course, graduationReqMap) {
+			score += 1
+		}
+		ranked[i].FitScore = score
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].FitScore > ranked[j].FitScore
+	})
+	return ranked
+}
+
+// restoreScores puts the real fit scores back on a plan chosen with
+// strategy scores.
+func restoreScores(set []RecommendedCourse, scored []RecommendedCourse) []RecommendedCourse {
+	fit := make(map[string]float64)
+	for _, rec := range scored {
+		fit[rec.Course.CourseID] = rec.FitScore
+	}
+	for i := range set {
+		set[i].FitScore = fit[set[i].Course.CourseID]
+	}
+	return set
+}
+
+// sharedCourses lists the courses of set, in set order, that an earlier plan
+// already contains.
+func sharedCourses(set []RecommendedCourse, previous [][]RecommendedCourse) []string {
+	var shared []string
+	for _, rec := range set {
+		for _, other := range previous {
+			if containsRecommendedCourse(other, rec) {
+				shared = append(shared, rec.Course.CourseID)
+				break
+			}
+		}
+	}
+	return shared
+}
+
+func overlapWithin(set []RecommendedCourse, previous [][]RecommendedCourse) bool {
+	for _, other := range previous {
+		smaller := min(len(set), len(other))
+		if smaller == 0 {
+			continue
+		}
+		common := 0
+		for _, rec := range set {
+			if containsRecommendedCourse(other, rec) {
+				common++
+			}
+		}
+		if float64(common)/float64(smaller) > maxSetOverlap {
+			return false
+		}
+	}
+	return true
+}
+
+func withoutCourse(candidates []RecommendedCourse, courseID string) []RecommendedCourse {
+	out := candidates[:0:0]
+	for _, rec := range candidates {
+		if rec.Course.CourseID != courseID {
+			out = append(out, rec)
+		}
+	}
+	return out
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestAlternativePlansKeepPreferences(t *testing.T) {
+	base := builtinWeightProfiles[DefaultWeightProfile].Fit
+	course := func(code, subdomain string, interest float64) RecommendedCourse {
+		return RecommendedCourse{
+			Course:                 Course{CourseID: code, CourseCode: code, CreditHours: 3, SubdomainName: subdomain},
+			CompetencyMatchScore:   0.5,
+			InterestAlignmentScore: interest,
+			ProgramProgressScore:   0.5,
+			FitScore:               combineFit(base, 0.5, interest, 0.5, 0),
+		}
+	}
+	// TST-102 trails TST-101 slightly on interest, well within the boost of
+	// its preferred subdomain.
+	scored := []RecommendedCourse{course("TST-101", "Testing", 0.52), course("TST-102", "Robotics", 0.5)}
+	scored = applyPreferences(scored, &RecommendationFilters{PreferredSubdomains: []string{"Robotics"}}, nil, defaultPreferences)
+
+	for _, strategy := range planStrategies[1:] {
+		if strategy.profile == "" {
+			continue
+		}
+		ranked := rankForStrategy(strategy, scored, nil, base, builtinWeightProfiles)
+		if ranked[0].Course.CourseCode != "TST-102" {
+			t.Errorf("%s: ranked %s first, want the preferred-subdomain TST-102", strategy.label, ranked[0].Course.CourseCode)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Roles that may read any student's data.
+var privilegedRoles = []string{"advisor", "admin"}
+
+var (
+	errMissingToken = errors.New("missing bearer token")
+	errInvalidToken = errors.New("invalid token")
+)
+
+// AuthClaims is the caller identity extracted from a validated JWT.
+type AuthClaims struct {
+	Subject   string
+	StudentID string
+	Roles     []string
+	Issuer    string
+	ExpiresAt time.Time
+}
+
+// HasRole reports whether the token carries one of roles.
+func (c *AuthClaims) HasRole(roles ...string) bool {
+	for _, have := range c.Roles {
+		for _, want := range roles {
+			if strings.EqualFold(have, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CanAccessStudent allows students their own record and advisors/admins any.
+func (c *AuthClaims) CanAccessStudent(studentID string) bool {
+	if c.HasRole(privilegedRoles...) {
+		return true
+	}
+	return studentID != "" && (studentID == c.StudentID || studentID == c.Subject)
+}
+
+// JWTVerifier validates HS256 tokens against a shared secret and RS256 tokens
+// against the keys of a JWKS file.
+type JWTVerifier struct {
+	secret  []byte
+	rsaKeys map[string]*rsa.PublicKey
+	issuer  string
+	now     func() time.Time
+}
+
+func NewJWTVerifier(cfg *Config) (*JWTVerifier, error) {
+	v := &JWTVerifier{
+		rsaKeys: make(map[string]*rsa.PublicKey),
+		issuer:  cfg.JWTIssuer,
+		now:     time.Now,
+	}
+	if cfg.JWTSecret != "" {
+		v.secret = []byte(cfg.JWTSecret)
+	}
+	if cfg.JWKSFile != "" {
+		keys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("load JWKS %s: %w", cfg.JWKSFile, err)
+		}
+		v.rsaKeys = keys
+	}
+	if v.secret == nil && len(v.rsaKeys) == 0 {
+		return nil, errors.New("authentication is enabled but neither jwt_secret nor jwks_file is set")
+	}
+	return v, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+}
+
+type jwtPayload struct {
+	Subject   string          `json:"sub"`
+	StudentID string          `json:"student_id"`
+	Issuer    string          `json:"iss"`
+	ExpiresAt *int64          `json:"exp"`
+	NotBefore *int64          `json:"nbf"`
+	Role      string          `json:"role"`
+	Roles     json.RawMessage `json:"roles"`
+}
+
+// Verify checks the signature, expiry, not-before and issuer of token.
+func (v *JWTVerifier) Verify(token string) (*AuthClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", errInvalidToken)
+	}
+
+	var header jwtHeader
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", errInvalidToken, err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature encoding", errInvalidToken)
+	}
+	if err := v.verifySignature(header, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var payload jwtPayload
+	if err := decodeJWTSegment(parts[1], &payload); err != nil {
+		return nil, fmt.Errorf("%w: payload: %v", errInvalidToken, err)
+	}
+
+	now := v.now()
+	if payload.ExpiresAt == nil {
+		return nil, fmt.Errorf("%w: missing exp", errInvalidToken)
+	}
+	expiresAt := time.Unix(*payload.ExpiresAt, 0)
+	if !now.Before(expiresAt) {
+		return nil, fmt.Errorf("%w: expired", errInvalidToken)
+	}
+	if payload.NotBefore != nil && now.Before(time.Unix(*payload.NotBefore, 0)) {
+		return nil, fmt.Errorf("%w: not yet valid", errInvalidToken)
+	}
+	if v.issuer != "" && payload.Issuer != v.issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", errInvalidToken, payload.Issuer)
+	}
+	if payload.Subject == "" && payload.StudentID == "" {
+		return nil, fmt.Errorf("%w: missing subject", errInvalidToken)
+	}
+
+	claims := &AuthClaims{
+		Subject:   payload.Subject,
+		StudentID: payload.StudentID,
+		Issuer:    payload.Issuer,
+		ExpiresAt: expiresAt,
+	}
+	if payload.Role != "" {
+		claims.Roles = append(claims.Roles, payload.Role)
+	}
+	if len(payload.Roles) > 0 {
+		var roles []string
+		if err := json.Unmarshal(payload.Roles, &roles); err != nil {
+			var single string
+			if err := json.Unmarshal(payload.Roles, &single); err != nil {
+				return nil, fmt.Errorf("%w: roles claim", errInvalidToken)
+			}
+			roles = []string{single}
+		}
+		claims.Roles = append(claims.Roles, roles...)
+	}
+	return claims, nil
+}
+
+func (v *JWTVerifier) verifySignature(header jwtHeader, signingInput string, signature []byte) error {
+	switch header.Alg {
+	case "HS256":
+		if v.secret == nil {
+			return fmt.Errorf("%w: HS256 not accepted", errInvalidToken)
+		}
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return fmt.Errorf("%w: bad signature", errInvalidToken)
+		}
+		return nil
+	case "RS256":
+		key, ok := v.rsaKeys[header.Kid]
+		if !ok && header.Kid == "" && len(v.rsaKeys) == 1 {
+			for _, only := range v.rsaKeys {
+				key, ok = only, true
+			}
+		}
+		if !ok {
+			return fmt.Errorf("%w: unknown key id %q", errInvalidToken, header.Kid)
+		}
+		digest := sha256.Sum256([]byte(signingInput))
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return fmt.Errorf("%w: bad signature", errInvalidToken)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unsupported alg %q", errInvalidToken, header.Alg)
+	}
+}
+
+func decodeJWTSegment(segment string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// loadJWKS reads the RSA signing keys of a JSON Web Key Set file, keyed by kid.
+func loadJWKS(filename string) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := readJSONFile(filename, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: modulus: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: exponent: %w", k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no RSA signing keys")
+	}
+	return keys, nil
+}
+
+// SignHS256 mints an HS256 token for claims; used by the mint-token command
+// to exercise the API locally.
+func SignHS256(secret string, claims map[string]interface{}) (string, error) {
+	header, err := json.Marshal(jwtHeader{Alg: "HS256"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+type authContextKey struct{}
+
+// claimsFromContext returns the claims authMiddleware attached, or nil when
+// authentication is disabled.
+func claimsFromContext(ctx context.Context) *AuthClaims {
+	claims, _ := ctx.Value(authContextKey{}).(*AuthClaims)
+	return claims
+}
+
+// authorizeStudent enforces student scope and writes a 403 when the caller
+// may not read studentID.
+func authorizeStudent(w http.ResponseWriter, r *http.Request, studentID string) bool {
+	claims := claimsFromContext(r.Context())
+	if claims == nil || claims.CanAccessStudent(studentID) {
+		return true
+	}
+	sendError(w, http.StatusForbidden, "FORBIDDEN", "Not allowed to access this student's data", "")
+	return false
+}
+
+// requireRole writes a 403 unless the caller holds one of roles.
+func requireRole(w http.ResponseWriter, r *http.Request, roles ...string) bool {
+	claims := claimsFromContext(r.Context())
+	if claims == nil || claims.HasRole(roles...) {
+		return true
+	}
+	sendError(w, http.StatusForbidden, "FORBIDDEN", "Insufficient role", "")
+	return false
+}
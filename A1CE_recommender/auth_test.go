@@ -0,0 +1,233 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	testSecret = "test-secret"
+	testIssuer = "a1ce-test"
+	testKid    = "test-key"
+)
+
+// testVerifier accepts HS256 tokens signed with testSecret and RS256 tokens
+// signed with the returned key, both issued by testIssuer.
+func testVerifier(t *testing.T) (*JWTVerifier, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": testKid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	}
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	raw, err := json.Marshal(jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(jwksFile, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	verifier, err := NewJWTVerifier(&Config{JWTSecret: testSecret, JWKSFile: jwksFile, JWTIssuer: testIssuer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return verifier, key
+}
+
+func testClaims(sub, role string, ttl time.Duration) map[string]interface{} {
+	return map[string]interface{}{
+		"sub":        sub,
+		"student_id": sub,
+		"role":       role,
+		"iss":        testIssuer,
+		"exp":        time.Now().Add(ttl).Unix(),
+	}
+}
+
+func mintHS256(t *testing.T, secret string, claims map[string]interface{}) string {
+	t.Helper()
+	token, err := SignHS256(secret, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// mintToken signs claims under header with key, or leaves the signature
+// empty when key is nil.
+func mintToken(t *testing.T, header map[string]string, claims map[string]interface{}, key *rsa.PrivateKey) string {
+	t.Helper()
+	h, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(p)
+	if key == nil {
+		return signingInput + "."
+	}
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// testServer mounts a student-scoped endpoint and the health check behind
+// authMiddleware.
+func testServer(verifier *JWTVerifier) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/student-data", func(w http.ResponseWriter, r *http.Request) {
+		if !authorizeStudent(w, r, r.URL.Query().Get("student_id")) {
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/api/v1/health", handleHealth)
+	return authMiddleware(verifier, mux)
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	verifier, key := testVerifier(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := testServer(verifier)
+
+	wrongIssuer := testClaims("S001", "student", time.Hour)
+	wrongIssuer["iss"] = "someone-else"
+	tampered := mintHS256(t, testSecret, testClaims("S001", "student", time.Hour))
+	flipped := byte('A')
+	if tampered[len(tampered)-10] == 'A' {
+		flipped = 'B'
+	}
+	tampered = tampered[:len(tampered)-10] + string(flipped) + tampered[len(tampered)-9:]
+
+	tests := []struct {
+		name    string
+		path    string
+		token   string
+		want    int
+		noToken bool
+	}{
+		{"valid HS256", "/api/v1/student-data?student_id=S001", mintHS256(t, testSecret, testClaims("S001", "student", time.Hour)), http.StatusOK, false},
+		{"valid RS256", "/api/v1/student-data?student_id=S001", mintToken(t, map[string]string{"alg": "RS256", "kid": testKid}, testClaims("S001", "student", time.Hour), key), http.StatusOK, false},
+		{"missing token", "/api/v1/student-data?student_id=S001", "", http.StatusUnauthorized, true},
+		{"expired", "/api/v1/student-data?student_id=S001", mintHS256(t, testSecret, testClaims("S001", "student", -time.Minute)), http.StatusUnauthorized, false},
+		{"HS256 wrong secret", "/api/v1/student-data?student_id=S001", mintHS256(t, "other-secret", testClaims("S001", "student", time.Hour)), http.StatusUnauthorized, false},
+		{"HS256 tampered signature", "/api/v1/student-data?student_id=S001", tampered, http.StatusUnauthorized, false},
+		{"RS256 wrong key", "/api/v1/student-data?student_id=S001", mintToken(t, map[string]string{"alg": "RS256", "kid": testKid}, testClaims("S001", "student", time.Hour), otherKey), http.StatusUnauthorized, false},
+		{"RS256 unknown kid", "/api/v1/student-data?student_id=S001", mintToken(t, map[string]string{"alg": "RS256", "kid": "nope"}, testClaims("S001", "student", time.Hour), key), http.StatusUnauthorized, false},
+		{"wrong issuer", "/api/v1/student-data?student_id=S001", mintHS256(t, testSecret, wrongIssuer), http.StatusUnauthorized, false},
+		{"alg none", "/api/v1/student-data?student_id=S001", mintToken(t, map[string]string{"alg": "none"}, testClaims("S001", "student", time.Hour), nil), http.StatusUnauthorized, false},
+		{"missing alg", "/api/v1/student-data?student_id=S001", mintToken(t, map[string]string{}, testClaims("S001", "student", time.Hour), nil), http.StatusUnauthorized, false},
+		{"RS256 signature under HS256", "/api/v1/student-data?student_id=S001", mintToken(t, map[string]string{"alg": "HS256"}, testClaims("S001", "student", time.Hour), key), http.StatusUnauthorized, false},
+		{"other student", "/api/v1/student-data?student_id=S002", mintHS256(t, testSecret, testClaims("S001", "student", time.Hour)), http.StatusForbidden, false},
+		{"advisor override", "/api/v1/student-data?student_id=S002", mintHS256(t, testSecret, testClaims("T100", "advisor", time.Hour)), http.StatusOK, false},
+		{"admin override", "/api/v1/student-data?student_id=S002", mintToken(t, map[string]string{"alg": "RS256", "kid": testKid}, testClaims("A1", "admin", time.Hour), key), http.StatusOK, false},
+		{"health without token", "/api/v1/health", "", http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if !tt.noToken {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthDisabled(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/student-data?student_id=S002", nil)
+	rec := httptest.NewRecorder()
+	testServer(nil).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d with authentication disabled", rec.Code, http.StatusOK)
+	}
+}
+
+func TestVerifyClaims(t *testing.T) {
+	verifier, _ := testVerifier(t)
+	claims := testClaims("S001", "", time.Hour)
+	delete(claims, "role")
+	claims["roles"] = []string{"student", "Advisor"}
+
+	got, err := verifier.Verify(mintHS256(t, testSecret, claims))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Subject != "S001" || got.StudentID != "S001" || got.Issuer != testIssuer {
+		t.Errorf("claims = %+v", got)
+	}
+	if !got.HasRole("advisor") || !got.CanAccessStudent("S999") {
+		t.Errorf("roles %v should grant advisor access", got.Roles)
+	}
+}
+
+func TestRecommendationByIDHidesOtherStudents(t *testing.T) {
+	store, err := NewHistoryStore(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	id, err := store.Save(&RecommendationRequest{StudentID: "S001"}, &RecommendationSet{StudentID: "S001", Status: "success"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := historyStore
+	historyStore = store
+	defer func() { historyStore = saved }()
+
+	verifier, _ := testVerifier(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/recommendations/{id}", handleRecommendationByID)
+	server := authMiddleware(verifier, mux)
+	token := mintHS256(t, testSecret, testClaims("S002", "student", time.Hour))
+
+	var bodies []string
+	for _, path := range []string{fmt.Sprintf("/api/v1/recommendations/%d", id), fmt.Sprintf("/api/v1/recommendations/%d", id+1)} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want %d", path, rec.Code, http.StatusNotFound)
+		}
+		bodies = append(bodies, rec.Body.String())
+	}
+	if bodies[0] != bodies[1] {
+		t.Errorf("another student's set answers %q, a missing one %q", bodies[0], bodies[1])
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CatalogCacheKey identifies one cached catalog.
+type CatalogCacheKey struct {
+	Semester          string `json:"semester"`
+	CurriculumVersion int    `json:"curriculum_version"`
+	UniversityCode    string `json:"university_code"`
+}
+
+func (k CatalogCacheKey) String() string {
+	return fmt.Sprintf("%s|%d|%s", k.Semester, k.CurriculumVersion, k.UniversityCode)
+}
+
+type catalogCacheEntry struct {
+	Key       CatalogCacheKey        `json:"key"`
+	Catalog   *CourseCatalogResponse `json:"catalog"`
+	FetchedAt time.Time              `json:"fetched_at"`
+	ExpiresAt time.Time              `json:"expires_at"`
+}
+
+// CatalogCacheStats is reported by the admin endpoint.
+type CatalogCacheStats struct {
+	Entries       int           `json:"entries"`
+	Hits          int64         `json:"hits"`
+	Misses        int64         `json:"misses"`
+	Invalidations int64         `json:"invalidations"`
+	HitRate       float64       `json:"hit_rate"`
+	TTLSeconds    float64       `json:"ttl_seconds"`
+	PersistPath   string        `json:"persist_path,omitempty"`
+	Keys          []cacheKeyAge `json:"keys"`
+}
+
+type cacheKeyAge struct {
+	CatalogCacheKey
+	FetchedAt time.Time `json:"fetched_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CatalogCache keeps course catalogs in memory for a TTL, optionally mirrored
+// to a JSON file so a restart does not go back to A1CE. Concurrent misses for
+// the same key share a single fetch.
+type CatalogCache struct {
+	ttl         time.Duration
+	persistPath string
+
+	mu       sync.Mutex
+	entries  map[string]*catalogCacheEntry
+	inflight map[string]*catalogFetch
+
+	hits          atomic.Int64
+	misses        atomic.Int64
+	invalidations atomic.Int64
+}
+
+type catalogFetch struct {
+	done    chan struct{}
+	catalog *CourseCatalogResponse
+	err     error
+}
+
+func NewCatalogCache(ttl time.Duration, persistPath string) *CatalogCache {
+	c := &CatalogCache{
+		ttl:         ttl,
+		persistPath: persistPath,
+		entries:     make(map[string]*catalogCacheEntry),
+		inflight:    make(map[string]*catalogFetch),
+	}
+	if persistPath != "" {
+		if err := c.load(); err != nil && !os.IsNotExist(err) {
+			log.Printf("(!) WARNING: Could not load catalog cache %s: %v", persistPath, err)
+		}
+	}
+	return c
+}
+
+// catalogFetchTimeout bounds a shared fetch, which no single request's
+// context governs.
+const catalogFetchTimeout = 2 * time.Minute
+
+// catalogCacheMaxEntries caps the cache; the oldest fetches go first.
+const catalogCacheMaxEntries = 64
+
+// Get returns the cached catalog for key or calls fetch to fill it. Partial
+// catalogs are returned but not cached. The fetch is shared by every caller
+// missing the same key, so it runs detached from ctx under
+// catalogFetchTimeout; a caller whose ctx ends stops waiting without
+// failing the others.
+func (c *CatalogCache) Get(ctx context.Context, key CatalogCacheKey, fetch func(ctx context.Context) (*CourseCatalogResponse, error)) (*CourseCatalogResponse, error) {
+	k := key.String()
+
+	c.mu.Lock()
+	if entry, ok := c.entries[k]; ok && time.Now().Before(entry.ExpiresAt) {
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return copyCatalog(entry.Catalog), nil
+	}
+	c.misses.Add(1)
+	f, ok := c.inflight[k]
+	if !ok {
+		f = &catalogFetch{done: make(chan struct{})}
+		c.inflight[k] = f
+		go c.fill(context.WithoutCancel(ctx), key, f, fetch)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return copyCatalog(f.catalog), nil
+}
+
+// fill runs one shared fetch and stores a complete result.
+func (c *CatalogCache) fill(ctx context.Context, key CatalogCacheKey, f *catalogFetch, fetch func(ctx context.Context) (*CourseCatalogResponse, error)) {
+	ctx, cancel := context.WithTimeout(ctx, catalogFetchTimeout)
+	defer cancel()
+	f.catalog, f.err = fetch(ctx)
+
+	k := key.String()
+	c.mu.Lock()
+	delete(c.inflight, k)
+	if f.err == nil && f.catalog.Status == "success" {
+		now := time.Now()
+		c.entries[k] = &catalogCacheEntry{Key: key, Catalog: copyCatalog(f.catalog), FetchedAt: now, ExpiresAt: now.Add(c.ttl)}
+		c.evictLocked(now)
+		c.persistLocked()
+	}
+	c.mu.Unlock()
+	close(f.done)
+}
+
+// evictLocked drops expired entries and then the oldest ones beyond
+// catalogCacheMaxEntries; the caller holds c.mu.
+func (c *CatalogCache) evictLocked(now time.Time) {
+	for k, entry := range c.entries {
+		if !now.Before(entry.ExpiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	if len(c.entries) <= catalogCacheMaxEntries {
+		return
+	}
+	keys := make([]string, 0, len(c.entries))
+	for k := range c.entries {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return c.entries[keys[i]].FetchedAt.Before(c.entries[keys[j]].FetchedAt) })
+	for _, k := range keys[:len(keys)-catalogCacheMaxEntries] {
+		delete(c.entries, k)
+	}
+}
+
+// Invalidate drops the entries matching match (all entries when match is
+// nil) and returns how many were removed.
+func (c *CatalogCache) Invalidate(match func(CatalogCacheKey) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for k, entry := range c.entries {
+		if match == nil || match(entry.Key) {
+			delete(c.entries, k)
+			removed++
+		}
+	}
+	c.invalidations.Add(int64(removed))
+	c.persistLocked()
+	return removed
+}
+
+func (c *CatalogCache) Stats() CatalogCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evictLocked(time.Now())
+
+	stats := CatalogCacheStats{
+		Entries:       len(c.entries),
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Invalidations: c.invalidations.Load(),
+		TTLSeconds:    c.ttl.Seconds(),
+		PersistPath:   c.persistPath,
+		Keys:          []cacheKeyAge{},
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	for _, entry := range c.entries {
+		stats.Keys = append(stats.Keys, cacheKeyAge{entry.Key, entry.FetchedAt, entry.ExpiresAt})
+	}
+	sort.Slice(stats.Keys, func(i, j int) bool {
+		return stats.Keys[i].String() < stats.Keys[j].String()
+	})
+	return stats
+}
+
+func (c *CatalogCache) load() error {
+	var entries []*catalogCacheEntry
+	if err := readJSONFile(c.persistPath, &entries); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		if entry.Catalog != nil && now.Before(entry.ExpiresAt) {
+			c.entries[entry.Key.String()] = entry
+		}
+	}
+	log.Printf("(✓) SUCCESS: Restored %d cached catalogs from %s", len(c.entries), c.persistPath)
+	return nil
+}
+
+// persistLocked rewrites the cache file; the caller holds c.mu.
+func (c *CatalogCache) persistLocked() {
+	if c.persistPath == "" {
+		return
+	}
+	entries := make([]*catalogCacheEntry, 0, len(c.entries))
+	for _, entry := range c.entries {
+		entries = append(entries, entry)
+	}
+	if err := writeJSONFileAtomic(c.persistPath, entries); err != nil {
+		log.Printf("(!) WARNING: Could not persist catalog cache: %v", err)
+	}
+}
+
+// writeJSONFileAtomic writes v to a temporary file and renames it into place.
+func writeJSONFileAtomic(filename string, v interface{}) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := json.NewEncoder(tmp).Encode(v); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// copyCatalog gives each caller its own course slice, since the service
+// annotates courses in place.
+func copyCatalog(catalog *CourseCatalogResponse) *CourseCatalogResponse {
+	out := *catalog
+	out.Courses = append([]Course(nil), catalog.Courses...)
+	return &out
+}
+
+// cachedDataSource serves GetCourseCatalog through a CatalogCache.
+type cachedDataSource struct {
+	DataSource
+	cache          *CatalogCache
+	universityCode func() string
+}
+
+func (s *cachedDataSource) GetCourseCatalog(ctx context.Context, semester string, curriculumVersion int) (*CourseCatalogResponse, error) {
+	key := CatalogCacheKey{Semester: semester, CurriculumVersion: curriculumVersion, UniversityCode: s.universityCode()}
+	return s.cache.Get(ctx, key, func(ctx context.Context) (*CourseCatalogResponse, error) {
+		return s.DataSource.GetCourseCatalog(ctx, semester, curriculumVersion)
+	})
+}
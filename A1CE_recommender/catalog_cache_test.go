@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCatalogCacheSharedFetchOutlivesCancelledCaller(t *testing.T) {
+	cache := NewCatalogCache(time.Hour, "")
+	key := CatalogCacheKey{Semester: "Fall 2025"}
+	release := make(chan struct{})
+	fetches := 0
+	fetch := func(ctx context.Context) (*CourseCatalogResponse, error) {
+		fetches++
+		select {
+		case <-release:
+			return &CourseCatalogResponse{Status: "success", Courses: []Course{{CourseCode: "AIC-101"}}}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := cache.Get(first, key, fetch)
+		firstErr <- err
+	}()
+	waitForInflight(t, cache, key)
+
+	second := make(chan error, 1)
+	go func() {
+		catalog, err := cache.Get(context.Background(), key, func(context.Context) (*CourseCatalogResponse, error) {
+			return nil, errors.New("second fetch started")
+		})
+		if err == nil && len(catalog.Courses) != 1 {
+			err = fmt.Errorf("got %d courses", len(catalog.Courses))
+		}
+		second <- err
+	}()
+
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled caller: err = %v, want context.Canceled", err)
+	}
+	close(release)
+	if err := <-second; err != nil {
+		t.Fatalf("waiting caller: %v", err)
+	}
+	if fetches != 1 {
+		t.Errorf("fetches = %d, want 1", fetches)
+	}
+	if got := cache.Stats().Entries; got != 1 {
+		t.Errorf("entries = %d, want 1", got)
+	}
+}
+
+func waitForInflight(t *testing.T, cache *CatalogCache, key CatalogCacheKey) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		cache.mu.Lock()
+		_, ok := cache.inflight[key.String()]
+		cache.mu.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("fetch never started")
+}
+
+func TestCatalogCacheEviction(t *testing.T) {
+	cache := NewCatalogCache(time.Hour, "")
+	fetch := func(context.Context) (*CourseCatalogResponse, error) {
+		return &CourseCatalogResponse{Status: "success"}, nil
+	}
+
+	cache.entries["stale"] = &catalogCacheEntry{Catalog: &CourseCatalogResponse{}, ExpiresAt: time.Now().Add(-time.Minute)}
+	for i := 0; i < catalogCacheMaxEntries+5; i++ {
+		if _, err := cache.Get(context.Background(), CatalogCacheKey{CurriculumVersion: i}, fetch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if _, ok := cache.entries["stale"]; ok {
+		t.Error("expired entry was not swept")
+	}
+	if len(cache.entries) != catalogCacheMaxEntries {
+		t.Errorf("entries = %d, want %d", len(cache.entries), catalogCacheMaxEntries)
+	}
+}
@@ -1,34 +1,508 @@
 package main
 
 import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Config holds application configuration
 type Config struct {
-	ServerPort  string
-	A1CEBaseURL string
-	JWTSecret   string
-	UseMockData bool
-	LogLevel    string
+	ConfigFile            string
+	ServerPort            string
+	A1CEBaseURL           string
+	UniversityCode        string
+	CatalogConcurrency    int
+	SubdomainTimeout      time.Duration
+	A1CEMaxRetries        int
+	A1CERetryBaseDelay    time.Duration
+	BreakerThreshold      int
+	BreakerCooldown       time.Duration
+	AuthEnabled           bool
+	JWTSecret             string
+	JWKSFile              string
+	JWTIssuer             string
+	UseMockData           bool
+	LogLevel              string
+	DataSource            string // a1ce, sqlite or fixtures
+	DatabasePath          string
+	FixtureDir            string
+	CatalogCacheEnabled   bool
+	CatalogCacheTTL       time.Duration
+	CatalogCacheFile      string
+	IdentityMapPath       string
+	CurriculumRulesPath   string
+	PrerequisiteRulesPath string
+	CurriculumDir         string
+	// ReferenceReloadInterval is how often the reference files are checked
+	// for changes; 0 loads them once.
+	ReferenceReloadInterval time.Duration
+	StrictProfile           bool
+	Optimizer               string
+	WeightProfile           string
+	WeightProfilesPath      string
+	ScheduleFile            string
+	HistoryEnabled          bool
+	HistoryDBPath           string
+	RoadmapDBPath           string
+	RoadmapPersist          bool
+	EvalReportPath          string
+	EvalJSONReportPath      string
+	EvalK                   int
+	EvalAlgorithm           string
+	EvalMaxCreditLoad       int
+	EvalSeed                int64
+	EvalSplit               string
+	EvalTestFraction        float64
+	EvalFolds               int
+	// EvalValidationFraction is the share of each student's training rows
+	// eval tune holds out to score trials on.
+	EvalValidationFraction float64
+	RecommendationsCSVPath string
+
+	// Content-similarity scoring, see ContentSimilarityParams.
+	SimilarityGradeWeight    float64
+	SimilarityRatingWeight   float64
+	SimilarityRequiredWeight float64
+	SimilarityTopThreshold   float64
+	SimilarityNeighbors      int
 }
 
-// LoadConfig loads configuration from environment variables
-func LoadConfig() *Config {
+// configField binds one setting to its config-file key, environment variable
+// and command-line flag. The flag name is the file key with dashes.
+type configField struct {
+	key    string
+	env    string
+	usage  string
+	secret bool
+	get    func(c *Config) string
+	set    func(c *Config, v string) error
+}
+
+func stringField(key, env, usage string, ptr func(c *Config) *string) configField {
+	return configField{
+		key: key, env: env, usage: usage,
+		get: func(c *Config) string { return *ptr(c) },
+		set: func(c *Config, v string) error { *ptr(c) = v; return nil },
+	}
+}
+
+func durationField(key, env, usage string, ptr func(c *Config) *time.Duration) configField {
+	return configField{
+		key: key, env: env, usage: usage,
+		get: func(c *Config) string { return ptr(c).String() },
+		set: func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return err
+			}
+			*ptr(c) = d
+			return nil
+		},
+	}
+}
+
+func intField(key, env, usage string, ptr func(c *Config) *int) configField {
+	return configField{
+		key: key, env: env, usage: usage,
+		get: func(c *Config) string { return strconv.Itoa(*ptr(c)) },
+		set: func(c *Config, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return err
+			}
+			*ptr(c) = n
+			return nil
+		},
+	}
+}
+
+func int64Field(key, env, usage string, ptr func(c *Config) *int64) configField {
+	return configField{
+		key: key, env: env, usage: usage,
+		get: func(c *Config) string { return strconv.FormatInt(*ptr(c), 10) },
+		set: func(c *Config, v string) error {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return err
+			}
+			*ptr(c) = n
+			return nil
+		},
+	}
+}
+
+func floatField(key, env, usage string, ptr func(c *Config) *float64) configField {
+	return configField{
+		key: key, env: env, usage: usage,
+		get: func(c *Config) string { return strconv.FormatFloat(*ptr(c), 'g', -1, 64) },
+		set: func(c *Config, v string) error {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return err
+			}
+			*ptr(c) = f
+			return nil
+		},
+	}
+}
+
+func boolField(key, env, usage string, ptr func(c *Config) *bool) configField {
+	return configField{
+		key: key, env: env, usage: usage,
+		get: func(c *Config) string { return strconv.FormatBool(*ptr(c)) },
+		set: func(c *Config, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			*ptr(c) = b
+			return nil
+		},
+	}
+}
+
+var configFields = []configField{
+	stringField("server_port", "PORT", "HTTP listen port", func(c *Config) *string { return &c.ServerPort }),
+	stringField("a1ce_base_url", "A1CE_BASE_URL", "A1CE API base URL", func(c *Config) *string { return &c.A1CEBaseURL }),
+	stringField("university_code", "UNIVERSITY_CODE", "university code catalogs are scoped to", func(c *Config) *string { return &c.UniversityCode }),
+	intField("catalog_concurrency", "CATALOG_CONCURRENCY", "parallel A1CE subdomain requests per catalog", func(c *Config) *int { return &c.CatalogConcurrency }),
+	durationField("subdomain_timeout", "SUBDOMAIN_TIMEOUT", "deadline for each A1CE subdomain request", func(c *Config) *time.Duration { return &c.SubdomainTimeout }),
+	intField("a1ce_max_retries", "A1CE_MAX_RETRIES", "retries of an A1CE call after a 5xx or timeout", func(c *Config) *int { return &c.A1CEMaxRetries }),
+	durationField("a1ce_retry_base_delay", "A1CE_RETRY_BASE_DELAY", "initial backoff between A1CE retries", func(c *Config) *time.Duration { return &c.A1CERetryBaseDelay }),
+	intField("breaker_threshold", "BREAKER_THRESHOLD", "consecutive A1CE failures that open the circuit breaker", func(c *Config) *int { return &c.BreakerThreshold }),
+	durationField("breaker_cooldown", "BREAKER_COOLDOWN", "how long the open circuit fails fast before a trial call", func(c *Config) *time.Duration { return &c.BreakerCooldown }),
+	boolField("auth_enabled", "AUTH_ENABLED", "require a valid JWT on API calls", func(c *Config) *bool { return &c.AuthEnabled }),
+	func() configField {
+		f := stringField("jwt_secret", "JWT_SECRET", "HS256 secret used to validate JWTs", func(c *Config) *string { return &c.JWTSecret })
+		f.secret = true
+		return f
+	}(),
+	stringField("jwks_file", "JWKS_FILE", "JWKS file with RS256 keys used to validate JWTs", func(c *Config) *string { return &c.JWKSFile }),
+	stringField("jwt_issuer", "JWT_ISSUER", "required JWT issuer (empty accepts any)", func(c *Config) *string { return &c.JWTIssuer }),
+	boolField("use_mock_data", "USE_MOCK_DATA", "serve the JSON fixtures instead of A1CE", func(c *Config) *bool { return &c.UseMockData }),
+	stringField("log_level", "LOG_LEVEL", "log level (debug or info)", func(c *Config) *string { return &c.LogLevel }),
+	stringField("data_source", "DATA_SOURCE", "data source: a1ce, sqlite or fixtures", func(c *Config) *string { return &c.DataSource }),
+	stringField("database_path", "DATABASE_PATH", "SQLite snapshot path", func(c *Config) *string { return &c.DatabasePath }),
+	stringField("fixture_dir", "FIXTURE_DIR", "JSON fixture directory", func(c *Config) *string { return &c.FixtureDir }),
+	boolField("catalog_cache_enabled", "CATALOG_CACHE_ENABLED", "cache course catalogs in process", func(c *Config) *bool { return &c.CatalogCacheEnabled }),
+	durationField("catalog_cache_ttl", "CATALOG_CACHE_TTL", "how long a cached catalog is served", func(c *Config) *time.Duration { return &c.CatalogCacheTTL }),
+	stringField("catalog_cache_file", "CATALOG_CACHE_FILE", "optional file the catalog cache is persisted to", func(c *Config) *string { return &c.CatalogCacheFile }),
+	stringField("identity_map_path", "IDENTITY_MAP_PATH", "course identity map file", func(c *Config) *string { return &c.IdentityMapPath }),
+	stringField("curriculum_rules_path", "CURRICULUM_RULES_PATH", "curriculum rules file", func(c *Config) *string { return &c.CurriculumRulesPath }),
+	stringField("prerequisite_rules_path", "PREREQUISITE_RULES_PATH", "versioned prerequisite grade threshold file", func(c *Config) *string { return &c.PrerequisiteRulesPath }),
+	stringField("curriculum_dir", "CURRICULUM_DIR", "directory of curriculum definition files", func(c *Config) *string { return &c.CurriculumDir }),
+	durationField("reference_reload_interval", "REFERENCE_RELOAD_INTERVAL", "how often the identity map and rules files are checked for changes (0 disables)", func(c *Config) *time.Duration { return &c.ReferenceReloadInterval }),
+	boolField("strict_profile", "STRICT_PROFILE", "refuse to recommend when a student's history cannot be loaded", func(c *Config) *bool { return &c.StrictProfile }),
+	stringField("optimizer", "OPTIMIZER", "default course set optimizer: greedy or exact", func(c *Config) *string { return &c.Optimizer }),
+	stringField("weight_profile", "WEIGHT_PROFILE", "default scoring weight profile", func(c *Config) *string { return &c.WeightProfile }),
+	stringField("weight_profiles_path", "WEIGHT_PROFILES_PATH", "JSON file of additional scoring weight profiles", func(c *Config) *string { return &c.WeightProfilesPath }),
+	stringField("schedule_file", "SCHEDULE_FILE", "JSON file of course sections used for time preferences", func(c *Config) *string { return &c.ScheduleFile }),
+	boolField("history_enabled", "HISTORY_ENABLED", "store generated recommendation sets", func(c *Config) *bool { return &c.HistoryEnabled }),
+	stringField("history_db_path", "HISTORY_DB_PATH", "SQLite file for recommendation history", func(c *Config) *string { return &c.HistoryDBPath }),
+	stringField("roadmap_db_path", "ROADMAP_DB_PATH", "SQLite file for stored roadmaps", func(c *Config) *string { return &c.RoadmapDBPath }),
+	boolField("roadmap_persist", "ROADMAP_PERSIST", "store generated roadmaps in Recommended_roadmap of roadmap_db_path", func(c *Config) *bool { return &c.RoadmapPersist }),
+	stringField("eval_report_path", "EVAL_REPORT_PATH", "evaluation report file", func(c *Config) *string { return &c.EvalReportPath }),
+	stringField("eval_json_report_path", "EVAL_JSON_REPORT_PATH", "machine-readable evaluation report file", func(c *Config) *string { return &c.EvalJSONReportPath }),
+	intField("eval_k", "EVAL_K", "cut-off k for the ranking metrics of the evaluation", func(c *Config) *int { return &c.EvalK }),
+	stringField("eval_algorithm", "EVAL_ALGORITHM", "algorithm evaluated by eval: pipeline (the served recommender), legacy or a registered recommender", func(c *Config) *string { return &c.EvalAlgorithm }),
+	intField("eval_max_credit_load", "EVAL_MAX_CREDIT_LOAD", "credit load each evaluated recommendation set may fill", func(c *Config) *int { return &c.EvalMaxCreditLoad }),
+	stringField("eval_split", "EVAL_SPLIT", "how eval splits the records: tables (student_train and student_test), or random, temporal or kfold over the student table", func(c *Config) *string { return &c.EvalSplit }),
+	floatField("eval_test_fraction", "EVAL_TEST_FRACTION", "share of each student's rows the random and temporal splits hold out", func(c *Config) *float64 { return &c.EvalTestFraction }),
+	intField("eval_folds", "EVAL_FOLDS", "number of folds of eval_split=kfold", func(c *Config) *int { return &c.EvalFolds }),
+	int64Field("eval_seed", "EVAL_SEED", "seed for the random choices of the evaluation", func(c *Config) *int64 { return &c.EvalSeed }),
+	floatField("eval_validation_fraction", "EVAL_VALIDATION_FRACTION", "share of each student's training rows eval tune validates on", func(c *Config) *float64 { return &c.EvalValidationFraction }),
+	floatField("similarity_grade_weight", "SIMILARITY_GRADE_WEIGHT", "content-similarity weight of grade/4", func(c *Config) *float64 { return &c.SimilarityGradeWeight }),
+	floatField("similarity_rating_weight", "SIMILARITY_RATING_WEIGHT", "content-similarity weight of rating/5", func(c *Config) *float64 { return &c.SimilarityRatingWeight }),
+	floatField("similarity_required_weight", "SIMILARITY_REQUIRED_WEIGHT", "content-similarity weight of a required competency", func(c *Config) *float64 { return &c.SimilarityRequiredWeight }),
+	floatField("similarity_top_threshold", "SIMILARITY_TOP_THRESHOLD", "content-similarity score a top competency reaches", func(c *Config) *float64 { return &c.SimilarityTopThreshold }),
+	intField("similarity_neighbors", "SIMILARITY_NEIGHBORS", "similar competencies content-similarity brings in per top competency", func(c *Config) *int { return &c.SimilarityNeighbors }),
+	stringField("recommendations_csv_path", "RECOMMENDATIONS_CSV_PATH", "evaluation recommendations CSV", func(c *Config) *string { return &c.RecommendationsCSVPath }),
+}
+
+// DefaultConfig returns the built-in settings.
+func DefaultConfig() *Config {
 	return &Config{
-		ServerPort:  getEnv("PORT", "8080"),
-		A1CEBaseURL: getEnv("A1CE_BASE_URL", "https://teaching.cmkl.ai/api"),
-		JWTSecret:   getEnv("JWT_SECRET", "your-secret-key"),
-		UseMockData: getEnv("USE_MOCK_DATA", "false") == "true",
-		LogLevel:    getEnv("LOG_LEVEL", "info"),
+		ServerPort:              "8080",
+		A1CEBaseURL:             "https://a1ce.cmkl.ac.th/api",
+		UniversityCode:          "CMKL",
+		CatalogConcurrency:      4,
+		SubdomainTimeout:        8 * time.Second,
+		A1CEMaxRetries:          2,
+		A1CERetryBaseDelay:      200 * time.Millisecond,
+		BreakerThreshold:        5,
+		BreakerCooldown:         30 * time.Second,
+		AuthEnabled:             true,
+		LogLevel:                "info",
+		DataSource:              DataSourceA1CE,
+		DatabasePath:            "a1ce_recommendation.db",
+		FixtureDir:              "fixtures",
+		CatalogCacheEnabled:     true,
+		CatalogCacheTTL:         6 * time.Hour,
+		IdentityMapPath:         "course_identities.json",
+		CurriculumRulesPath:     "curriculum_rules.json",
+		PrerequisiteRulesPath:   "prerequisite_rules.json",
+		CurriculumDir:           "curricula",
+		ReferenceReloadInterval: 30 * time.Second,
+		Optimizer:               OptimizerGreedy,
+		WeightProfile:           DefaultWeightProfile,
+		WeightProfilesPath:      "weight_profiles.json",
+		HistoryEnabled:          true,
+		HistoryDBPath:           filepath.Join("data", "history.db"),
+		RoadmapDBPath:           filepath.Join("data", "roadmaps.db"),
+		RoadmapPersist:          true,
+		EvalReportPath:          filepath.Join("logs", "evaluation_report.txt"),
+		EvalJSONReportPath:      filepath.Join("logs", "evaluation_report.json"),
+		EvalK:                   10,
+		EvalAlgorithm:           EvalAlgorithmPipeline,
+		EvalMaxCreditLoad:       60,
+		EvalSeed:                1,
+		EvalSplit:               EvalSplitTables,
+		EvalTestFraction:        0.2,
+		EvalFolds:               5,
+		EvalValidationFraction:  0.2,
+		RecommendationsCSVPath:  "student_recommendations.csv",
+
+		SimilarityGradeWeight:    defaultContentSimilarityParams.GradeWeight,
+		SimilarityRatingWeight:   defaultContentSimilarityParams.RatingWeight,
+		SimilarityRequiredWeight: defaultContentSimilarityParams.RequiredWeight,
+		SimilarityTopThreshold:   defaultContentSimilarityParams.TopThreshold,
+		SimilarityNeighbors:      defaultContentSimilarityParams.Neighbors,
+	}
+}
+
+// LoadConfig builds the configuration from, in increasing precedence: the
+// defaults, an optional config file (-config or CONFIG_FILE), environment
+// variables and command-line flags. Callers may register extra flags on fs
+// before calling; fs is parsed here.
+func LoadConfig(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := DefaultConfig()
+
+	flagValues := make(map[string]string)
+	fs.StringVar(&cfg.ConfigFile, "config", os.Getenv("CONFIG_FILE"), "config file (.json, .yaml or .yml)")
+	for _, f := range configFields {
+		key := f.key
+		fs.Func(strings.ReplaceAll(key, "_", "-"), f.usage, func(v string) error {
+			flagValues[key] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if cfg.ConfigFile != "" {
+		fileValues, err := readConfigFile(cfg.ConfigFile)
+		if err != nil {
+			return nil, fmt.Errorf("config file %s: %w", cfg.ConfigFile, err)
+		}
+		if err := cfg.apply(fileValues, "config file"); err != nil {
+			return nil, err
+		}
 	}
+
+	envValues := make(map[string]string)
+	for _, f := range configFields {
+		if v := os.Getenv(f.env); v != "" {
+			envValues[f.key] = v
+		}
+	}
+	if err := cfg.apply(envValues, "environment"); err != nil {
+		return nil, err
+	}
+	if err := cfg.apply(flagValues, "flag"); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+func (c *Config) apply(values map[string]string, origin string) error {
+	for _, f := range configFields {
+		v, ok := values[f.key]
+		if !ok {
+			continue
+		}
+		if err := f.set(c, v); err != nil {
+			return fmt.Errorf("%s: invalid %s %q: %w", origin, f.key, v, err)
+		}
 	}
-	return defaultValue
+	return nil
+}
+
+// Validate rejects settings no component can run with.
+func (c *Config) Validate() error {
+	switch c.DataSource {
+	case DataSourceA1CE, DataSourceSQLite, DataSourceFixtures:
+	default:
+		return fmt.Errorf("data_source must be one of %s, %s, %s; got %q",
+			DataSourceA1CE, DataSourceSQLite, DataSourceFixtures, c.DataSource)
+	}
+	if c.Optimizer == "" || !validOptimizer(c.Optimizer) {
+		return fmt.Errorf("optimizer must be %s or %s; got %q", OptimizerGreedy, OptimizerExact, c.Optimizer)
+	}
+	if c.A1CEMaxRetries < 0 {
+		return fmt.Errorf("a1ce_max_retries must not be negative; got %d", c.A1CEMaxRetries)
+	}
+	if c.BreakerThreshold <= 0 {
+		return fmt.Errorf("breaker_threshold must be positive; got %d", c.BreakerThreshold)
+	}
+	if c.CatalogConcurrency <= 0 {
+		return fmt.Errorf("catalog_concurrency must be positive; got %d", c.CatalogConcurrency)
+	}
+	if c.CatalogCacheEnabled && c.CatalogCacheTTL <= 0 {
+		return fmt.Errorf("catalog_cache_ttl must be positive; got %s", c.CatalogCacheTTL)
+	}
+	if c.EvalK <= 0 {
+		return fmt.Errorf("eval_k must be positive; got %d", c.EvalK)
+	}
+	if !validEvalAlgorithm(c.EvalAlgorithm) {
+		return fmt.Errorf("eval_algorithm must be %s, %s or one of %s; got %q",
+			EvalAlgorithmPipeline, EvalAlgorithmLegacy, strings.Join(RecommenderNames(), ", "), c.EvalAlgorithm)
+	}
+	if c.EvalMaxCreditLoad <= 0 {
+		return fmt.Errorf("eval_max_credit_load must be positive; got %d", c.EvalMaxCreditLoad)
+	}
+	if !validEvalSplit(c.EvalSplit) {
+		return fmt.Errorf("eval_split must be %s, %s, %s or %s; got %q",
+			EvalSplitTables, EvalSplitRandom, EvalSplitTemporal, EvalSplitKFold, c.EvalSplit)
+	}
+	if c.EvalTestFraction <= 0 || c.EvalTestFraction >= 1 {
+		return fmt.Errorf("eval_test_fraction must be between 0 and 1; got %g", c.EvalTestFraction)
+	}
+	if c.EvalFolds < 2 {
+		return fmt.Errorf("eval_folds must be at least 2; got %d", c.EvalFolds)
+	}
+	if c.EvalValidationFraction <= 0 || c.EvalValidationFraction >= 1 {
+		return fmt.Errorf("eval_validation_fraction must be between 0 and 1; got %g", c.EvalValidationFraction)
+	}
+	if err := c.SimilarityParams().validate(); err != nil {
+		return err
+	}
+	if c.ReferenceReloadInterval < 0 {
+		return fmt.Errorf("reference_reload_interval must not be negative; got %s", c.ReferenceReloadInterval)
+	}
+	if _, err := strconv.Atoi(c.ServerPort); err != nil {
+		return fmt.Errorf("server_port must be numeric; got %q", c.ServerPort)
+	}
+	return nil
+}
+
+// SimilarityParams collects the content-similarity settings.
+func (c *Config) SimilarityParams() ContentSimilarityParams {
+	return ContentSimilarityParams{
+		GradeWeight:    c.SimilarityGradeWeight,
+		RatingWeight:   c.SimilarityRatingWeight,
+		RequiredWeight: c.SimilarityRequiredWeight,
+		TopThreshold:   c.SimilarityTopThreshold,
+		Neighbors:      c.SimilarityNeighbors,
+	}
+}
+
+// LogEffective writes the effective configuration to the log with secrets
+// redacted.
+func (c *Config) LogEffective() {
+	log.Println("Effective configuration:")
+	if c.ConfigFile != "" {
+		log.Printf("  %-26s %s", "config_file", c.ConfigFile)
+	}
+	for _, f := range configFields {
+		v := f.get(c)
+		if f.secret && v != "" {
+			v = "[REDACTED]"
+		}
+		log.Printf("  %-26s %s", f.key, v)
+	}
+}
+
+// readConfigFile reads a JSON object or a flat "key: value" YAML file into
+// string values keyed like the JSON settings.
+func readConfigFile(filename string) (map[string]string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		var raw map[string]interface{}
+		if err := readJSONFile(filename, &raw); err != nil {
+			return nil, err
+		}
+		values := make(map[string]string)
+		for k, v := range raw {
+			if _, ok := lookupConfigField(k); !ok {
+				log.Printf("(!) WARNING: ignoring unknown config key %q", k)
+				continue
+			}
+			values[k] = configValueString(v)
+		}
+		return values, nil
+	case ".yaml", ".yml":
+		return readFlatYAML(filename)
+	default:
+		return nil, fmt.Errorf("unsupported config format %q", filepath.Ext(filename))
+	}
+}
+
+// configValueString formats a decoded JSON value the way a flag would take
+// it. Numbers decode as float64, which fmt prints in exponent form once they
+// are large (1.2345678e+07), so they are written out in full.
+func configValueString(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// readFlatYAML supports the subset of YAML the config needs: one scalar
+// "key: value" per line, "#" comments and optional quotes.
+func readFlatYAML(filename string) (map[string]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || line == "---" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: value", lineNo)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if idx := strings.Index(value, " #"); idx >= 0 {
+			value = strings.TrimSpace(value[:idx])
+		}
+		value = strings.Trim(value, `"'`)
+		if _, ok := lookupConfigField(key); !ok {
+			log.Printf("(!) WARNING: ignoring unknown config key %q", key)
+			continue
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
+
+func lookupConfigField(key string) (configField, bool) {
+	for _, f := range configFields {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return configField{}, false
 }
 
 /*
@@ -74,12 +548,67 @@ func getEnv(key, defaultValue string) string {
 
 === ENVIRONMENT VARIABLES ===
 
+Settings are read, in increasing precedence, from the defaults, an optional
+config file, environment variables and command-line flags.
+
 Create a .env file (optional):
 PORT=8080
-A1CE_BASE_URL=https://teaching.cmkl.ai/api
-JWT_SECRET=your-secret-key
+A1CE_BASE_URL=https://a1ce.cmkl.ac.th/api
+UNIVERSITY_CODE=CMKL
+CATALOG_CONCURRENCY=4
+SUBDOMAIN_TIMEOUT=8s
+A1CE_MAX_RETRIES=2
+A1CE_RETRY_BASE_DELAY=200ms
+BREAKER_THRESHOLD=5
+BREAKER_COOLDOWN=30s
+AUTH_ENABLED=true             # false skips JWT validation (local testing only)
+JWT_SECRET=your-secret-key    # HS256 secret, and/or
+JWKS_FILE=                    # JWKS file with RS256 keys
+JWT_ISSUER=                   # required "iss" claim, if set
 USE_MOCK_DATA=false
 LOG_LEVEL=info
+DATA_SOURCE=a1ce              # a1ce, sqlite or fixtures
+DATABASE_PATH=a1ce_recommendation.db
+FIXTURE_DIR=fixtures
+CATALOG_CACHE_ENABLED=true
+CATALOG_CACHE_TTL=6h
+CATALOG_CACHE_FILE=           # optional, e.g. cache/catalogs.json
+IDENTITY_MAP_PATH=course_identities.json
+CURRICULUM_RULES_PATH=curriculum_rules.json
+PREREQUISITE_RULES_PATH=prerequisite_rules.json
+CURRICULUM_DIR=curricula
+REFERENCE_RELOAD_INTERVAL=30s # 0 loads the files once
+STRICT_PROFILE=false
+OPTIMIZER=greedy
+WEIGHT_PROFILE=default        # default, balanced, graduation-first, explore or one from the file
+WEIGHT_PROFILES_PATH=weight_profiles.json
+SCHEDULE_FILE=
+HISTORY_ENABLED=true
+HISTORY_DB_PATH=data/history.db # kept apart from the tracked snapshot
+ROADMAP_DB_PATH=data/roadmaps.db
+ROADMAP_PERSIST=true
+EVAL_REPORT_PATH=logs/evaluation_report.txt
+EVAL_JSON_REPORT_PATH=logs/evaluation_report.json
+EVAL_K=10
+EVAL_ALGORITHM=pipeline       # pipeline, legacy, content-similarity, fit-score, popularity or random
+EVAL_MAX_CREDIT_LOAD=60
+EVAL_SEED=1
+EVAL_SPLIT=tables             # tables, random, temporal or kfold
+EVAL_TEST_FRACTION=0.2
+EVAL_FOLDS=5
+EVAL_VALIDATION_FRACTION=0.2
+SIMILARITY_GRADE_WEIGHT=0.5   # content-similarity (legacy) scoring, see eval tune
+SIMILARITY_RATING_WEIGHT=0.3
+SIMILARITY_REQUIRED_WEIGHT=0.2
+SIMILARITY_TOP_THRESHOLD=0.8
+SIMILARITY_NEIGHBORS=3
+RECOMMENDATIONS_CSV_PATH=student_recommendations.csv
+CONFIG_FILE=                  # optional .json or flat .yaml file
+
+The config file uses the lower-case keys (server_port, a1ce_base_url, ...)
+and every key has a matching flag (-server-port, -a1ce-base-url, ...):
+   ./recommender -config recommender.yaml -server-port 9090
+   ./recommender eval -database-path snapshot.db
 
 === DEPLOYMENT ===
 
@@ -163,7 +692,9 @@ chmod +x test_api.sh
    go version
    (Need Go 1.21 or higher)
 
-4. If A1CE API is unavailable, set USE_MOCK_DATA=true to test with mock data
+4. If A1CE API is unavailable, set USE_MOCK_DATA=true to serve the JSON
+   fixtures in FIXTURE_DIR, or DATA_SOURCE=sqlite to serve the
+   a1ce_recommendation.db snapshot
 
 === PROJECT STRUCTURE ===
 
@@ -183,10 +714,8 @@ a1ce-recommender/
 
 1. Implement actual JWT validation with A1CE
 2. Add proper error handling and logging
-3. Implement caching for course catalog
 4. Add rate limiting
 5. Create comprehensive tests
 6. Add Swagger/OpenAPI documentation
 7. Implement monitoring and metrics
-8. Add database for storing recommendation history
 */
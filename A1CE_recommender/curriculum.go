@@ -0,0 +1,423 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// defaultTotalCredits is used when neither a curriculum file nor the
+// student's graduation status gives the credits needed to graduate.
+const defaultTotalCredits = 120
+
+// courseCodePattern is the shape of a course code, e.g. AIC-201.
+var courseCodePattern = regexp.MustCompile(`^[A-Z]{2,4}-?[0-9]{3}[A-Z]?$`)
+
+// Curriculum defines one program for a university_code and
+// curriculum_version. Files live in curriculum_dir, one program per file; see
+// curricula/cmkl.json. Default marks the program used for a university's
+// students whose version has no file of its own.
+type Curriculum struct {
+	UniversityCode    string             `json:"university_code"`
+	CurriculumVersion int                `json:"curriculum_version"`
+	Default           bool               `json:"default,omitempty"`
+	TotalCredits      float64            `json:"total_credits"`
+	RequiredCourses   []string           `json:"required_courses"`
+	DistributionAreas []DistributionArea `json:"distribution_areas"`
+	ElectivePools     []ElectivePool     `json:"elective_pools"`
+	EquivalenceGroups [][]string         `json:"equivalence_groups"`
+
+	file string
+}
+
+// DistributionArea is a credit minimum over the courses whose code starts
+// with one of Prefixes or whose subdomain (ID or name) is listed.
+type DistributionArea struct {
+	Name       string   `json:"name"`
+	Prefixes   []string `json:"prefixes,omitempty"`
+	Subdomains []string `json:"subdomains,omitempty"`
+	MinCredits float64  `json:"min_credits"`
+}
+
+// ElectivePool is a credit minimum over an explicit list of courses.
+type ElectivePool struct {
+	Name       string   `json:"name"`
+	Courses    []string `json:"courses"`
+	MinCredits float64  `json:"min_credits"`
+}
+
+func (c *Curriculum) key() string {
+	return fmt.Sprintf("%s|%d", strings.ToUpper(c.UniversityCode), c.CurriculumVersion)
+}
+
+func (c *Curriculum) String() string {
+	return fmt.Sprintf("%s v%d", c.UniversityCode, c.CurriculumVersion)
+}
+
+// validate checks the definition is internally consistent.
+func (c *Curriculum) validate() error {
+	if c.UniversityCode == "" {
+		return fmt.Errorf("university_code is required")
+	}
+	if c.TotalCredits <= 0 {
+		return fmt.Errorf("total_credits must be positive")
+	}
+
+	checkCodes := func(what string, codes []string) error {
+		seen := make(map[string]bool)
+		for _, code := range codes {
+			if !courseCodePattern.MatchString(code) {
+				return fmt.Errorf("%s: malformed course code %q", what, code)
+			}
+			if seen[normalizeCode(code)] {
+				return fmt.Errorf("%s: %s listed twice", what, code)
+			}
+			seen[normalizeCode(code)] = true
+		}
+		return nil
+	}
+	if err := checkCodes("required_courses", c.RequiredCourses); err != nil {
+		return err
+	}
+
+	names := make(map[string]bool)
+	minimums := 0.0
+	for _, a := range c.DistributionAreas {
+		if a.Name == "" || names[a.Name] {
+			return fmt.Errorf("distribution area names must be present and unique (%q)", a.Name)
+		}
+		names[a.Name] = true
+		if len(a.Prefixes) == 0 && len(a.Subdomains) == 0 {
+			return fmt.Errorf("distribution area %s: no prefixes or subdomains", a.Name)
+		}
+		if a.MinCredits < 0 {
+			return fmt.Errorf("distribution area %s: negative min_credits", a.Name)
+		}
+		minimums += a.MinCredits
+	}
+	for _, p := range c.ElectivePools {
+		if p.Name == "" || names[p.Name] {
+			return fmt.Errorf("elective pool names must be present and unique among areas and pools (%q)", p.Name)
+		}
+		names[p.Name] = true
+		if err := checkCodes("elective pool "+p.Name, p.Courses); err != nil {
+			return err
+		}
+		if p.MinCredits < 0 {
+			return fmt.Errorf("elective pool %s: negative min_credits", p.Name)
+		}
+		minimums += p.MinCredits
+	}
+	if minimums > c.TotalCredits {
+		return fmt.Errorf("area and pool minimums (%.0f) exceed total_credits (%.0f)", minimums, c.TotalCredits)
+	}
+
+	inGroup := make(map[string]int)
+	for i, group := range c.EquivalenceGroups {
+		if len(group) < 2 {
+			return fmt.Errorf("equivalence group %d: needs at least two codes", i+1)
+		}
+		if err := checkCodes(fmt.Sprintf("equivalence group %d", i+1), group); err != nil {
+			return err
+		}
+		for _, code := range group {
+			if j, ok := inGroup[normalizeCode(code)]; ok {
+				return fmt.Errorf("%s is in equivalence groups %d and %d", code, j, i+1)
+			}
+			inGroup[normalizeCode(code)] = i + 1
+		}
+	}
+	return nil
+}
+
+// missingRequired lists the codes rules (normalised, as curriculum rules are
+// loaded) marks required that the curriculum does not list, directly or
+// through an equivalence group.
+func (c *Curriculum) missingRequired(rules map[string]bool) []string {
+	listed := make(map[string]bool)
+	for _, code := range c.RequiredCourses {
+		listed[normalizeCode(code)] = true
+	}
+	for _, group := range c.EquivalenceGroups {
+		for _, code := range group {
+			if listed[normalizeCode(code)] {
+				for _, member := range group {
+					listed[normalizeCode(member)] = true
+				}
+				break
+			}
+		}
+	}
+	var missing []string
+	for code, required := range rules {
+		if required && !listed[code] {
+			missing = append(missing, code)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// CurriculumRegistry holds the loaded curricula by university and version.
+type CurriculumRegistry struct {
+	byKey     map[string]*Curriculum
+	defaults  map[string]*Curriculum
+	Curricula []*Curriculum
+}
+
+func newCurriculumRegistry() *CurriculumRegistry {
+	return &CurriculumRegistry{byKey: make(map[string]*Curriculum), defaults: make(map[string]*Curriculum)}
+}
+
+// loadCurricula reads and validates every *.json file in dir. An empty or
+// missing directory gives an empty registry.
+func loadCurricula(dir string) (*CurriculumRegistry, error) {
+	reg := newCurriculumRegistry()
+	if dir == "" {
+		return reg, nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		if _, statErr := os.Stat(dir); statErr != nil {
+			return reg, nil
+		}
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		c := &Curriculum{file: file}
+		if err := readJSONFile(file, c); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if err := c.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if other, ok := reg.byKey[c.key()]; ok {
+			return nil, fmt.Errorf("%s: %s is already defined in %s", file, c, other.file)
+		}
+		reg.byKey[c.key()] = c
+		if c.Default {
+			uni := strings.ToUpper(c.UniversityCode)
+			if other, ok := reg.defaults[uni]; ok {
+				return nil, fmt.Errorf("%s: %s already has a default curriculum in %s", file, c.UniversityCode, other.file)
+			}
+			reg.defaults[uni] = c
+		}
+		reg.Curricula = append(reg.Curricula, c)
+	}
+	return reg, nil
+}
+
+// Lookup finds the curriculum for a student, falling back to the
+// university's default.
+func (r *CurriculumRegistry) Lookup(universityCode string, version int) (*Curriculum, bool) {
+	if r == nil {
+		return nil, false
+	}
+	key := fmt.Sprintf("%s|%d", strings.ToUpper(universityCode), version)
+	if c, ok := r.byKey[key]; ok {
+		return c, true
+	}
+	c, ok := r.defaults[strings.ToUpper(universityCode)]
+	return c, ok
+}
+
+var (
+	curriculaMu sync.Mutex
+	curricula   = make(map[string]*CurriculumRegistry)
+)
+
+// curriculaFor loads the curricula in dir once. An invalid directory is
+// logged and treated as empty; runServer refuses to start on one instead.
+func curriculaFor(dir string) *CurriculumRegistry {
+	curriculaMu.Lock()
+	defer curriculaMu.Unlock()
+
+	if reg, ok := curricula[dir]; ok {
+		return reg
+	}
+	reg, err := loadCurricula(dir)
+	if err != nil {
+		log.Printf("(!) WARNING: Curriculum definitions not used: %v", err)
+		reg = newCurriculumRegistry()
+	}
+	curricula[dir] = reg
+	return reg
+}
+
+// areaFor names the elective pool or distribution area a course counts
+// towards, with its credit minimum. Pools list their courses explicitly, so
+// they are tried first.
+func (r *CurriculumRequirements) areaFor(course Course) (string, float64, bool) {
+	for _, p := range r.ElectivePools {
+		if matchesCourseCode(course, p.Courses) {
+			return p.Name, p.MinCredits, true
+		}
+	}
+	code := normalizeCode(course.CourseCode)
+	for _, a := range r.DistributionAreas {
+		for _, prefix := range a.Prefixes {
+			if strings.HasPrefix(code, normalizeCode(prefix)) {
+				return a.Name, a.MinCredits, true
+			}
+		}
+		if inPreferredSubdomain(course, a.Subdomains) {
+			return a.Name, a.MinCredits, true
+		}
+	}
+	return "", 0, false
+}
+
+// equivalents returns code and every code in its equivalence group.
+func (r *CurriculumRequirements) equivalents(code string) []string {
+	norm := normalizeCode(code)
+	for _, group := range r.EquivalenceGroups {
+		for _, member := range group {
+			if normalizeCode(member) == norm {
+				return group
+			}
+		}
+	}
+	return []string{code}
+}
+
+// satisfies reports whether course fulfils the required code, directly, by
+// the competencies it teaches or through an equivalence group.
+func (r *CurriculumRequirements) satisfies(course Course, required string) bool {
+	if contains(course.TeachesCompetencies, required) {
+		return true
+	}
+	for _, code := range r.equivalents(required) {
+		if matchesCourseCode(course, []string{code}) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordPlanned counts a planned course's credits towards its area, so later
+// semesters of a roadmap see the gap close.
+func (r *CurriculumRequirements) recordPlanned(course Course) {
+	if name, _, ok := r.areaFor(course); ok {
+		r.AreaCredits[name] += course.CreditHours
+	}
+}
+
+// newCurriculumRequirements derives what the student still has to complete.
+// With a curriculum definition, its required courses (less those completed
+// directly or through an equivalent, and counting the codes of one
+// equivalence group once), areas and total are used; otherwise
+// the student's graduation status. credits, by normalised code, gives the
+// credits of the elective pool courses the student has completed.
+func newCurriculumRequirements(profile *StudentProfile, curriculum *Curriculum, history *ResolvedHistory, credits map[string]float64) *CurriculumRequirements {
+	reqs := &CurriculumRequirements{
+		CurriculumVersion:        profile.CurriculumVersion,
+		RequiredCompetencies:     profile.RequiredCompetencies,
+		DistributionRequirements: make(map[string]float64),
+		TotalCreditsRequired:     float64(profile.TotalCredits.Required),
+		AreaCredits:              make(map[string]float64),
+	}
+	if curriculum == nil {
+		if reqs.TotalCreditsRequired <= 0 {
+			reqs.TotalCreditsRequired = defaultTotalCredits
+		}
+		return reqs
+	}
+
+	reqs.Curriculum = curriculum.String()
+	reqs.TotalCreditsRequired = curriculum.TotalCredits
+	reqs.DistributionAreas = curriculum.DistributionAreas
+	reqs.ElectivePools = curriculum.ElectivePools
+	reqs.EquivalenceGroups = curriculum.EquivalenceGroups
+
+	if len(curriculum.RequiredCourses) > 0 {
+		outstanding := []string{}
+		listed := make(map[string]bool)
+		for _, code := range curriculum.RequiredCourses {
+			group := reqs.equivalents(code)
+			if listed[normalizeCode(group[0])] {
+				continue
+			}
+			listed[normalizeCode(group[0])] = true
+			if !slices.ContainsFunc(group, history.HasCode) {
+				outstanding = append(outstanding, code)
+			}
+		}
+		reqs.RequiredCompetencies = outstanding
+	}
+
+	for _, a := range curriculum.DistributionAreas {
+		reqs.DistributionRequirements[a.Name] = a.MinCredits
+		for key, credits := range profile.DistributionCredits {
+			if containsFold(a.Prefixes, key) || containsFold(a.Subdomains, key) || strings.EqualFold(a.Name, key) {
+				reqs.AreaCredits[a.Name] += float64(credits.Earned)
+			}
+		}
+	}
+	for _, p := range curriculum.ElectivePools {
+		reqs.DistributionRequirements[p.Name] = p.MinCredits
+		for _, code := range p.Courses {
+			if history.HasCode(code) {
+				reqs.AreaCredits[p.Name] += credits[normalizeCode(code)]
+			}
+		}
+	}
+	return reqs
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	courseCreditsMu sync.Mutex
+	courseCredits   = make(map[string]map[string]float64)
+)
+
+// courseCreditsFor loads the credits of every course in competency_data of
+// dbPath once, by normalised code, so completed courses count even when the
+// semester's catalog does not offer them. A missing database gives none.
+func courseCreditsFor(dbPath string) map[string]float64 {
+	courseCreditsMu.Lock()
+	defer courseCreditsMu.Unlock()
+
+	if c, ok := courseCredits[dbPath]; ok {
+		return c
+	}
+	credits := make(map[string]float64)
+	if _, err := os.Stat(dbPath); err == nil {
+		meta, err := loadCompetencyMetaFile(dbPath)
+		if err != nil {
+			log.Printf("(!) WARNING: Course credits unavailable: %v", err)
+		}
+		for code, m := range meta {
+			credits[normalizeCode(code)] = float64(m.Credits)
+		}
+	}
+	courseCredits[dbPath] = credits
+	return credits
+}
+
+func loadCompetencyMetaFile(dbPath string) (map[string]CompetencyMeta, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", dbPath, err)
+	}
+	defer db.Close()
+	return loadCompetencyMeta(db)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShippedCurriculumAgreesWithRules(t *testing.T) {
+	reg, err := loadCurricula("curricula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, st := loadCurriculumRules("curriculum_rules.json", NewPrereqGraph())
+	if st.Error != "" {
+		t.Fatal(st.Error)
+	}
+	for _, c := range reg.Curricula {
+		if missing := c.missingRequired(rules); len(missing) > 0 {
+			t.Errorf("%s does not require %v", c, missing)
+		}
+	}
+}
+
+func TestCurriculumMissingRequired(t *testing.T) {
+	c := &Curriculum{
+		RequiredCourses:   []string{"AIC-101"},
+		EquivalenceGroups: [][]string{{"AIC-101", "SEN-101"}},
+	}
+	rules := map[string]bool{"AIC101": true, "SEN101": true, "MAT101": true, "COM100": false}
+	if got, want := c.missingRequired(rules), []string{"MAT101"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("missing = %v, want %v", got, want)
+	}
+}
+
+func TestElectivePoolCountsCoursesOutsideTheCatalog(t *testing.T) {
+	c := &Curriculum{
+		UniversityCode: "TST",
+		TotalCredits:   120,
+		ElectivePools:  []ElectivePool{{Name: "Electives", Courses: []string{"TST-301", "TST-302", "TST-303"}, MinCredits: 12}},
+	}
+	history := newResolvedHistory(NewEquivalenceResolver(nil, nil))
+	history.Add(HistoryRecord{CourseCode: "TST-301", Source: "profile"})
+	history.Add(HistoryRecord{CourseCode: "TST-302", Source: "profile"})
+	credits := map[string]float64{"TST301": 3, "TST302": 4, "TST303": 5}
+
+	reqs := newCurriculumRequirements(&StudentProfile{}, c, history, credits)
+	if got := reqs.AreaCredits["Electives"]; got != 7 {
+		t.Errorf("pool credits = %v, want 7", got)
+	}
+}
+
+func TestEquivalenceGroupIsOneRequirement(t *testing.T) {
+	c := &Curriculum{
+		UniversityCode:    "TST",
+		TotalCredits:      120,
+		RequiredCourses:   []string{"TST-101", "TST-102", "TST-201"},
+		EquivalenceGroups: [][]string{{"TST-101", "TST-102"}},
+	}
+	tests := []struct {
+		name  string
+		taken []string
+		want  []string
+	}{
+		{"neither taken", nil, []string{"TST-101", "TST-201"}},
+		{"one taken", []string{"TST-102"}, []string{"TST-201"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := newResolvedHistory(NewEquivalenceResolver(nil, nil))
+			for _, code := range tt.taken {
+				history.Add(HistoryRecord{CourseCode: code, Source: "profile"})
+			}
+			reqs := newCurriculumRequirements(&StudentProfile{}, c, history, nil)
+			if !reflect.DeepEqual(reqs.RequiredCompetencies, tt.want) {
+				t.Errorf("outstanding = %v, want %v", reqs.RequiredCompetencies, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// StudentDataSource provides a student's identity, history and graduation
+// status in A1CE's shapes. A student the source does not know is reported as
+// an error wrapping ErrNotFound.
+type StudentDataSource interface {
+	GetStudentProfile(ctx context.Context, studentID string) (*StudentProfile, error)
+	GetSemesterCompetencies(ctx context.Context, studentID, semester string) ([]A1CECompetencyCard, error)
+	GetGraduationStatus(ctx context.Context, studentID string) (*A1CEGraduationStatus, error)
+}
+
+// CatalogSource provides the courses offered for a semester.
+type CatalogSource interface {
+	GetCourseCatalog(ctx context.Context, semester string, curriculumVersion int) (*CourseCatalogResponse, error)
+}
+
+// DataSource is everything the recommender needs to read.
+type DataSource interface {
+	StudentDataSource
+	CatalogSource
+}
+
+var (
+	_ DataSource = (*A1CEClient)(nil)
+	_ DataSource = (*SQLiteDataSource)(nil)
+	_ DataSource = (*FixtureDataSource)(nil)
+	_ DataSource = (*profileSource)(nil)
+)
+
+const (
+	DataSourceA1CE     = "a1ce"
+	DataSourceSQLite   = "sqlite"
+	DataSourceFixtures = "fixtures"
+)
+
+var (
+	sharedSourcesMu sync.Mutex
+	sharedSources   = make(map[string]DataSource)
+)
+
+// catalogCache, when set, fronts every data source's GetCourseCatalog.
+var catalogCache *CatalogCache
+
+// NewDataSource returns the backend selected by cfg. The A1CE backend is built
+// per caller because it forwards their JWT; the offline backends are opened
+// once and shared.
+func NewDataSource(cfg *Config, jwtToken string) (DataSource, error) {
+	source, universityCode, err := openDataSource(cfg, jwtToken)
+	if err != nil {
+		return nil, err
+	}
+	if catalogCache == nil {
+		return source, nil
+	}
+	return &cachedDataSource{DataSource: source, cache: catalogCache, universityCode: universityCode}, nil
+}
+
+// openDataSource also returns how to read the university code the backend
+// will scope catalog requests to, which is part of the cache key.
+func openDataSource(cfg *Config, jwtToken string) (DataSource, func() string, error) {
+	kind := cfg.DataSource
+	if cfg.UseMockData {
+		kind = DataSourceFixtures
+	}
+
+	switch kind {
+	case "", DataSourceA1CE:
+		client := NewA1CEClient(cfg.A1CEBaseURL)
+		client.JWTToken = jwtToken
+		client.UniversityCode = cfg.UniversityCode
+		client.CatalogConcurrency = cfg.CatalogConcurrency
+		client.SubdomainTimeout = cfg.SubdomainTimeout
+		client.Retry.MaxRetries = cfg.A1CEMaxRetries
+		client.Retry.BaseDelay = cfg.A1CERetryBaseDelay
+		client.Breaker = breakerFor(client.BaseURL, cfg.BreakerThreshold, cfg.BreakerCooldown)
+		return client, func() string { return client.UniversityCode }, nil
+	case DataSourceSQLite:
+		source, err := sharedSource(kind+":"+cfg.DatabasePath, func() (DataSource, error) {
+			return NewSQLiteDataSource(cfg.DatabasePath)
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return source, func() string { return source.(*SQLiteDataSource).UniversityCode }, nil
+	case DataSourceFixtures:
+		source, err := sharedSource(kind+":"+cfg.FixtureDir, func() (DataSource, error) {
+			return NewFixtureDataSource(cfg.FixtureDir)
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return source, func() string { return cfg.UniversityCode }, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown data source %q", kind)
+	}
+}
+
+func sharedSource(key string, open func() (DataSource, error)) (DataSource, error) {
+	sharedSourcesMu.Lock()
+	defer sharedSourcesMu.Unlock()
+
+	if src, ok := sharedSources[key]; ok {
+		return src, nil
+	}
+	src, err := open()
+	if err != nil {
+		return nil, err
+	}
+	sharedSources[key] = src
+	return src, nil
+}
+
+// Record notes whether part of a profile loaded; a part recorded twice keeps
+// its first failure.
+func (c *ProfileCompleteness) Record(part string, err error) {
+	entry := ProfilePart{Name: part, Loaded: err == nil}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	found := false
+	for i := range c.Parts {
+		if c.Parts[i].Name == part {
+			if c.Parts[i].Loaded {
+				c.Parts[i] = entry
+			}
+			found = true
+		}
+	}
+	if !found {
+		c.Parts = append(c.Parts, entry)
+	}
+
+	c.Complete = true
+	for _, p := range c.Parts {
+		c.Complete = c.Complete && p.Loaded
+	}
+}
+
+// Failed returns the parts that did not load.
+func (c *ProfileCompleteness) Failed() []ProfilePart {
+	var failed []ProfilePart
+	for _, p := range c.Parts {
+		if !p.Loaded {
+			failed = append(failed, p)
+		}
+	}
+	return failed
+}
+
+// Missing reports whether part was attempted and failed.
+func (c *ProfileCompleteness) Missing(part string) bool {
+	for _, p := range c.Parts {
+		if p.Name == part {
+			return !p.Loaded
+		}
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ComparisonReport holds the evaluation of several strategies on the same
+// split and the paired tests between each pair of them. Under k-fold
+// cross-validation each report pools the folds, its aggregate is the mean
+// across folds and Variance holds each strategy's variance across folds.
+type ComparisonReport struct {
+	GeneratedAt time.Time          `json:"generated_at"`
+	K           int                `json:"k"`
+	Split       string             `json:"split"`
+	Folds       int                `json:"folds,omitempty"`
+	Algorithms  []string           `json:"algorithms"`
+	Reports     []EvaluationReport `json:"reports"`
+	Variance    []AggregateMetrics `json:"variance,omitempty"`
+	Tests       []PairedTest       `json:"tests"`
+}
+
+// PairedTest is a Wilcoxon signed-rank test of one per-student metric
+// between strategies A and B. Pairs counts the students whose values differ;
+// MeanDiff is the mean of B - A over all students.
+type PairedTest struct {
+	Metric   string  `json:"metric"`
+	A        string  `json:"a"`
+	B        string  `json:"b"`
+	MeanDiff float64 `json:"mean_diff"`
+	Pairs    int     `json:"pairs"`
+	W        float64 `json:"w_plus"`
+	Z        float64 `json:"z"`
+	PValue   float64 `json:"p_value"`
+}
+
+// significanceLevel marks a test as significant in the text report.
+const significanceLevel = 0.05
+
+// pairedMetrics are the per-student metrics the strategies are tested on.
+var pairedMetrics = []struct {
+	name  string
+	value func(m RankingMetrics) float64
+}{
+	{"precision@k", func(m RankingMetrics) float64 { return m.PrecisionAtK }},
+	{"recall@k", func(m RankingMetrics) float64 { return m.RecallAtK }},
+	{"ndcg@k", func(m RankingMetrics) float64 { return m.NDCGAtK }},
+	{"average_precision", func(m RankingMetrics) float64 { return m.AveragePrecision }},
+	{"reciprocal_rank", func(m RankingMetrics) float64 { return m.ReciprocalRank }},
+}
+
+// CompareRecommenders evaluates each named strategy on the same students,
+// profiles and held-out courses, prints a metrics table with a column per
+// strategy, and tests every pair of strategies per student.
+func CompareRecommenders(cfg *Config, names []string) error {
+	if len(names) < 2 {
+		return fmt.Errorf("-compare needs at least two recommenders; got %d", len(names))
+	}
+	for _, name := range names {
+		if _, ok := recommenderRegistry[name]; !ok {
+			return fmt.Errorf("%w %q (expected one of %s)", ErrUnknownRecommender, name, strings.Join(RecommenderNames(), ", "))
+		}
+	}
+
+	if err := redirectLogsToReport(cfg.EvalReportPath); err != nil {
+		return err
+	}
+
+	data, err := loadEvalData(cfg)
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+
+	comparison := ComparisonReport{GeneratedAt: time.Now(), K: cfg.EvalK, Split: data.splitLabel(), Algorithms: names, Tests: []PairedTest{}}
+	if len(data.splits) > 1 {
+		comparison.Folds = len(data.splits)
+	}
+	for _, name := range names {
+		log.Printf("=== %s ===\n", name)
+		folds, err := data.evaluateSplits(name, name)
+		if err != nil {
+			return err
+		}
+		if comparison.Folds == 0 {
+			logStudentMetrics(folds[0])
+			comparison.Reports = append(comparison.Reports, folds[0])
+			continue
+		}
+		cv := crossValidate(folds, cfg.EvalSeed)
+		for _, f := range folds {
+			log.Printf("--- %s ---\n", f.Split)
+			logStudentMetrics(f)
+		}
+		comparison.Reports = append(comparison.Reports, cv.pooled())
+		comparison.Variance = append(comparison.Variance, cv.Variance)
+	}
+
+	for i := range comparison.Reports {
+		for j := i + 1; j < len(comparison.Reports); j++ {
+			a, b := comparison.Reports[i], comparison.Reports[j]
+			for _, metric := range pairedMetrics {
+				comparison.Tests = append(comparison.Tests, pairedTest(metric.name, a, b, metric.value))
+			}
+		}
+	}
+	logComparison(comparison)
+
+	if err := writeJSONFileAtomic(cfg.EvalJSONReportPath, comparison); err != nil {
+		return fmt.Errorf("write JSON report: %w", err)
+	}
+	fmt.Printf("Report written to %s and %s\n", cfg.EvalReportPath, cfg.EvalJSONReportPath)
+	return nil
+}
+
+// pairedTest pairs the two reports' students, which evaluateRankings lists
+// in the same order for the same truth. Pooled folds pair each student of
+// each fold.
+func pairedTest(metric string, a, b EvaluationReport, value func(m RankingMetrics) float64) PairedTest {
+	xs := make([]float64, len(a.Students))
+	ys := make([]float64, len(b.Students))
+	for i := range a.Students {
+		xs[i] = value(a.Students[i].Metrics)
+		ys[i] = value(b.Students[i].Metrics)
+	}
+	t := wilcoxonSignedRank(xs, ys)
+	t.Metric, t.A, t.B = metric, a.Algorithm, b.Algorithm
+	return t
+}
+
+// wilcoxonSignedRank tests whether ys tends to differ from xs, pair by
+// pair. Zero differences are dropped, tied differences share their mean
+// rank, and the p-value is two-sided from the normal approximation with tie
+// and continuity corrections.
+func wilcoxonSignedRank(xs, ys []float64) PairedTest {
+	var t PairedTest
+	type diff struct{ abs, sign float64 }
+	var diffs []diff
+	for i := range xs {
+		d := ys[i] - xs[i]
+		t.MeanDiff += d
+		if math.Abs(d) > 1e-12 {
+			diffs = append(diffs, diff{math.Abs(d), math.Copysign(1, d)})
+		}
+	}
+	if len(xs) > 0 {
+		t.MeanDiff /= float64(len(xs))
+	}
+	t.Pairs = len(diffs)
+	t.PValue = 1
+	if t.Pairs == 0 {
+		return t
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].abs < diffs[j].abs })
+	n := float64(len(diffs))
+	tieCorrection := 0.0
+	for i := 0; i < len(diffs); {
+		j := i
+		for j+1 < len(diffs) && diffs[j+1].abs-diffs[i].abs <= 1e-12 {
+			j++
+		}
+		rank := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			if diffs[k].sign > 0 {
+				t.W += rank
+			}
+		}
+		ties := float64(j - i + 1)
+		tieCorrection += ties*ties*ties - ties
+		i = j + 1
+	}
+
+	mean := n * (n + 1) / 4
+	variance := n*(n+1)*(2*n+1)/24 - tieCorrection/48
+	if variance <= 0 {
+		return t
+	}
+	dev := t.W - mean
+	switch {
+	case dev > 0.5:
+		dev -= 0.5
+	case dev < -0.5:
+		dev += 0.5
+	default:
+		dev = 0
+	}
+	t.Z = dev / math.Sqrt(variance)
+	t.PValue = math.Erfc(math.Abs(t.Z) / math.Sqrt2)
+	return t
+}
+
+// logComparison writes the metrics table and the tests to the text report
+// and the terminal.
+func logComparison(c ComparisonReport) {
+	printBoth := func(format string, args ...interface{}) {
+		log.Printf(format, args...)
+		fmt.Printf(format, args...)
+	}
+
+	rows := make([][][2]string, len(c.Reports))
+	header := fmt.Sprintf("%-22s", "Metric")
+	for i, r := range c.Reports {
+		rows[i] = r.Aggregate.metricRows(c.K)
+		header += fmt.Sprintf(" %20s", r.Algorithm)
+	}
+	printBoth("Split: %s\n", c.Split)
+	if c.Folds > 0 {
+		printBoth("Mean across %d folds:\n", c.Folds)
+	}
+	printBoth("%s\n", header)
+	printBoth("%-22s%s\n", "Students", studentsRow(c.Reports))
+	for m := range rows[0] {
+		line := fmt.Sprintf("%-22s", rows[0][m][0])
+		for i := range rows {
+			line += fmt.Sprintf(" %20s", rows[i][m][1])
+		}
+		printBoth("%s\n", line)
+	}
+	if len(c.Variance) > 0 {
+		printBoth("\nVariance across %d folds:\n", c.Folds)
+		for m, row := range rows[0] {
+			line := fmt.Sprintf("%-22s", row[0])
+			for i := range c.Variance {
+				line += fmt.Sprintf(" %20.6f", *c.Variance[i].fields()[m])
+			}
+			printBoth("%s\n", line)
+		}
+	}
+
+	printBoth("\nPaired Wilcoxon signed-rank tests (B - A, * p < %.2f):\n", significanceLevel)
+	for _, t := range c.Tests {
+		mark := ""
+		if t.PValue < significanceLevel {
+			mark = " *"
+		}
+		printBoth("%-20s %-20s %-18s diff=%+.4f  n=%-4d z=%+.3f  p=%.4f%s\n",
+			t.A, t.B, t.Metric, t.MeanDiff, t.Pairs, t.Z, t.PValue, mark)
+	}
+}
+
+func studentsRow(reports []EvaluationReport) string {
+	var b strings.Builder
+	for _, r := range reports {
+		fmt.Fprintf(&b, " %20d", r.Aggregate.Students)
+	}
+	return b.String()
+}
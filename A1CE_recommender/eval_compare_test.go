@@ -0,0 +1,50 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWilcoxonSignedRank(t *testing.T) {
+	// The before/after table of the Wikipedia article on the test (from
+	// Lowry). One difference is zero, leaving 9 pairs; the two differences
+	// of 5 tie at rank 1.5. W+ = 27 against a mean of 22.5, the tie
+	// correction takes (2^3-2)/48 off the variance 71.25, and the continuity
+	// correction takes 0.5 off the deviation: z = 4/sqrt(71.125).
+	before := []float64{110, 122, 125, 120, 140, 124, 123, 137, 135, 145}
+	after := []float64{125, 115, 130, 140, 140, 115, 140, 125, 140, 135}
+
+	got := wilcoxonSignedRank(before, after)
+	if got.Pairs != 9 || got.W != 27 {
+		t.Errorf("pairs = %d, W+ = %v, want 9 and 27", got.Pairs, got.W)
+	}
+	if math.Abs(got.MeanDiff-2.4) > 1e-9 {
+		t.Errorf("mean difference = %v, want 2.4", got.MeanDiff)
+	}
+	if want := 4 / math.Sqrt(71.125); math.Abs(got.Z-want) > 1e-9 {
+		t.Errorf("z = %v, want %v", got.Z, want)
+	}
+	if math.Abs(got.PValue-0.63529) > 1e-5 {
+		t.Errorf("p = %v, want 0.63529", got.PValue)
+	}
+
+	// Swapping the samples mirrors z and keeps the two-sided p-value.
+	swapped := wilcoxonSignedRank(after, before)
+	if swapped.W != 18 || math.Abs(swapped.Z+got.Z) > 1e-9 || math.Abs(swapped.PValue-got.PValue) > 1e-12 {
+		t.Errorf("swapped: W+ = %v, z = %v, p = %v", swapped.W, swapped.Z, swapped.PValue)
+	}
+}
+
+func TestWilcoxonSignedRankWithoutDifferences(t *testing.T) {
+	xs := []float64{0.2, 0.5, 0.5, 1}
+	got := wilcoxonSignedRank(xs, xs)
+	if got.Pairs != 0 || got.PValue != 1 || got.Z != 0 || got.MeanDiff != 0 {
+		t.Errorf("identical samples: %+v, want no pairs and p = 1", got)
+	}
+
+	// W+ = 1 is within the continuity correction of its mean 1.5.
+	got = wilcoxonSignedRank([]float64{0, 0}, []float64{1, -2})
+	if got.Pairs != 2 || got.PValue != 1 {
+		t.Errorf("balanced differences: %+v, want 2 pairs and p = 1", got)
+	}
+}
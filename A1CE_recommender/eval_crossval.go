@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// CrossValidationReport is one strategy evaluated on every fold, with the
+// mean and the sample variance of each aggregate metric across folds.
+// Students in Mean is the mean number of students per fold.
+type CrossValidationReport struct {
+	GeneratedAt time.Time          `json:"generated_at"`
+	Algorithm   string             `json:"algorithm"`
+	K           int                `json:"k"`
+	Seed        int64              `json:"seed"`
+	Mean        AggregateMetrics   `json:"mean"`
+	Variance    AggregateMetrics   `json:"variance"`
+	Folds       []EvaluationReport `json:"folds"`
+}
+
+// crossValidate summarises the per-fold reports of one strategy.
+func crossValidate(folds []EvaluationReport, seed int64) CrossValidationReport {
+	cv := CrossValidationReport{GeneratedAt: time.Now(), Seed: seed, Folds: folds}
+	if len(folds) == 0 {
+		return cv
+	}
+	cv.Algorithm, cv.K = folds[0].Algorithm, folds[0].K
+
+	aggs := make([]AggregateMetrics, len(folds))
+	students := 0
+	for i, f := range folds {
+		aggs[i] = f.Aggregate
+		students += f.Aggregate.Students
+	}
+	cv.Mean.Students = int(math.Round(float64(students) / float64(len(folds))))
+
+	means, variances := cv.Mean.fields(), cv.Variance.fields()
+	n := float64(len(aggs))
+	for m := range means {
+		for i := range aggs {
+			*means[m] += *aggs[i].fields()[m]
+		}
+		*means[m] /= n
+		if len(aggs) < 2 {
+			continue
+		}
+		for i := range aggs {
+			d := *aggs[i].fields()[m] - *means[m]
+			*variances[m] += d * d
+		}
+		*variances[m] /= n - 1
+	}
+	return cv
+}
+
+// fields points at the averaged metrics, in metricRows order with the
+// whole-list recall last.
+func (a *AggregateMetrics) fields() []*float64 {
+	return []*float64{
+		&a.PrecisionAtK, &a.RecallAtK, &a.F1AtK, &a.NDCGAtK, &a.MAP, &a.MRR,
+		&a.HitRate, &a.CatalogCoverage, &a.IntraListDiversity, &a.Novelty, &a.Recall,
+	}
+}
+
+// pooled merges the folds into one report for paired tests: every fold's
+// students in fold order, tagged with their fold, and the mean aggregate.
+func (cv CrossValidationReport) pooled() EvaluationReport {
+	report := EvaluationReport{
+		GeneratedAt: cv.GeneratedAt,
+		Algorithm:   cv.Algorithm,
+		K:           cv.K,
+		Split:       EvalSplitKFold,
+		Aggregate:   cv.Mean,
+		Students:    []StudentEvaluation{},
+	}
+	for i, f := range cv.Folds {
+		for _, s := range f.Students {
+			s.Fold = i + 1
+			report.Students = append(report.Students, s)
+		}
+	}
+	return report
+}
+
+// logCrossValidation writes each fold's metrics to the text report, and the
+// per-fold aggregates with their mean and variance to the report and the
+// terminal.
+func logCrossValidation(cv CrossValidationReport) {
+	printBoth := func(format string, args ...interface{}) {
+		log.Printf(format, args...)
+		fmt.Printf(format, args...)
+	}
+
+	for _, f := range cv.Folds {
+		log.Printf("=== %s ===\n", f.Split)
+		logStudentMetrics(f)
+	}
+
+	printBoth("%d-fold cross-validation of %s (seed %d)\n", len(cv.Folds), cv.Algorithm, cv.Seed)
+	for _, f := range cv.Folds {
+		a := f.Aggregate
+		printBoth("%-10s students=%-4d P@%d=%.4f R@%d=%.4f nDCG@%d=%.4f MAP=%.4f MRR=%.4f\n",
+			f.Split, a.Students, cv.K, a.PrecisionAtK, cv.K, a.RecallAtK, cv.K, a.NDCGAtK, a.MAP, a.MRR)
+	}
+	printBoth("%-22s %12s %12s\n", "Metric", "Mean", "Variance")
+	variances := cv.Variance.fields()
+	for i, row := range cv.Mean.metricRows(cv.K) {
+		printBoth("%-22s %12s %12.6f\n", row[0], row[1], *variances[i])
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RankingMetrics are the quality measures of one student's ranked list
+// against the courses they went on to take. The @k measures look at the
+// first k recommendations.
+type RankingMetrics struct {
+	PrecisionAtK       float64 `json:"precision_at_k"`
+	RecallAtK          float64 `json:"recall_at_k"`
+	F1AtK              float64 `json:"f1_at_k"`
+	NDCGAtK            float64 `json:"ndcg_at_k"`
+	AveragePrecision   float64 `json:"average_precision"`
+	ReciprocalRank     float64 `json:"reciprocal_rank"`
+	Hit                float64 `json:"hit"`
+	Recall             float64 `json:"recall"` // over the whole list; the old "accuracy"
+	IntraListDiversity float64 `json:"intra_list_diversity"`
+	Novelty            float64 `json:"novelty"`
+}
+
+// StudentEvaluation is one student's list, truth and metrics.
+type StudentEvaluation struct {
+	StudentID   string         `json:"student_id"`
+	Fold        int            `json:"fold,omitempty"` // when folds are pooled
+	Recommended []string       `json:"recommended"`
+	Relevant    []string       `json:"relevant"`
+	Correct     int            `json:"correct"`
+	Metrics     RankingMetrics `json:"metrics"`
+}
+
+// AggregateMetrics averages the per-student metrics. MAP and MRR are the
+// means of average precision and reciprocal rank, and hit rate the share of
+// students with a hit in the first k.
+type AggregateMetrics struct {
+	Students           int     `json:"students"`
+	PrecisionAtK       float64 `json:"precision_at_k"`
+	RecallAtK          float64 `json:"recall_at_k"`
+	F1AtK              float64 `json:"f1_at_k"`
+	NDCGAtK            float64 `json:"ndcg_at_k"`
+	MAP                float64 `json:"map"`
+	MRR                float64 `json:"mrr"`
+	HitRate            float64 `json:"hit_rate"`
+	Recall             float64 `json:"recall"`
+	CatalogCoverage    float64 `json:"catalog_coverage"`
+	IntraListDiversity float64 `json:"intra_list_diversity"`
+	Novelty            float64 `json:"novelty"`
+}
+
+// EvaluationReport is the machine-readable evaluation result.
+type EvaluationReport struct {
+	GeneratedAt time.Time           `json:"generated_at"`
+	Algorithm   string              `json:"algorithm"`
+	K           int                 `json:"k"`
+	Split       string              `json:"split,omitempty"`
+	Aggregate   AggregateMetrics    `json:"aggregate"`
+	Students    []StudentEvaluation `json:"students"`
+}
+
+// rankingContext holds what the beyond-accuracy metrics need: content
+// similarity for diversity, how many training students took each course for
+// novelty, and the catalog size for coverage.
+type rankingContext struct {
+	k           int
+	sim         map[string]map[string]float64
+	popularity  map[string]int
+	students    int
+	catalogSize int
+}
+
+// newRankingContext counts course popularity over the training rows.
+func newRankingContext(k int, sim map[string]map[string]float64, train []TrainRow, catalogSize int) rankingContext {
+	ctx := rankingContext{k: k, sim: sim, popularity: make(map[string]int), catalogSize: catalogSize}
+	seen := make(map[string]bool)
+	students := make(map[string]bool)
+	for _, r := range train {
+		students[r.StudentID] = true
+		key := r.StudentID + "|" + r.CompetencyCode
+		if !seen[key] {
+			seen[key] = true
+			ctx.popularity[r.CompetencyCode]++
+		}
+	}
+	ctx.students = len(students)
+	return ctx
+}
+
+// evaluateRankings scores every student in truth. Students without
+// recommendations count with an empty list.
+func evaluateRankings(algorithm string, recs, truth map[string][]string, ctx rankingContext) EvaluationReport {
+	report := EvaluationReport{GeneratedAt: time.Now(), Algorithm: algorithm, K: ctx.k, Students: []StudentEvaluation{}}
+
+	ids := make([]string, 0, len(truth))
+	for sid := range truth {
+		ids = append(ids, sid)
+	}
+	sort.Strings(ids)
+
+	recommended := make(map[string]bool)
+	for _, sid := range ids {
+		list := recs[sid]
+		m := ctx.metrics(list, truth[sid])
+		report.Students = append(report.Students, StudentEvaluation{
+			StudentID:   sid,
+			Recommended: list,
+			Relevant:    truth[sid],
+			Correct:     intersectionCount(truth[sid], list),
+			Metrics:     m,
+		})
+		for _, c := range list {
+			recommended[c] = true
+		}
+	}
+	report.Aggregate = aggregateMetrics(report.Students)
+	if ctx.catalogSize > 0 {
+		report.Aggregate.CatalogCoverage = float64(len(recommended)) / float64(ctx.catalogSize)
+	}
+	return report
+}
+
+// metrics computes one student's measures with binary relevance. A code
+// repeated in either list counts once.
+func (ctx rankingContext) metrics(list, relevant []string) RankingMetrics {
+	var m RankingMetrics
+	rel := make(map[string]bool)
+	for _, c := range relevant {
+		rel[strings.TrimSpace(c)] = true
+	}
+	if len(rel) == 0 {
+		return m
+	}
+
+	k := ctx.k
+	hits, dcg, precisionSum := 0, 0.0, 0.0
+	found := make(map[string]bool)
+	for i, c := range list {
+		c = strings.TrimSpace(c)
+		if !rel[c] || found[c] {
+			continue
+		}
+		found[c] = true
+		if i < k {
+			hits++
+			dcg += 1 / math.Log2(float64(i)+2)
+			precisionSum += float64(hits) / float64(i+1)
+			if m.ReciprocalRank == 0 {
+				m.ReciprocalRank = 1 / float64(i+1)
+			}
+		}
+	}
+	m.PrecisionAtK = float64(hits) / float64(k)
+	m.RecallAtK = float64(hits) / float64(len(rel))
+	if m.PrecisionAtK+m.RecallAtK > 0 {
+		m.F1AtK = 2 * m.PrecisionAtK * m.RecallAtK / (m.PrecisionAtK + m.RecallAtK)
+	}
+	idcg := 0.0
+	for i := 0; i < min(k, len(rel)); i++ {
+		idcg += 1 / math.Log2(float64(i)+2)
+	}
+	m.NDCGAtK = dcg / idcg
+	m.AveragePrecision = precisionSum / float64(min(k, len(rel)))
+	if hits > 0 {
+		m.Hit = 1
+	}
+	m.Recall = float64(intersectionCount(relevant, list)) / float64(len(rel))
+	m.IntraListDiversity = ctx.diversity(list)
+	m.Novelty = ctx.novelty(list)
+	return m
+}
+
+// diversity is the mean pairwise content dissimilarity (1 - similarity) of
+// the list. Pairs without a similarity score count as unrelated.
+func (ctx rankingContext) diversity(list []string) float64 {
+	if len(list) < 2 {
+		return 0
+	}
+	total, pairs := 0.0, 0
+	for i := range list {
+		for j := i + 1; j < len(list); j++ {
+			s, ok := ctx.sim[list[i]][list[j]]
+			if !ok {
+				s = ctx.sim[list[j]][list[i]]
+			}
+			total += 1 - s
+			pairs++
+		}
+	}
+	return total / float64(pairs)
+}
+
+// novelty is the mean self-information, -log2 of the share of training
+// students who took the course, with add-one smoothing so courses nobody
+// took are finite.
+func (ctx rankingContext) novelty(list []string) float64 {
+	if len(list) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, c := range list {
+		p := float64(ctx.popularity[c]+1) / float64(ctx.students+1)
+		total += -math.Log2(p)
+	}
+	return total / float64(len(list))
+}
+
+func aggregateMetrics(students []StudentEvaluation) AggregateMetrics {
+	agg := AggregateMetrics{Students: len(students)}
+	if len(students) == 0 {
+		return agg
+	}
+	for _, s := range students {
+		m := s.Metrics
+		agg.PrecisionAtK += m.PrecisionAtK
+		agg.RecallAtK += m.RecallAtK
+		agg.F1AtK += m.F1AtK
+		agg.NDCGAtK += m.NDCGAtK
+		agg.MAP += m.AveragePrecision
+		agg.MRR += m.ReciprocalRank
+		agg.HitRate += m.Hit
+		agg.Recall += m.Recall
+		agg.IntraListDiversity += m.IntraListDiversity
+		agg.Novelty += m.Novelty
+	}
+	n := float64(len(students))
+	agg.PrecisionAtK /= n
+	agg.RecallAtK /= n
+	agg.F1AtK /= n
+	agg.NDCGAtK /= n
+	agg.MAP /= n
+	agg.MRR /= n
+	agg.HitRate /= n
+	agg.Recall /= n
+	agg.IntraListDiversity /= n
+	agg.Novelty /= n
+	return agg
+}
+
+// metricRows lists the aggregate metrics for the text report, in order.
+func (a AggregateMetrics) metricRows(k int) [][2]string {
+	pct := func(v float64) string { return fmt.Sprintf("%.2f%%", v*100) }
+	return [][2]string{
+		{fmt.Sprintf("Precision@%d", k), pct(a.PrecisionAtK)},
+		{fmt.Sprintf("Recall@%d", k), pct(a.RecallAtK)},
+		{fmt.Sprintf("F1@%d", k), pct(a.F1AtK)},
+		{fmt.Sprintf("nDCG@%d", k), fmt.Sprintf("%.4f", a.NDCGAtK)},
+		{fmt.Sprintf("MAP@%d", k), fmt.Sprintf("%.4f", a.MAP)},
+		{fmt.Sprintf("MRR@%d", k), fmt.Sprintf("%.4f", a.MRR)},
+		{fmt.Sprintf("Hit rate@%d", k), pct(a.HitRate)},
+		{"Catalog coverage", pct(a.CatalogCoverage)},
+		{"Intra-list diversity", fmt.Sprintf("%.4f", a.IntraListDiversity)},
+		{"Novelty (bits)", fmt.Sprintf("%.4f", a.Novelty)},
+	}
+}
+
+// logEvaluationReport writes the per-student and aggregate metrics to the
+// text report and prints the aggregate to the terminal.
+func logEvaluationReport(report EvaluationReport) {
+	logStudentMetrics(report)
+
+	log.Printf("Split: %s\n", report.Split)
+	fmt.Printf("Split: %s\n", report.Split)
+	log.Printf("Average Recommendation Accuracy: %.2f%%\n", report.Aggregate.Recall*100)
+	fmt.Printf("Average Recommendation Accuracy: %.2f%%\n", report.Aggregate.Recall*100)
+	for _, row := range report.Aggregate.metricRows(report.K) {
+		log.Printf("%-22s %s\n", row[0]+":", row[1])
+		fmt.Printf("%-22s %s\n", row[0]+":", row[1])
+	}
+}
+
+// logStudentMetrics writes each student's metrics to the text report.
+func logStudentMetrics(report EvaluationReport) {
+	for _, s := range report.Students {
+		m := s.Metrics
+		log.Printf("Student %s: true=%d, recommended=%d, correct=%d, acc=%.2f, P@%d=%.2f, R@%d=%.2f, nDCG@%d=%.3f, AP=%.3f, RR=%.3f\n",
+			s.StudentID, len(s.Relevant), len(s.Recommended), s.Correct, m.Recall,
+			report.K, m.PrecisionAtK, report.K, m.RecallAtK, report.K, m.NDCGAtK, m.AveragePrecision, m.ReciprocalRank)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRankingMetrics(t *testing.T) {
+	// With k = 3 and two relevant courses the ideal DCG is 1 + 1/log2(3).
+	idcg2 := 1 + 1/math.Log2(3)
+	tests := []struct {
+		name           string
+		list, relevant []string
+		want           RankingMetrics
+	}{
+		{"hit at rank 1", []string{"A", "X", "Y"}, []string{"A", "B"}, RankingMetrics{
+			PrecisionAtK: 1.0 / 3, RecallAtK: 0.5, F1AtK: 0.4, NDCGAtK: 1 / idcg2,
+			AveragePrecision: 0.5, ReciprocalRank: 1, Hit: 1, Recall: 0.5,
+		}},
+		{"hit at rank k", []string{"X", "Y", "A"}, []string{"A", "B"}, RankingMetrics{
+			PrecisionAtK: 1.0 / 3, RecallAtK: 0.5, F1AtK: 0.4, NDCGAtK: 0.5 / idcg2,
+			AveragePrecision: 1.0 / 6, ReciprocalRank: 1.0 / 3, Hit: 1, Recall: 0.5,
+		}},
+		{"hit past k", []string{"X", "Y", "Z", "A"}, []string{"A"}, RankingMetrics{Recall: 1}},
+		{"list shorter than k", []string{"A"}, []string{"A"}, RankingMetrics{
+			PrecisionAtK: 1.0 / 3, RecallAtK: 1, F1AtK: 0.5, NDCGAtK: 1,
+			AveragePrecision: 1, ReciprocalRank: 1, Hit: 1, Recall: 1,
+		}},
+		{"no relevant course recommended", []string{"X", "Y", "Z"}, []string{"A"}, RankingMetrics{}},
+		{"nothing relevant", []string{"A", "B"}, nil, RankingMetrics{}},
+		{"duplicate codes", []string{"A", "A", "X"}, []string{"A", "A", "B"}, RankingMetrics{
+			PrecisionAtK: 1.0 / 3, RecallAtK: 0.5, F1AtK: 0.4, NDCGAtK: 1 / idcg2,
+			AveragePrecision: 0.5, ReciprocalRank: 1, Hit: 1, Recall: 0.5,
+		}},
+	}
+	ctx := rankingContext{k: 3}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ctx.metrics(tt.list, tt.relevant)
+			for _, f := range []struct {
+				name      string
+				got, want float64
+			}{
+				{"precision@k", got.PrecisionAtK, tt.want.PrecisionAtK},
+				{"recall@k", got.RecallAtK, tt.want.RecallAtK},
+				{"F1@k", got.F1AtK, tt.want.F1AtK},
+				{"nDCG@k", got.NDCGAtK, tt.want.NDCGAtK},
+				{"AP@k", got.AveragePrecision, tt.want.AveragePrecision},
+				{"reciprocal rank", got.ReciprocalRank, tt.want.ReciprocalRank},
+				{"hit", got.Hit, tt.want.Hit},
+				{"recall", got.Recall, tt.want.Recall},
+			} {
+				if math.Abs(f.got-f.want) > 1e-9 {
+					t.Errorf("%s = %v, want %v", f.name, f.got, f.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+)
+
+const (
+	EvalAlgorithmPipeline = "pipeline"
+	EvalAlgorithmLegacy   = "legacy"
+)
+
+// evalTrainTable holds the part of each student's record the recommender
+// sees during evaluation; student_test holds what they took afterwards.
+const evalTrainTable = "student_train"
+
+// evalRecommenderName maps eval_algorithm to a registered strategy: pipeline
+// is the fit-score recommender the server runs and legacy the notebook's
+// content-similarity algorithm.
+func evalRecommenderName(algorithm string) string {
+	switch algorithm {
+	case EvalAlgorithmPipeline:
+		return RecommenderFitScore
+	case EvalAlgorithmLegacy:
+		return RecommenderContentSimilarity
+	default:
+		return algorithm
+	}
+}
+
+// validEvalAlgorithm accepts the two aliases and every registered strategy.
+func validEvalAlgorithm(algorithm string) bool {
+	_, ok := recommenderRegistry[evalRecommenderName(algorithm)]
+	return ok
+}
+
+// evalData is everything an evaluation run scores strategies on: each
+// student's profile built from their training rows, the catalog, and the
+// courses each student took afterwards. splits holds every split eval_split
+// asks for; the data is that of the one named split.
+type evalData struct {
+	cfg      *Config
+	db       *sql.DB
+	source   *SQLiteDataSource
+	meta     map[string]CompetencyMeta
+	catalog  *CourseCatalogResponse
+	env      *RecommenderEnv
+	splits   []evalSplit
+	split    string
+	students []string
+	profiles map[string]*StudentProfile
+	truth    map[string][]string
+}
+
+// loadEvalData reads the splits of eval_split from database_path and uses
+// the first. Profiles are built the way the SQLite data source builds them
+// for data_source=sqlite.
+func loadEvalData(cfg *Config) (*evalData, error) {
+	db, err := sql.Open("sqlite3", cfg.DatabasePath)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite db: %w", err)
+	}
+	d := &evalData{cfg: cfg, db: db}
+	if err := d.load(); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *evalData) load() error {
+	var err error
+	d.meta, err = loadCompetencyMeta(d.db)
+	if err != nil {
+		return fmt.Errorf("load competency data: %w", err)
+	}
+
+	sim, err := loadSimilarityMatrix(d.db)
+	if err != nil {
+		log.Printf("warning: cannot load similarity matrix: %v (nearest neighbors empty)\n", err)
+		sim = make(map[string]map[string]float64)
+	}
+	d.env = &RecommenderEnv{
+		Config:     d.cfg,
+		Similarity: sim,
+		Prereqs:    prereqGraphFor(d.cfg.DatabasePath),
+		Seed:       d.cfg.EvalSeed,
+		Params:     d.cfg.SimilarityParams(),
+	}
+
+	d.source, err = NewSQLiteDataSource(d.cfg.DatabasePath)
+	if err != nil {
+		return err
+	}
+	d.catalog, err = d.source.GetCourseCatalog(context.Background(), "", 0)
+	if err != nil {
+		return fmt.Errorf("load course catalog: %w", err)
+	}
+
+	d.splits, err = loadEvalSplits(d.db, d.cfg)
+	if err != nil {
+		return err
+	}
+	d.useSplit(d.splits[0])
+	return nil
+}
+
+// splitLabel names the split eval_split made: the split's own name, which
+// says when temporal is a proxy, or eval_split for the folds.
+func (d *evalData) splitLabel() string {
+	if len(d.splits) > 1 {
+		return d.cfg.EvalSplit
+	}
+	return d.splits[0].Name
+}
+
+// withSplit returns the data for another split of the same database:
+// profiles from its training rows, evaluated against its truth.
+func (d *evalData) withSplit(s evalSplit) *evalData {
+	split := *d
+	env := *d.env
+	split.env = &env
+	split.useSplit(s)
+	return &split
+}
+
+func (d *evalData) useSplit(s evalSplit) {
+	d.env.Train = s.Train
+	d.split = s.Name
+	d.truth = s.Truth
+
+	byStudent := make(map[string][]TrainRow)
+	d.students = nil
+	for _, r := range s.Train {
+		if _, ok := byStudent[r.StudentID]; !ok {
+			d.students = append(d.students, r.StudentID)
+		}
+		byStudent[r.StudentID] = append(byStudent[r.StudentID], r)
+	}
+	sort.Strings(d.students)
+
+	d.profiles = make(map[string]*StudentProfile)
+	for _, studentID := range d.students {
+		profile, err := d.source.ProfileFromRows(studentID, byStudent[studentID])
+		if err != nil {
+			log.Printf("(!) WARNING: Student %s: %v\n", studentID, err)
+			continue
+		}
+		d.profiles[studentID] = profile
+	}
+}
+
+func (d *evalData) Close() error {
+	if d.source != nil {
+		d.source.Close()
+	}
+	return d.db.Close()
+}
+
+// recommendAll runs the named strategy for every student. A student whose
+// profile could not be loaded is evaluated with an empty list. Nothing is
+// stored in the recommendation history.
+func (d *evalData) recommendAll(name string) (map[string][]string, error) {
+	rec, err := NewRecommender(name, d.env)
+	if err != nil {
+		return nil, err
+	}
+	opts := RecommendOptions{MaxCreditLoad: float64(d.cfg.EvalMaxCreditLoad)}
+
+	out := make(map[string][]string)
+	for _, studentID := range d.students {
+		log.Printf("Processing student: %s\n", studentID)
+		out[studentID] = []string{}
+
+		profile, ok := d.profiles[studentID]
+		if !ok {
+			continue
+		}
+		for _, c := range rec.Recommend(profile, d.catalog, opts) {
+			out[studentID] = append(out[studentID], c.Course.CourseCode)
+		}
+	}
+	return out, nil
+}
+
+// evaluate scores recs against the held-out courses.
+func (d *evalData) evaluate(algorithm string, recs map[string][]string) EvaluationReport {
+	ctx := newRankingContext(d.cfg.EvalK, d.env.Similarity, d.env.Train, len(d.meta))
+	report := evaluateRankings(algorithm, recs, d.truth, ctx)
+	report.Split = d.split
+	return report
+}
+
+// evaluateSplits runs and scores the named strategy on every split, in
+// order, and reports algorithm as its name.
+func (d *evalData) evaluateSplits(algorithm, name string) ([]EvaluationReport, error) {
+	reports := make([]EvaluationReport, 0, len(d.splits))
+	for _, s := range d.splits {
+		data := d.withSplit(s)
+		recs, err := data.recommendAll(name)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, data.evaluate(algorithm, recs))
+	}
+	return reports, nil
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// How eval divides student records into what the recommender sees and what
+// it is scored against.
+const (
+	EvalSplitTables   = "tables"   // the student_train and student_test tables
+	EvalSplitRandom   = "random"   // random rows of each student
+	EvalSplitTemporal = "temporal" // each student's latest rows
+	EvalSplitKFold    = "kfold"    // k-fold cross-validation over each student's rows
+)
+
+// evalStudentTable holds the full records the generated splits come from.
+const evalStudentTable = "student"
+
+// evalTemporalProxy names a temporal split where some rows have no semester,
+// so every row is ordered by lessRecent's fallback instead.
+const evalTemporalProxy = "temporal (proxy: graded before in progress, then course level)"
+
+func validEvalSplit(split string) bool {
+	switch split {
+	case EvalSplitTables, EvalSplitRandom, EvalSplitTemporal, EvalSplitKFold:
+		return true
+	}
+	return false
+}
+
+// evalSplit is one division of the records: profiles are built from Train
+// and scored against Truth.
+type evalSplit struct {
+	Name  string
+	Train []TrainRow
+	Truth map[string][]string
+}
+
+// loadEvalSplits returns the split, or the folds, eval_split asks for.
+func loadEvalSplits(db *sql.DB, cfg *Config) ([]evalSplit, error) {
+	if cfg.EvalSplit == EvalSplitTables {
+		train, err := loadAllStudentTrain(db)
+		if err != nil {
+			return nil, fmt.Errorf("load %s: %w", evalTrainTable, err)
+		}
+		if len(train) == 0 {
+			return nil, fmt.Errorf("no data in %s", evalTrainTable)
+		}
+		truth, err := loadStudentTestTruth(db)
+		if err != nil {
+			return nil, err
+		}
+		return []evalSplit{{Name: EvalSplitTables, Train: train, Truth: truth}}, nil
+	}
+
+	rows, err := loadStudentRows(db, evalStudentTable)
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", evalStudentTable, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no data in %s", evalStudentTable)
+	}
+
+	switch cfg.EvalSplit {
+	case EvalSplitRandom:
+		train, truth := holdoutSplit(rows, cfg.EvalTestFraction, cfg.EvalSeed)
+		return []evalSplit{{Name: EvalSplitRandom, Train: train, Truth: truth}}, nil
+	case EvalSplitTemporal:
+		name := temporalSplitName(rows)
+		if name != EvalSplitTemporal {
+			log.Printf("(!) WARNING: Table %s has rows without a semester; eval_split=temporal orders them graded before in progress, then by course level, which only approximates when they were taken", evalStudentTable)
+		}
+		train, truth := temporalSplit(rows, cfg.EvalTestFraction)
+		return []evalSplit{{Name: name, Train: train, Truth: truth}}, nil
+	case EvalSplitKFold:
+		return kFoldSplits(rows, cfg.EvalFolds, cfg.EvalSeed), nil
+	default:
+		return nil, fmt.Errorf("unknown eval_split %q", cfg.EvalSplit)
+	}
+}
+
+// validationSplit holds out eval_validation_fraction of the training rows
+// the way the test rows were held out: the latest rows under temporal, random
+// rows otherwise.
+func validationSplit(cfg *Config, train []TrainRow) evalSplit {
+	if cfg.EvalSplit == EvalSplitTemporal {
+		train, truth := temporalSplit(train, cfg.EvalValidationFraction)
+		return evalSplit{Name: "validation", Train: train, Truth: truth}
+	}
+	train, truth := holdoutSplit(train, cfg.EvalValidationFraction, cfg.EvalSeed)
+	return evalSplit{Name: "validation", Train: train, Truth: truth}
+}
+
+// loadStudentRows reads every row of table, with the semester when the
+// table records one.
+func loadStudentRows(db *sql.DB, table string) ([]TrainRow, error) {
+	semester := "NULL"
+	if ok, err := tableHasColumn(db, table, "semester"); err != nil {
+		return nil, err
+	} else if ok {
+		semester = "semester"
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`SELECT student_id, competency_code, Overall_rating, Grade, %s FROM %s`, semester, table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []TrainRow
+	for rows.Next() {
+		var sid, comp, grade, sem sql.NullString
+		var overall sql.NullFloat64
+		if err := rows.Scan(&sid, &comp, &overall, &grade, &sem); err != nil {
+			return nil, err
+		}
+		if !sid.Valid || !comp.Valid {
+			continue
+		}
+		g, err := strconv.ParseFloat(strings.TrimSpace(grade.String), 64)
+		out = append(out, TrainRow{
+			StudentID:      sid.String,
+			CompetencyCode: comp.String,
+			OverallRating:  overall.Float64,
+			Grade:          g,
+			Graded:         err == nil,
+			Semester:       sem.String,
+		})
+	}
+	return out, rows.Err()
+}
+
+func tableHasColumn(db *sql.DB, table, column string) (bool, error) {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE lower(name) = lower(?)`, table, column).Scan(&n)
+	return n > 0, err
+}
+
+// rowsByStudent groups rows by student, each student's rows sorted by code
+// so that shuffles with the same seed give the same split.
+func rowsByStudent(rows []TrainRow) ([]string, map[string][]TrainRow) {
+	byStudent := make(map[string][]TrainRow)
+	for _, r := range rows {
+		byStudent[r.StudentID] = append(byStudent[r.StudentID], r)
+	}
+	students := make([]string, 0, len(byStudent))
+	for sid, own := range byStudent {
+		students = append(students, sid)
+		sort.Slice(own, func(i, j int) bool { return own[i].CompetencyCode < own[j].CompetencyCode })
+	}
+	sort.Strings(students)
+	return students, byStudent
+}
+
+// heldOut is how many of n rows a holdout of fraction moves to the truth: at
+// least one and never all of them, so a student with a single row keeps it.
+func heldOut(n int, fraction float64) int {
+	if n < 2 {
+		return 0
+	}
+	return min(max(int(math.Round(fraction*float64(n))), 1), n-1)
+}
+
+// holdoutSplit moves a random fraction of each student's rows to the truth.
+// The same rows and seed always give the same split.
+func holdoutSplit(rows []TrainRow, fraction float64, seed int64) ([]TrainRow, map[string][]string) {
+	students, byStudent := rowsByStudent(rows)
+	rng := rand.New(rand.NewSource(seed))
+	var train []TrainRow
+	truth := make(map[string][]string)
+	for _, sid := range students {
+		own := byStudent[sid]
+		rng.Shuffle(len(own), func(i, j int) { own[i], own[j] = own[j], own[i] })
+
+		held := heldOut(len(own), fraction)
+		for _, r := range own[:held] {
+			truth[sid] = append(truth[sid], r.CompetencyCode)
+		}
+		train = append(train, own[held:]...)
+	}
+	return train, truth
+}
+
+// temporalSplit moves the latest fraction of each student's rows to the
+// truth, so the recommender only sees what came before. Rows are ordered by
+// lessRecent, by semester only when every row has one.
+func temporalSplit(rows []TrainRow, fraction float64) ([]TrainRow, map[string][]string) {
+	dated := allDated(rows)
+	students, byStudent := rowsByStudent(rows)
+	var train []TrainRow
+	truth := make(map[string][]string)
+	for _, sid := range students {
+		own := byStudent[sid]
+		sort.SliceStable(own, func(i, j int) bool { return lessRecent(own[i], own[j], dated) })
+
+		held := heldOut(len(own), fraction)
+		cut := len(own) - held
+		for _, r := range own[cut:] {
+			truth[sid] = append(truth[sid], r.CompetencyCode)
+		}
+		train = append(train, own[:cut]...)
+	}
+	return train, truth
+}
+
+// temporalSplitName labels a temporal split of rows as the proxy unless
+// every row has a semester.
+func temporalSplitName(rows []TrainRow) string {
+	if allDated(rows) {
+		return EvalSplitTemporal
+	}
+	return evalTemporalProxy
+}
+
+// allDated reports whether every row has a semester semesterKey recognises.
+func allDated(rows []TrainRow) bool {
+	for _, r := range rows {
+		if semesterKey(r.Semester) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// lessRecent orders rows oldest first. With bySemester, which needs every
+// row to have one, rows go by semester (Spring, Summer, Fall of each year).
+// The snapshot has no semesters, so rows are otherwise, and within a
+// semester, ordered graded before in progress (no grade, or the 99
+// placeholder), then by course level, the first digit of the code number.
+// Mixing the two orders between pairs of rows would not be transitive.
+func lessRecent(a, b TrainRow, bySemester bool) bool {
+	if ka, kb := semesterKey(a.Semester), semesterKey(b.Semester); bySemester && ka != kb {
+		return ka < kb
+	}
+	doneA, doneB := a.Graded && a.Grade <= 4.0, b.Graded && b.Grade <= 4.0
+	if doneA != doneB {
+		return doneA
+	}
+	return courseLevel(a.CompetencyCode) < courseLevel(b.CompetencyCode)
+}
+
+// semesterKey orders "Spring 2025" < "Summer 2025" < "Fall 2025"; names it
+// does not recognise give -1.
+func semesterKey(semester string) int {
+	parts := strings.Fields(semester)
+	if len(parts) != 2 {
+		return -1
+	}
+	year, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return -1
+	}
+	switch strings.ToLower(parts[0]) {
+	case "spring":
+		return year * 3
+	case "summer":
+		return year*3 + 1
+	case "fall":
+		return year*3 + 2
+	}
+	return -1
+}
+
+// courseLevel is the first digit of the number in a code ("AIC-304" -> 3).
+func courseLevel(code string) int {
+	for _, r := range code {
+		if unicode.IsDigit(r) {
+			return int(r - '0')
+		}
+	}
+	return 0
+}
+
+// kFoldSplits deals each student's shuffled rows into k folds; fold i holds
+// out the student's rows dealt to it. A student with fewer than k rows has
+// none in some folds, and one whose fold would take every row is left out
+// of that fold's truth.
+func kFoldSplits(rows []TrainRow, k int, seed int64) []evalSplit {
+	students, byStudent := rowsByStudent(rows)
+	rng := rand.New(rand.NewSource(seed))
+
+	splits := make([]evalSplit, k)
+	for i := range splits {
+		splits[i] = evalSplit{Name: fmt.Sprintf("fold %d/%d", i+1, k), Truth: make(map[string][]string)}
+	}
+	for _, sid := range students {
+		own := byStudent[sid]
+		rng.Shuffle(len(own), func(i, j int) { own[i], own[j] = own[j], own[i] })
+		for fold := range splits {
+			var train []TrainRow
+			var held []string
+			for i, r := range own {
+				if i%k == fold {
+					held = append(held, r.CompetencyCode)
+				} else {
+					train = append(train, r)
+				}
+			}
+			if len(train) == 0 {
+				train, held = own, nil
+			}
+			splits[fold].Train = append(splits[fold].Train, train...)
+			if len(held) > 0 {
+				splits[fold].Truth[sid] = held
+			}
+		}
+	}
+	return splits
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTemporalSplitName(t *testing.T) {
+	dated := []TrainRow{{StudentID: "S1", CompetencyCode: "TST-101", Semester: "Fall 2024"}, {StudentID: "S1", CompetencyCode: "TST-201", Semester: "Spring 2025"}}
+	if got := temporalSplitName(dated); got != EvalSplitTemporal {
+		t.Errorf("rows with semesters: split %q, want %q", got, EvalSplitTemporal)
+	}
+	undated := append(dated, TrainRow{StudentID: "S1", CompetencyCode: "TST-301"})
+	if got := temporalSplitName(undated); got != evalTemporalProxy {
+		t.Errorf("a row without a semester: split %q, want %q", got, evalTemporalProxy)
+	}
+}
+
+func TestTemporalSplitHoldsOutLatestRows(t *testing.T) {
+	rows := []TrainRow{
+		{StudentID: "S1", CompetencyCode: "TST-301", Semester: "Fall 2025", Graded: true, Grade: 3},
+		{StudentID: "S1", CompetencyCode: "TST-102", Semester: "Spring 2024", Graded: true, Grade: 3},
+		{StudentID: "S1", CompetencyCode: "TST-201", Semester: "Spring 2025", Graded: true, Grade: 3},
+		{StudentID: "S1", CompetencyCode: "TST-101", Semester: "Fall 2024", Graded: true, Grade: 3},
+		{StudentID: "S2", CompetencyCode: "TST-101", Semester: "Fall 2024", Graded: true, Grade: 3},
+		{StudentID: "S2", CompetencyCode: "TST-102", Semester: "Fall 2024"},
+	}
+	train, truth := temporalSplit(rows, 0.5)
+	want := map[string][]string{"S1": {"TST-201", "TST-301"}, "S2": {"TST-102"}}
+	if !reflect.DeepEqual(truth, want) {
+		t.Errorf("truth = %v, want %v", truth, want)
+	}
+	if len(train) != 3 {
+		t.Errorf("train has %d rows, want 3", len(train))
+	}
+}
+
+func TestTemporalSplitMixedSemesters(t *testing.T) {
+	// Compared by semester whenever both rows have one, these rows form a
+	// cycle: CCC-101 < BBB-201 < AAA-301 by level, AAA-301 < CCC-101 by
+	// semester, so the order would depend on the order they come in. With
+	// BBB-201 undated, every row goes by level.
+	rows := []TrainRow{
+		{StudentID: "S1", CompetencyCode: "AAA-301", Semester: "Spring 2024", Graded: true, Grade: 3},
+		{StudentID: "S1", CompetencyCode: "BBB-201", Graded: true, Grade: 3},
+		{StudentID: "S1", CompetencyCode: "CCC-101", Semester: "Fall 2025", Graded: true, Grade: 3},
+	}
+	dated := allDated(rows)
+	for _, a := range rows {
+		for _, b := range rows {
+			for _, c := range rows {
+				if lessRecent(a, b, dated) && lessRecent(b, c, dated) && !lessRecent(a, c, dated) {
+					t.Errorf("%s < %s < %s, but not %s < %s", a.CompetencyCode, b.CompetencyCode, c.CompetencyCode, a.CompetencyCode, c.CompetencyCode)
+				}
+			}
+		}
+	}
+	if _, truth := temporalSplit(rows, 0.34); !reflect.DeepEqual(truth["S1"], []string{"AAA-301"}) {
+		t.Errorf("truth = %v, want [AAA-301]", truth["S1"])
+	}
+}
+
+func TestKFoldSplitsHoldOutEachRowOnce(t *testing.T) {
+	var rows []TrainRow
+	for sid, n := range map[string]int{"S1": 5, "S2": 2, "S3": 7, "S4": 1} {
+		for i := 0; i < n; i++ {
+			rows = append(rows, TrainRow{StudentID: sid, CompetencyCode: fmt.Sprintf("TST-%d", 100+i)})
+		}
+	}
+	splits := kFoldSplits(rows, 3, 1)
+
+	held := make(map[string]int)
+	for _, split := range splits {
+		trained := make(map[string]bool)
+		for _, r := range split.Train {
+			trained[r.StudentID+"|"+r.CompetencyCode] = true
+		}
+		for sid, codes := range split.Truth {
+			for _, code := range codes {
+				key := sid + "|" + code
+				held[key]++
+				if trained[key] {
+					t.Errorf("%s: %s is in both train and truth", split.Name, key)
+				}
+			}
+		}
+		if len(trained) != len(rows)-countTruth(split.Truth) {
+			t.Errorf("%s: %d training rows, want the %d not held out", split.Name, len(trained), len(rows)-countTruth(split.Truth))
+		}
+	}
+	for _, r := range rows {
+		key := r.StudentID + "|" + r.CompetencyCode
+		want := 1
+		if r.StudentID == "S4" {
+			want = 0 // a single row always stays in training
+		}
+		if held[key] != want {
+			t.Errorf("%s held out in %d folds, want %d", key, held[key], want)
+		}
+	}
+}
+
+func countTruth(truth map[string][]string) int {
+	n := 0
+	for _, codes := range truth {
+		n += len(codes)
+	}
+	return n
+}
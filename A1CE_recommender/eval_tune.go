@@ -0,0 +1,399 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TunedWeightProfile names the weight profile eval tune writes.
+const TunedWeightProfile = "tuned"
+
+// Searches eval tune can run.
+const (
+	TuneSearchGrid   = "grid"
+	TuneSearchRandom = "random"
+)
+
+// The values searched besides the weights. Random search draws thresholds
+// and neighbour counts from the same ranges.
+var (
+	tuneCreditLoads = []int{30, 45, 60, 75}
+	tuneThresholds  = []float64{0.6, 0.7, 0.8, 0.9}
+	tuneNeighbors   = []int{2, 3, 4, 5}
+)
+
+// tuneMetrics are the aggregate metrics a search can maximise, by their
+// JSON names.
+var tuneMetrics = map[string]func(a AggregateMetrics) float64{
+	"precision_at_k": func(a AggregateMetrics) float64 { return a.PrecisionAtK },
+	"recall_at_k":    func(a AggregateMetrics) float64 { return a.RecallAtK },
+	"f1_at_k":        func(a AggregateMetrics) float64 { return a.F1AtK },
+	"ndcg_at_k":      func(a AggregateMetrics) float64 { return a.NDCGAtK },
+	"map":            func(a AggregateMetrics) float64 { return a.MAP },
+	"mrr":            func(a AggregateMetrics) float64 { return a.MRR },
+	"hit_rate":       func(a AggregateMetrics) float64 { return a.HitRate },
+	"recall":         func(a AggregateMetrics) float64 { return a.Recall },
+}
+
+// TuningParams is one point of the search space: the credit load and, for
+// content-similarity, its scoring or, for fit-score, its fit weights.
+type TuningParams struct {
+	MaxCreditLoad int                      `json:"max_credit_load"`
+	Similarity    *ContentSimilarityParams `json:"similarity,omitempty"`
+	Fit           *FitWeights              `json:"fit,omitempty"`
+}
+
+func (p TuningParams) String() string {
+	switch {
+	case p.Similarity != nil:
+		s := p.Similarity
+		return fmt.Sprintf("credits=%d grade=%.3f rating=%.3f required=%.3f threshold=%.2f neighbors=%d",
+			p.MaxCreditLoad, s.GradeWeight, s.RatingWeight, s.RequiredWeight, s.TopThreshold, s.Neighbors)
+	case p.Fit != nil:
+		return fmt.Sprintf("credits=%d competency=%.3f interest=%.3f progress=%.3f gateway=%.3f",
+			p.MaxCreditLoad, p.Fit.Competency, p.Fit.Interest, p.Fit.Progress, p.Fit.Gateway)
+	default:
+		return fmt.Sprintf("credits=%d", p.MaxCreditLoad)
+	}
+}
+
+// TuningTrial is one evaluated point; Score is the searched metric.
+type TuningTrial struct {
+	Trial     int              `json:"trial"`
+	Params    TuningParams     `json:"params"`
+	Score     float64          `json:"score"`
+	Aggregate AggregateMetrics `json:"aggregate"`
+}
+
+// TuningReport records a search. Every trial is scored on the validation
+// split; Test is the best trial scored on the test split, for reference
+// only.
+type TuningReport struct {
+	GeneratedAt        time.Time        `json:"generated_at"`
+	Algorithm          string           `json:"algorithm"`
+	Search             string           `json:"search"`
+	Metric             string           `json:"metric"`
+	Split              string           `json:"split"`
+	K                  int              `json:"k"`
+	ValidationFraction float64          `json:"validation_fraction"`
+	ValidationStudents int              `json:"validation_students"`
+	Trials             []TuningTrial    `json:"trials"`
+	Best               TuningTrial      `json:"best"`
+	Test               AggregateMetrics `json:"test"`
+	Output             string           `json:"output"`
+}
+
+type tuneOptions struct {
+	search string
+	trials int
+	step   float64
+	metric string
+	output string
+}
+
+func (o *tuneOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.search, "search", TuneSearchRandom, "eval tune search: grid or random")
+	fs.IntVar(&o.trials, "trials", 50, "trials of an eval tune random search")
+	fs.Float64Var(&o.step, "tune-step", 0.1, "weight step of an eval tune grid search")
+	fs.StringVar(&o.metric, "tune-metric", "ndcg_at_k", "aggregate metric eval tune maximises")
+	fs.StringVar(&o.output, "tune-output", filepath.Join("logs", "tuned_config.json"), "config file eval tune writes the best parameters to")
+}
+
+func (o *tuneOptions) validate(algorithm string) error {
+	if algorithm != RecommenderContentSimilarity && algorithm != RecommenderFitScore {
+		return fmt.Errorf("eval tune tunes %s or %s; %s has no parameters", RecommenderContentSimilarity, RecommenderFitScore, algorithm)
+	}
+	if _, ok := tuneMetrics[o.metric]; !ok {
+		names := make([]string, 0, len(tuneMetrics))
+		for name := range tuneMetrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("-tune-metric must be one of %s; got %q", strings.Join(names, ", "), o.metric)
+	}
+	switch o.search {
+	case TuneSearchGrid:
+		if o.step <= 0 || o.step > 0.5 {
+			return fmt.Errorf("-tune-step must be in (0, 0.5]; got %g", o.step)
+		}
+	case TuneSearchRandom:
+		if o.trials <= 0 {
+			return fmt.Errorf("-trials must be positive; got %d", o.trials)
+		}
+	default:
+		return fmt.Errorf("-search must be %s or %s; got %q", TuneSearchGrid, TuneSearchRandom, o.search)
+	}
+	return nil
+}
+
+// TuneRecommender searches the parameters of eval_algorithm. It holds out
+// eval_validation_fraction of every student's training rows of the
+// eval_split split (its first fold under kfold), scores each trial on them,
+// and writes the best parameters to a config file that both the server and
+// eval accept with -config. The split's test rows are only used to report
+// how the best trial does.
+func TuneRecommender(cfg *Config, o *tuneOptions) error {
+	algorithm := evalRecommenderName(cfg.EvalAlgorithm)
+	if err := o.validate(algorithm); err != nil {
+		return err
+	}
+	base, err := baseWeightProfile(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := redirectLogsToReport(cfg.EvalReportPath); err != nil {
+		return err
+	}
+	data, err := loadEvalData(cfg)
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+	if algorithm == RecommenderFitScore {
+		data.env.Weights = &base
+	}
+
+	validation := data.withSplit(validationSplit(cfg, data.env.Train))
+
+	report := TuningReport{
+		GeneratedAt:        time.Now(),
+		Algorithm:          algorithm,
+		Search:             o.search,
+		Metric:             o.metric,
+		Split:              data.splitLabel(),
+		K:                  cfg.EvalK,
+		ValidationFraction: cfg.EvalValidationFraction,
+		ValidationStudents: len(validation.truth),
+		Trials:             []TuningTrial{},
+		Output:             o.output,
+	}
+	log.Printf("Tuning %s by %s search on %d validation students of the %s split, maximising %s\n", algorithm, o.search, len(validation.truth), report.Split, o.metric)
+
+	// The recommenders log per student; only the trials go to the report.
+	trialLog := log.New(log.Writer(), "", log.LstdFlags)
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(trialLog.Writer())
+
+	score := tuneMetrics[o.metric]
+	for i, params := range o.candidates(algorithm, base.Fit.Gateway, rand.New(rand.NewSource(cfg.EvalSeed))) {
+		agg, err := validation.withParams(params).run(algorithm)
+		if err != nil {
+			return err
+		}
+		trial := TuningTrial{Trial: i + 1, Params: params, Score: score(agg), Aggregate: agg}
+		report.Trials = append(report.Trials, trial)
+		trialLog.Printf("Trial %d: %s -> %s=%.4f\n", trial.Trial, params, o.metric, trial.Score)
+		if i == 0 || trial.Score > report.Best.Score {
+			report.Best = trial
+		}
+	}
+	if len(report.Trials) == 0 {
+		return fmt.Errorf("the search space is empty")
+	}
+
+	report.Test, err = data.withParams(report.Best.Params).run(algorithm)
+	if err != nil {
+		return err
+	}
+	log.SetOutput(trialLog.Writer())
+
+	if err := writeTunedConfig(o.output, cfg, report.Best.Params, base); err != nil {
+		return fmt.Errorf("write tuned config: %w", err)
+	}
+
+	for _, line := range []string{
+		fmt.Sprintf("Best of %d trials (trial %d): %s", len(report.Trials), report.Best.Trial, report.Best.Params),
+		fmt.Sprintf("Validation %s: %.4f", o.metric, report.Best.Score),
+		fmt.Sprintf("Test %s: %.4f", o.metric, score(report.Test)),
+		fmt.Sprintf("Best parameters written to %s", o.output),
+	} {
+		log.Println(line)
+		fmt.Println(line)
+	}
+
+	if err := writeJSONFileAtomic(cfg.EvalJSONReportPath, report); err != nil {
+		return fmt.Errorf("write JSON report: %w", err)
+	}
+	fmt.Printf("Report written to %s and %s\n", cfg.EvalReportPath, cfg.EvalJSONReportPath)
+	return nil
+}
+
+// candidates lists the trials of the search in order. Fit weights keep the
+// base profile's gateway weight and share the rest.
+func (o *tuneOptions) candidates(algorithm string, gateway float64, rng *rand.Rand) []TuningParams {
+	var out []TuningParams
+	if o.search == TuneSearchGrid {
+		for _, w := range simplexGrid(o.step) {
+			for _, credits := range tuneCreditLoads {
+				if algorithm == RecommenderFitScore {
+					out = append(out, TuningParams{MaxCreditLoad: credits, Fit: fitWeights(w, gateway)})
+					continue
+				}
+				for _, threshold := range tuneThresholds {
+					for _, neighbors := range tuneNeighbors {
+						out = append(out, TuningParams{MaxCreditLoad: credits, Similarity: &ContentSimilarityParams{
+							GradeWeight: w[0], RatingWeight: w[1], RequiredWeight: w[2], TopThreshold: threshold, Neighbors: neighbors,
+						}})
+					}
+				}
+			}
+		}
+		return out
+	}
+
+	for i := 0; i < o.trials; i++ {
+		w := randomSimplex(rng)
+		params := TuningParams{MaxCreditLoad: tuneCreditLoads[rng.Intn(len(tuneCreditLoads))]}
+		if algorithm == RecommenderFitScore {
+			params.Fit = fitWeights(w, gateway)
+		} else {
+			lo, hi := tuneThresholds[0], tuneThresholds[len(tuneThresholds)-1]
+			params.Similarity = &ContentSimilarityParams{
+				GradeWeight: w[0], RatingWeight: w[1], RequiredWeight: w[2],
+				TopThreshold: math.Round((lo+rng.Float64()*(hi-lo))*100) / 100,
+				Neighbors:    tuneNeighbors[0] + rng.Intn(tuneNeighbors[len(tuneNeighbors)-1]-tuneNeighbors[0]+1),
+			}
+		}
+		out = append(out, params)
+	}
+	return out
+}
+
+// simplexGrid lists every three weights that are multiples of step and sum
+// to 1.
+func simplexGrid(step float64) [][3]float64 {
+	n := int(math.Round(1 / step))
+	var out [][3]float64
+	for i := 0; i <= n; i++ {
+		for j := 0; j <= n-i; j++ {
+			out = append(out, [3]float64{float64(i) / float64(n), float64(j) / float64(n), float64(n-i-j) / float64(n)})
+		}
+	}
+	return out
+}
+
+// randomSimplex draws three weights uniformly among those summing to 1,
+// rounded to three decimals.
+func randomSimplex(rng *rand.Rand) [3]float64 {
+	var w [3]float64
+	sum := 0.0
+	for i := range w {
+		w[i] = -math.Log(1 - rng.Float64())
+		sum += w[i]
+	}
+	w[0] = math.Round(w[0]/sum*1000) / 1000
+	w[1] = math.Round(w[1]/sum*1000) / 1000
+	w[2] = math.Round((1-w[0]-w[1])*1000) / 1000
+	return w
+}
+
+// fitWeights scales three weights summing to 1 to share 1-gateway, with
+// gateway as the fourth. Competency and interest are rounded to three
+// decimals; progress takes what is left, so the four still sum to 1.
+func fitWeights(w [3]float64, gateway float64) *FitWeights {
+	share := 1 - gateway
+	fit := &FitWeights{
+		Competency: math.Round(w[0]*share*1000) / 1000,
+		Interest:   math.Round(w[1]*share*1000) / 1000,
+		Gateway:    gateway,
+	}
+	fit.Progress = math.Round((share-fit.Competency-fit.Interest)*1e9) / 1e9
+	return fit
+}
+
+// withParams returns the data with a trial's parameters applied.
+func (d *evalData) withParams(p TuningParams) *evalData {
+	trial := *d
+	cfg := *d.cfg
+	cfg.EvalMaxCreditLoad = p.MaxCreditLoad
+	trial.cfg = &cfg
+
+	env := *d.env
+	env.Config = &cfg
+	if p.Similarity != nil {
+		env.Params = *p.Similarity
+	}
+	if p.Fit != nil {
+		weights := *env.Weights
+		weights.Fit = *p.Fit
+		env.Weights = &weights
+	}
+	trial.env = &env
+	return &trial
+}
+
+// run recommends with the named strategy and returns the aggregate metrics.
+func (d *evalData) run(name string) (AggregateMetrics, error) {
+	recs, err := d.recommendAll(name)
+	if err != nil {
+		return AggregateMetrics{}, err
+	}
+	return d.evaluate(name, recs).Aggregate, nil
+}
+
+// baseWeightProfile is the configured weight profile, whose fit weights a
+// search replaces.
+func baseWeightProfile(cfg *Config) (WeightProfile, error) {
+	profiles, err := loadWeightProfiles(cfg.WeightProfilesPath)
+	if err != nil {
+		return WeightProfile{}, err
+	}
+	p, err := profiles.Get(cfg.WeightProfile)
+	if err != nil {
+		return WeightProfile{}, fmt.Errorf("weight_profile: %w", err)
+	}
+	return p, nil
+}
+
+// writeTunedConfig writes the best parameters as a config file. Fit weights
+// become the "tuned" profile of a weight profiles file next to it, which
+// also keeps the profiles of the configured file.
+func writeTunedConfig(path string, cfg *Config, best TuningParams, base WeightProfile) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	values := map[string]interface{}{
+		"eval_algorithm":       cfg.EvalAlgorithm,
+		"eval_max_credit_load": best.MaxCreditLoad,
+		"eval_split":           cfg.EvalSplit,
+		"eval_test_fraction":   cfg.EvalTestFraction,
+		"eval_folds":           cfg.EvalFolds,
+		// As a string: a JSON number above 2^53 would not read back exactly.
+		"eval_seed": strconv.FormatInt(cfg.EvalSeed, 10),
+	}
+	if s := best.Similarity; s != nil {
+		values["similarity_grade_weight"] = s.GradeWeight
+		values["similarity_rating_weight"] = s.RatingWeight
+		values["similarity_required_weight"] = s.RequiredWeight
+		values["similarity_top_threshold"] = s.TopThreshold
+		values["similarity_neighbors"] = s.Neighbors
+	}
+	if best.Fit != nil {
+		profiles := make(map[string]WeightProfile)
+		if err := readJSONFile(cfg.WeightProfilesPath, &profiles); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("read %s: %w", cfg.WeightProfilesPath, err)
+		}
+		base.Fit = *best.Fit
+		profiles[TunedWeightProfile] = base
+
+		profilesPath := filepath.Join(filepath.Dir(path), "tuned_weight_profiles.json")
+		if err := writeJSONFileAtomic(profilesPath, profiles); err != nil {
+			return err
+		}
+		values["weight_profiles_path"] = profilesPath
+		values["weight_profile"] = TunedWeightProfile
+	}
+	return writeJSONFileAtomic(path, values)
+}
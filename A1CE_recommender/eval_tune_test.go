@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+func TestTuneCandidatesKeepGateway(t *testing.T) {
+	base := builtinWeightProfiles[DefaultWeightProfile]
+	for _, o := range []tuneOptions{{search: TuneSearchGrid, step: 0.1}, {search: TuneSearchRandom, trials: 50}} {
+		for _, params := range o.candidates(RecommenderFitScore, base.Fit.Gateway, rand.New(rand.NewSource(1))) {
+			p := base
+			p.Fit = *params.Fit
+			if p.Fit.Gateway != base.Fit.Gateway {
+				t.Fatalf("%s: %s dropped the gateway weight", o.search, params)
+			}
+			if err := p.validate(); err != nil {
+				t.Fatalf("%s: %s: %v", o.search, params, err)
+			}
+		}
+	}
+}
+
+func TestTunedConfigReloads(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.EvalSeed = 1<<62 + 1 // not exact as a float64
+	cfg.EvalSplit = EvalSplitKFold
+	cfg.EvalFolds = 7
+	cfg.WeightProfilesPath = filepath.Join(t.TempDir(), "weight_profiles.json")
+	best := TuningParams{MaxCreditLoad: 45, Fit: &FitWeights{Competency: 0.3, Interest: 0.3, Progress: 0.4}}
+
+	path := filepath.Join(t.TempDir(), "tuned_config.json")
+	if err := writeTunedConfig(path, cfg, best, builtinWeightProfiles[DefaultWeightProfile]); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	got, err := LoadConfig(fs, []string{"-config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.EvalSeed != cfg.EvalSeed {
+		t.Errorf("eval_seed = %d, want %d", got.EvalSeed, cfg.EvalSeed)
+	}
+	if got.EvalSplit != cfg.EvalSplit || got.EvalFolds != cfg.EvalFolds || got.EvalMaxCreditLoad != 45 {
+		t.Errorf("split %s, folds %d, credit load %v", got.EvalSplit, got.EvalFolds, got.EvalMaxCreditLoad)
+	}
+	if got.WeightProfile != TunedWeightProfile {
+		t.Errorf("weight_profile = %q, want %q", got.WeightProfile, TunedWeightProfile)
+	}
+}
@@ -2,8 +2,12 @@ package main
 
 // competency_all_evaluator.go
 //
-// Implements the Python evaluation pipeline in one file for "all students" mode.
-// Logs are now written to logs/evaluation_report.txt
+// Evaluates recommendations for every student in student_train against the
+// courses they went on to take (student_test). The served recommender is
+// evaluated by default; eval_algorithm=legacy runs the original algorithm
+// ported from the Python notebook instead, and any registered strategy can
+// be named too. eval -compare scores several side by side (eval_compare.go).
+// Logs are written to Config.EvalReportPath (logs/evaluation_report.txt by default).
 //
 
 import (
@@ -20,22 +24,24 @@ import (
 )
 
 // ---------------------------------------------------------
-// Init: redirect all logs to logs/evaluation_report.txt
+// redirect all logs to the evaluation report
 // ---------------------------------------------------------
-func init() {
+func redirectLogsToReport(reportPath string) error {
 	// Ensure logs folder exists
-	os.MkdirAll("logs", os.ModePerm)
+	if err := os.MkdirAll(filepath.Dir(reportPath), os.ModePerm); err != nil {
+		return err
+	}
 
 	// Create/open the log file
-	logFile, err := os.Create(filepath.Join("logs", "evaluation_report.txt"))
+	logFile, err := os.Create(reportPath)
 	if err != nil {
-		fmt.Println("Failed to create log file:", err)
-		return
+		return fmt.Errorf("create report file: %w", err)
 	}
 
 	// Send all log output to this file
 	log.SetOutput(logFile)
 	log.SetFlags(log.LstdFlags)
+	return nil
 }
 
 // ---------------------------------------------------------
@@ -47,6 +53,8 @@ type TrainRow struct {
 	CompetencyCode string
 	OverallRating  float64
 	Grade          float64
+	Graded         bool   // false when Grade is NULL
+	Semester       string // empty when the table has no semester column
 }
 
 type CompetencyMeta struct {
@@ -59,221 +67,66 @@ type CompetencyMeta struct {
 // Main exported function
 // ---------------------------------------------------------
 
-func EvaluateAllStudentsFromSQLite(dbPath string) error {
-	if dbPath == "" {
-		dbPath = "a1ce_recommendation.db"
-	}
-
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return fmt.Errorf("open sqlite db: %w", err)
-	}
-	defer db.Close()
-
-	competencyMeta, err := loadCompetencyMeta(db)
-	if err != nil {
-		return fmt.Errorf("load competency data: %w", err)
-	}
-
-	sim, err := loadSimilarityMatrix(db)
-	if err != nil {
-		log.Printf("warning: cannot load similarity matrix: %v (nearest neighbors empty)\n", err)
-		sim = make(map[string]map[string]float64)
-	}
-
-	prereqs, err := loadPrerequisites(db)
-	if err != nil {
-		log.Printf("warning: cannot load prerequisites: %v (skipped)\n", err)
-		prereqs = make(map[string][]string)
+func EvaluateAllStudentsFromSQLite(cfg *Config) error {
+	if err := redirectLogsToReport(cfg.EvalReportPath); err != nil {
+		return err
 	}
 
-	allTrain, err := loadAllStudentTrain(db)
+	data, err := loadEvalData(cfg)
 	if err != nil {
-		return fmt.Errorf("load student_train: %w", err)
-	}
-	if len(allTrain) == 0 {
-		return fmt.Errorf("no data in student_train")
-	}
-
-	requiredMap := make(map[string]int)
-	for code, meta := range competencyMeta {
-		requiredMap[code] = meta.Required
+		return err
 	}
+	defer data.Close()
 
-	// Group by student
-	studentRowsMap := make(map[string][]TrainRow)
-	uniqueStudents := []string{}
-	for _, r := range allTrain {
-		if _, ok := studentRowsMap[r.StudentID]; !ok {
-			uniqueStudents = append(uniqueStudents, r.StudentID)
-		}
-		studentRowsMap[r.StudentID] = append(studentRowsMap[r.StudentID], r)
+	if len(data.splits) > 1 {
+		return crossValidateAlgorithm(cfg, data)
 	}
 
-	sort.Strings(uniqueStudents)
-	allRecommendations := make(map[string][]string)
-
 	// ---------------------------------------------------------
 	// Generate recommendations
 	// ---------------------------------------------------------
-	for _, studentID := range uniqueStudents {
-		log.Printf("Processing student: %s\n", studentID)
-
-		rows := studentRowsMap[studentID]
-
-		type scored struct {
-			Comp  string
-			Score float64
-		}
-		var scoredList []scored
-
-		for _, rr := range rows {
-			req := requiredMap[rr.CompetencyCode]
-			score := (rr.Grade/4.0)*0.5 + (rr.OverallRating/5.0)*0.3 + float64(req)*0.2
-			scoredList = append(scoredList, scored{Comp: rr.CompetencyCode, Score: score})
-		}
-
-		topSet := make(map[string]struct{})
-		for _, s := range scoredList {
-			if s.Score >= 0.8 {
-				topSet[s.Comp] = struct{}{}
-			}
-		}
-
-		if len(topSet) == 0 {
-			log.Printf("Student %s: no top competencies (>=0.8)\n", studentID)
-			allRecommendations[studentID] = []string{}
-			continue
-		}
-
-		var topComps []string
-		for c := range topSet {
-			topComps = append(topComps, c)
-		}
-
-		recommendedSet := make(map[string]struct{})
-		for _, t := range topComps {
-			neighbors := nearestNeighborsFromSim(sim, t, 3)
-			for _, n := range neighbors {
-				recommendedSet[n] = struct{}{}
-			}
-		}
-
-		completedSet := topSet
-		finalCandidates := []string{}
-		for comp := range recommendedSet {
-			if reqs, ok := prereqs[comp]; ok {
-				missing := false
-				for _, pre := range reqs {
-					if _, have := completedSet[pre]; !have {
-						missing = true
-						break
-					}
-				}
-				if missing {
-					continue
-				}
-			}
-			finalCandidates = append(finalCandidates, comp)
-		}
-
-		completedAll := make(map[string]struct{})
-		for _, r := range rows {
-			completedAll[r.CompetencyCode] = struct{}{}
-		}
-
-		filtered := []string{}
-		for _, c := range finalCandidates {
-			if _, done := completedAll[c]; !done {
-				filtered = append(filtered, c)
-			}
-		}
-
-		type cm struct {
-			Code     string
-			Required int
-			Credits  int
-		}
-		var candList []cm
-		for _, c := range filtered {
-			meta := competencyMeta[c]
-			candList = append(candList, cm{Code: c, Required: meta.Required, Credits: meta.Credits})
-		}
-
-		sort.Slice(candList, func(i, j int) bool {
-			if candList[i].Required != candList[j].Required {
-				return candList[i].Required > candList[j].Required
-			}
-			return candList[i].Credits < candList[j].Credits
-		})
-
-		totalCredits := 0
-		used := make(map[string]struct{})
-		selected := []string{}
-
-		for _, row := range candList {
-			if totalCredits+row.Credits <= 60 {
-				if _, ok := used[row.Code]; !ok {
-					selected = append(selected, row.Code)
-					totalCredits += row.Credits
-					used[row.Code] = struct{}{}
-				}
-			}
-		}
-
-		allRecommendations[studentID] = selected
+	allRecommendations, err := data.recommendAll(evalRecommenderName(cfg.EvalAlgorithm))
+	if err != nil {
+		return err
 	}
 
 	// Write recommendations CSV
-	if err := writeRecommendationsCSV("student_recommendations.csv", allRecommendations); err != nil {
+	if err := writeRecommendationsCSV(cfg.RecommendationsCSVPath, allRecommendations); err != nil {
 		return err
 	}
-	log.Println("Wrote recommendations to student_recommendations.csv")
+	log.Printf("Wrote recommendations to %s\n", cfg.RecommendationsCSVPath)
 
 	// ---------------------------------------------------------
 	// Evaluation
 	// ---------------------------------------------------------
-	testTruth, err := loadStudentTestTruth(db)
-	if err != nil {
-		return err
+	report := data.evaluate(cfg.EvalAlgorithm, allRecommendations)
+	if len(report.Students) == 0 {
+		log.Println("No students with truth data found.")
 	}
+	logEvaluationReport(report)
 
-	var accuracies []float64
-	for sid, truth := range testTruth {
-		rec := allRecommendations[sid]
-		correct := intersectionCount(truth, rec)
-
-		acc := 0.0
-		if len(truth) > 0 {
-			acc = float64(correct) / float64(len(truth))
-		}
-		accuracies = append(accuracies, acc)
-
-		log.Printf("Student %s: true=%d, recommended=%d, correct=%d, acc=%.2f\n",
-			sid, len(truth), len(rec), correct, acc)
+	if err := writeJSONFileAtomic(cfg.EvalJSONReportPath, report); err != nil {
+		return fmt.Errorf("write JSON report: %w", err)
 	}
+	fmt.Printf("Report written to %s and %s\n", cfg.EvalReportPath, cfg.EvalJSONReportPath)
 
-	// Average accuracy
-    if len(accuracies) == 0 {
-        log.Println("No students with truth data found.")
-        fmt.Println("Average Recommendation Accuracy: 0.00%")
-        fmt.Println("Report written to logs/evaluation_report.txt")
-        return nil
-    }
-
-    sum := 0.0
-    for _, v := range accuracies {
-        sum += v
-    }
-    avg := sum / float64(len(accuracies))
-    log.Printf("Average Recommendation Accuracy: %.2f%%\n", avg*100)
-
-    // Print final accuracy ALSO to terminal
-    fmt.Printf("Average Recommendation Accuracy: %.2f%%\n", avg*100)
+	return nil
+}
 
-    // Inform user (no blank line)
-    fmt.Println("Report written to logs/evaluation_report.txt")
+// crossValidateAlgorithm evaluates eval_algorithm on every fold. No
+// recommendations CSV is written, since each student has a list per fold.
+func crossValidateAlgorithm(cfg *Config, data *evalData) error {
+	folds, err := data.evaluateSplits(cfg.EvalAlgorithm, evalRecommenderName(cfg.EvalAlgorithm))
+	if err != nil {
+		return err
+	}
+	cv := crossValidate(folds, cfg.EvalSeed)
+	logCrossValidation(cv)
 
+	if err := writeJSONFileAtomic(cfg.EvalJSONReportPath, cv); err != nil {
+		return fmt.Errorf("write JSON report: %w", err)
+	}
+	fmt.Printf("Report written to %s and %s\n", cfg.EvalReportPath, cfg.EvalJSONReportPath)
 	return nil
 }
 
@@ -420,6 +273,7 @@ func loadAllStudentTrain(db *sql.DB) ([]TrainRow, error) {
 				CompetencyCode: comp.String,
 				OverallRating:  overall.Float64,
 				Grade:          grade.Float64,
+				Graded:         grade.Valid,
 			})
 		}
 	}
@@ -469,7 +323,8 @@ func writeRecommendationsCSV(filename string, recs map[string][]string) error {
 	sort.Strings(students)
 
 	for _, sid := range students {
-		comps := recs[sid]
+		// Sort a copy: the lists are ranked and evaluated afterwards.
+		comps := append([]string(nil), recs[sid]...)
 		sort.Strings(comps)
 		for _, c := range comps {
 			w.Write([]string{sid, c})
@@ -499,6 +354,7 @@ func loadStudentTestTruth(db *sql.DB) (map[string][]string, error) {
 	return out, nil
 }
 
+// intersectionCount counts the distinct codes in both a and b.
 func intersectionCount(a, b []string) int {
 	set := make(map[string]struct{})
 	for _, x := range a {
@@ -508,8 +364,9 @@ func intersectionCount(a, b []string) int {
 	cnt := 0
 	for _, y := range b {
 		if _, ok := set[strings.TrimSpace(y)]; ok {
+			delete(set, strings.TrimSpace(y))
 			cnt++
 		}
 	}
 	return cnt
-}
\ No newline at end of file
+}
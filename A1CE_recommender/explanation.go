@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ScoreExplanation says why a course was recommended. The contributions of
+// the components, including any preferences adjustment, add up to the fit
+// score.
+type ScoreExplanation struct {
+	Components             []ScoreComponent        `json:"components"`
+	RequirementsFilled     []string                `json:"requirements_filled,omitempty"`
+	DistributionGap        *DistributionGap        `json:"distribution_gap,omitempty"`
+	PrerequisitesSatisfied []SatisfiedPrerequisite `json:"prerequisites_satisfied,omitempty"`
+	InterestDrivers        []string                `json:"interest_drivers,omitempty"`
+	Unlocks                int                     `json:"unlocks,omitempty"`
+	Sentences              []ExplanationSentence   `json:"sentences"`
+	Text                   string                  `json:"text"`
+}
+
+// ScoreComponent is one part of the fit score with the inputs behind it.
+type ScoreComponent struct {
+	Name         string             `json:"name"`
+	Score        float64            `json:"score"`
+	Weight       float64            `json:"weight"`
+	Contribution float64            `json:"contribution"`
+	Inputs       map[string]float64 `json:"inputs"`
+}
+
+// DistributionGap is the area or elective pool a course counts towards and
+// the credits it still needs.
+type DistributionGap struct {
+	Area          string  `json:"area"`
+	CreditsNeeded float64 `json:"credits_needed"`
+	CreditsFilled float64 `json:"credits_filled"`
+}
+
+// SatisfiedPrerequisite is a prerequisite the student holds, with their
+// grade and the minimum the rules ask for.
+type SatisfiedPrerequisite struct {
+	Course   string  `json:"course"`
+	Grade    float64 `json:"grade"`
+	MinGrade float64 `json:"min_grade,omitempty"`
+}
+
+// ExplanationSentence is one sentence of an explanation as a message key
+// and its parameters, so clients can render it in their own language.
+type ExplanationSentence struct {
+	Key    string            `json:"key"`
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// explanationMessages are the sentence templates by language and key.
+var explanationMessages = map[string]map[string]string{
+	"en": {
+		"requirement":   "It satisfies the required course(s) {courses}.",
+		"distribution":  "It adds {credits} credits to {area}, which still needs {needed}.",
+		"interest":      "It matches your interest in {subdomain}, based on {courses}.",
+		"prerequisites": "You meet its prerequisites: {courses}.",
+		"unlocks":       "It opens the way to {count} later course(s).",
+		"general":       "It fits your competencies and degree progress.",
+	},
+}
+
+// Render writes the explanation in lang, falling back to English.
+func (e *ScoreExplanation) Render(lang string) string {
+	messages, ok := explanationMessages[lang]
+	if !ok {
+		messages = explanationMessages["en"]
+	}
+	var out []string
+	for _, s := range e.Sentences {
+		text := messages[s.Key]
+		for name, value := range s.Params {
+			text = strings.ReplaceAll(text, "{"+name+"}", value)
+		}
+		out = append(out, text)
+	}
+	return strings.Join(out, " ")
+}
+
+// courseScores are the scores scoreCourses worked out for one course, with
+// the parts behind them.
+type courseScores struct {
+	Competency      float64
+	Interest        float64
+	Progress        float64
+	Unlock          float64
+	CompetencyParts competencyMatchParts
+	ProgressParts   programProgressParts
+}
+
+// explain builds the explanation of a course's fit score from the scores it
+// was given.
+func (s *RecommenderService) explain(course Course, profile *StudentProfile, weights WeightProfile, scores courseScores) *ScoreExplanation {
+	comp, progress := scores.CompetencyParts, scores.ProgressParts
+
+	component := func(name string, score, weight float64, inputs map[string]float64) ScoreComponent {
+		return ScoreComponent{Name: name, Score: score, Weight: weight, Contribution: score * weight, Inputs: inputs}
+	}
+	e := &ScoreExplanation{
+		Components: []ScoreComponent{
+			component("competency_match", scores.Competency, weights.Fit.Competency, map[string]float64{
+				"prerequisites": comp.Prerequisites, "grades": comp.Grades, "skill_gap": comp.SkillGap,
+			}),
+			component("interest", scores.Interest, weights.Fit.Interest, interestInputs(course, profile, weights.InterestAlignment)),
+			component("program_progress", scores.Progress, weights.Fit.Progress, map[string]float64{
+				"required": progress.Required, "distribution": progress.Distribution,
+				"remaining": progress.Remaining, "urgency": progress.Urgency,
+			}),
+			component("gateway", scores.Unlock, weights.Fit.Gateway, map[string]float64{"unlock_score": scores.Unlock}),
+		},
+		RequirementsFilled:     progress.Filled,
+		PrerequisitesSatisfied: s.satisfiedPrerequisites(course, profile),
+		InterestDrivers:        profile.InterestSources[course.SubdomainID],
+		Unlocks:                s.prereqs.Unlocks(course.CourseCode),
+	}
+	if progress.AreaGap > 0 {
+		e.DistributionGap = &DistributionGap{
+			Area:          progress.Area,
+			CreditsNeeded: progress.AreaGap,
+			CreditsFilled: math.Min(course.CreditHours, progress.AreaGap),
+		}
+	}
+
+	say := func(key string, params map[string]string) {
+		e.Sentences = append(e.Sentences, ExplanationSentence{Key: key, Params: params})
+	}
+	if len(e.RequirementsFilled) > 0 {
+		say("requirement", map[string]string{"courses": strings.Join(e.RequirementsFilled, ", ")})
+	}
+	if g := e.DistributionGap; g != nil {
+		say("distribution", map[string]string{
+			"area": g.Area, "credits": formatCredits(g.CreditsFilled), "needed": formatCredits(g.CreditsNeeded),
+		})
+	}
+	if len(e.InterestDrivers) > 0 {
+		say("interest", map[string]string{"subdomain": course.SubdomainName, "courses": strings.Join(e.InterestDrivers, ", ")})
+	}
+	if len(e.PrerequisitesSatisfied) > 0 {
+		var codes []string
+		for _, p := range e.PrerequisitesSatisfied {
+			codes = append(codes, p.Course)
+		}
+		say("prerequisites", map[string]string{"courses": strings.Join(codes, ", ")})
+	}
+	if e.Unlocks > 0 {
+		say("unlocks", map[string]string{"count": fmt.Sprint(e.Unlocks)})
+	}
+	if len(e.Sentences) == 0 {
+		say("general", nil)
+	}
+	e.Text = e.Render("en")
+	return e
+}
+
+// addPreferences records what the request's preferences added to or took
+// from the fit score, by preference, as the "preferences" component.
+func (e *ScoreExplanation) addPreferences(inputs map[string]float64) {
+	if e == nil || len(inputs) == 0 {
+		return
+	}
+	total := 0.0
+	for _, v := range inputs {
+		total += v
+	}
+	e.Components = append(e.Components, ScoreComponent{Name: "preferences", Score: total, Weight: 1, Contribution: total, Inputs: inputs})
+}
+
+// satisfiedPrerequisites lists the course's prerequisites, from the catalog
+// and the prerequisite graph, that the student holds.
+func (s *RecommenderService) satisfiedPrerequisites(course Course, profile *StudentProfile) []SatisfiedPrerequisite {
+	seen := make(map[string]bool)
+	var out []SatisfiedPrerequisite
+	for _, code := range append(append([]string(nil), course.Prerequisites...), s.prereqs.Prerequisites(course.CourseCode)...) {
+		if seen[normalizeCode(code)] {
+			continue
+		}
+		seen[normalizeCode(code)] = true
+		grade, ok := studentGrade(profile, code)
+		if !ok {
+			continue
+		}
+		p := SatisfiedPrerequisite{Course: code, Grade: grade}
+		for comp, minGrade := range course.RequiredCompetencies {
+			if normalizeCode(comp) == normalizeCode(code) {
+				p.MinGrade = minGrade
+			}
+		}
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Course < out[j].Course })
+	return out
+}
+
+// interestSources lists, by subdomain, the completed courses InferInterestAreas
+// counts towards that subdomain's interest.
+func interestSources(completedCourses []string, courseCatalog []Course) map[string][]string {
+	courseMap := make(map[string]Course)
+	for _, course := range courseCatalog {
+		courseMap[course.CourseID] = course
+	}
+	sources := make(map[string][]string)
+	for _, courseID := range completedCourses {
+		if course, ok := courseMap[courseID]; ok && !containsString(sources[course.SubdomainID], course.CourseCode) {
+			sources[course.SubdomainID] = append(sources[course.SubdomainID], course.CourseCode)
+		}
+	}
+	return sources
+}
+
+// interestInputs reports the subdomain weight CalculateInterestScore used,
+// or its default for a subdomain the student has not explored.
+func interestInputs(course Course, profile *StudentProfile, weights InterestAlignmentWeights) map[string]float64 {
+	if weight, ok := profile.InterestWeights[course.SubdomainID]; ok {
+		return map[string]float64{"subdomain_weight": weight}
+	}
+	return map[string]float64{"unexplored_default": weights.Unexplored}
+}
+
+func formatCredits(credits float64) string {
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", credits), ".0")
+}
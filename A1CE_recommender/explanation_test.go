@@ -0,0 +1,56 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExplanationComponentsSumToFitScore(t *testing.T) {
+	svc := NewRecommenderService(DefaultConfig(), nil)
+	weights := builtinWeightProfiles[DefaultWeightProfile]
+	profile := &StudentProfile{
+		Competencies:    map[string]float64{"TST-101": 3.0},
+		InterestWeights: map[string]float64{"ml": 0.8},
+		TotalCredits:    A1CECredit{Earned: 30, Required: 120},
+	}
+	requirements := &CurriculumRequirements{
+		RequiredCompetencies: []string{"TST-201"},
+		TotalCreditsRequired: 120,
+		AreaCredits:          map[string]float64{},
+	}
+	courses := []Course{
+		{CourseID: "TST-201", CourseCode: "TST-201", SubdomainID: "ml", CreditHours: 3,
+			RequiredCompetencies: map[string]float64{"TST-101": 2.0}, TeachesCompetencies: []string{"TST-201"}},
+		{CourseID: "TST-202", CourseCode: "TST-202", SubdomainID: "hci", CreditHours: 3},
+	}
+	unlock := map[string]float64{"TST-201": 1}
+
+	scored := svc.scoreCourses(courses, profile, requirements, unlock, weights)
+	scored = applyPreferences(scored, &RecommendationFilters{PreferredSubdomains: []string{"hci"}}, nil, defaultPreferences)
+
+	for _, rec := range scored {
+		sum := 0.0
+		for _, c := range rec.Explanation.Components {
+			sum += c.Contribution
+		}
+		if math.Abs(sum-rec.FitScore) > 1e-9 {
+			t.Errorf("%s: components sum to %v, fit score is %v", rec.Course.CourseCode, sum, rec.FitScore)
+		}
+	}
+	fit := map[string]float64{
+		"competency_match": weights.Fit.Competency, "interest": weights.Fit.Interest,
+		"program_progress": weights.Fit.Progress, "gateway": weights.Fit.Gateway,
+	}
+	for _, c := range scored[0].Explanation.Components {
+		if w, ok := fit[c.Name]; ok && c.Weight != w {
+			t.Errorf("%s weight = %v, want the profile's %v", c.Name, c.Weight, w)
+		}
+	}
+	for _, rec := range scored {
+		components := rec.Explanation.Components
+		hasPreferences := components[len(components)-1].Name == "preferences"
+		if preferred := rec.Course.CourseCode == "TST-202"; hasPreferences != preferred {
+			t.Errorf("%s: preferences component %v, want %v", rec.Course.CourseCode, hasPreferences, preferred)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FixtureDataSource serves canned A1CE responses from a directory:
+//
+//	<dir>/catalog.json            {"courses": [...]}
+//	<dir>/students/<id>.json      {"identity": {...}, "cards": [...], "graduation_status": {...}}
+//
+// Records use the same JSON shapes as the A1CE API models.
+type FixtureDataSource struct {
+	Dir string
+}
+
+type fixtureStudent struct {
+	Identity         A1CEStudentIdentity   `json:"identity"`
+	Cards            []A1CECompetencyCard  `json:"cards"`
+	GraduationStatus *A1CEGraduationStatus `json:"graduation_status"`
+}
+
+func NewFixtureDataSource(dir string) (*FixtureDataSource, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("open fixtures: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("open fixtures: %s is not a directory", dir)
+	}
+	return &FixtureDataSource{Dir: dir}, nil
+}
+
+func (f *FixtureDataSource) GetStudentProfile(ctx context.Context, studentID string) (*StudentProfile, error) {
+	student, err := f.loadStudent(studentID)
+	if err != nil {
+		return nil, err
+	}
+	profile := buildStudentProfile(studentID, &student.Identity, student.Cards, student.GraduationStatus)
+	profile.Completeness.Record(ProfilePartHistory, nil)
+	if student.GraduationStatus == nil {
+		profile.Completeness.Record(ProfilePartGraduation, fmt.Errorf("graduation status fixture for student %s: %w", studentID, ErrNotFound))
+	} else {
+		profile.Completeness.Record(ProfilePartGraduation, nil)
+	}
+	return profile, nil
+}
+
+func (f *FixtureDataSource) GetSemesterCompetencies(ctx context.Context, studentID, semester string) ([]A1CECompetencyCard, error) {
+	student, err := f.loadStudent(studentID)
+	if err != nil {
+		return nil, err
+	}
+	cards := []A1CECompetencyCard{}
+	for _, card := range student.Cards {
+		if card.Semester == semester {
+			cards = append(cards, card)
+		}
+	}
+	return cards, nil
+}
+
+func (f *FixtureDataSource) GetGraduationStatus(ctx context.Context, studentID string) (*A1CEGraduationStatus, error) {
+	student, err := f.loadStudent(studentID)
+	if err != nil {
+		return nil, err
+	}
+	if student.GraduationStatus == nil {
+		return nil, fmt.Errorf("graduation status fixture for student %s: %w", studentID, ErrNotFound)
+	}
+	return student.GraduationStatus, nil
+}
+
+func (f *FixtureDataSource) GetCourseCatalog(ctx context.Context, semester string, curriculumVersion int) (*CourseCatalogResponse, error) {
+	var input struct {
+		Courses []Course `json:"courses"`
+	}
+	if err := readJSONFile(filepath.Join(f.Dir, "catalog.json"), &input); err != nil {
+		return nil, err
+	}
+
+	catalog := &CourseCatalogResponse{
+		Status: "success", Semester: semester, CurriculumVersion: curriculumVersion, Courses: []Course{},
+	}
+	catalog.Courses = append(catalog.Courses, input.Courses...)
+	catalog.TotalCourses = len(catalog.Courses)
+	return catalog, nil
+}
+
+func (f *FixtureDataSource) loadStudent(studentID string) (*fixtureStudent, error) {
+	if filepath.Base(studentID) != studentID {
+		return nil, fmt.Errorf("invalid student id %q", studentID)
+	}
+	var student fixtureStudent
+	err := readJSONFile(filepath.Join(f.Dir, "students", studentID+".json"), &student)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("student %s: %w", studentID, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &student, nil
+}
+
+func readJSONFile(filename string, v interface{}) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return json.NewDecoder(file).Decode(v)
+}
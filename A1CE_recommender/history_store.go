@@ -0,0 +1,259 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var errHistoryNotFound = errors.New("recommendation not found")
+
+// HistoryStore persists every generated RecommendationSet so advisors can see
+// what was suggested over time.
+type HistoryStore struct {
+	db *sql.DB
+}
+
+// RecommendationHistoryEntry is one stored recommendation set.
+type RecommendationHistoryEntry struct {
+	ID               int64                 `json:"recommendation_id"`
+	StudentID        string                `json:"student_id"`
+	Semester         string                `json:"semester"`
+	Request          RecommendationRequest `json:"request"`
+	AlgorithmVersion string                `json:"algorithm_version"`
+	TotalCredits     float64               `json:"total_credits"`
+	GoodnessScore    float64               `json:"goodness_score"`
+	Status           string                `json:"status"`
+	CreatedAt        time.Time             `json:"created_at"`
+	Courses          []HistoryCourse       `json:"courses,omitempty"`
+	Enrollment       *EnrollmentComparison `json:"enrollment,omitempty"`
+}
+
+// HistoryCourse is one course of a stored set with the scores it had then.
+type HistoryCourse struct {
+	Position               int     `json:"position"`
+	CourseID               string  `json:"course_id"`
+	CourseCode             string  `json:"course_code"`
+	CourseName             string  `json:"course_name"`
+	CreditHours            float64 `json:"credit_hours"`
+	FitScore               float64 `json:"fit_score"`
+	CompetencyMatchScore   float64 `json:"competency_match_score"`
+	InterestAlignmentScore float64 `json:"interest_alignment_score"`
+	ProgramProgressScore   float64 `json:"program_progress_score"`
+	Reason                 string  `json:"reason"`
+}
+
+// EnrollmentComparison relates a stored set to the student's current record.
+type EnrollmentComparison struct {
+	TakenCourses    []string `json:"taken_courses"`
+	NotTakenCourses []string `json:"not_taken_courses"`
+	FollowRate      float64  `json:"follow_rate"`
+	Error           string   `json:"error,omitempty"`
+}
+
+const historySchema = `
+CREATE TABLE IF NOT EXISTS recommendation_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    student_id TEXT NOT NULL,
+    semester TEXT,
+    request_json TEXT,
+    algorithm_version TEXT,
+    total_credits REAL,
+    goodness_score REAL,
+    status TEXT,
+    created_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_recommendation_history_student
+    ON recommendation_history (student_id, created_at);
+CREATE TABLE IF NOT EXISTS recommendation_history_course (
+    history_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    course_id TEXT,
+    competency_code TEXT,
+    course_name TEXT,
+    credit_hours REAL,
+    fit_score REAL,
+    competency_match_score REAL,
+    interest_alignment_score REAL,
+    program_progress_score REAL,
+    reason TEXT,
+    PRIMARY KEY (history_id, position),
+    FOREIGN KEY (history_id) REFERENCES recommendation_history(id)
+);`
+
+// NewHistoryStore opens dbPath, creating its directory and the history
+// tables if needed.
+func NewHistoryStore(dbPath string) (*HistoryStore, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("create history db directory: %w", err)
+	}
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("open history db: %w", err)
+	}
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create history tables: %w", err)
+	}
+	return &HistoryStore{db: db}, nil
+}
+
+func (h *HistoryStore) Close() error {
+	return h.db.Close()
+}
+
+// Save stores set and the request that produced it, returning the new id.
+func (h *HistoryStore) Save(req *RecommendationRequest, set *RecommendationSet) (int64, error) {
+	requestJSON, err := json.Marshal(req)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	createdAt := set.Metadata.GenerationTimestamp
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	res, err := tx.Exec(`INSERT INTO recommendation_history
+		(student_id, semester, request_json, algorithm_version, total_credits, goodness_score, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		set.StudentID, set.Semester, string(requestJSON), set.Metadata.AlgorithmVersion,
+		set.TotalCredits, set.Metrics.GoodnessScore, set.Status, createdAt.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return 0, fmt.Errorf("insert recommendation: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for i, rec := range set.RecommendedSet {
+		_, err := tx.Exec(`INSERT INTO recommendation_history_course
+			(history_id, position, course_id, competency_code, course_name, credit_hours,
+			 fit_score, competency_match_score, interest_alignment_score, program_progress_score, reason)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, i+1, rec.Course.CourseID, rec.Course.CourseCode, rec.Course.CourseName, rec.Course.CreditHours,
+			rec.FitScore, rec.CompetencyMatchScore, rec.InterestAlignmentScore, rec.ProgramProgressScore, rec.Reason)
+		if err != nil {
+			return 0, fmt.Errorf("insert recommended course: %w", err)
+		}
+	}
+
+	return id, tx.Commit()
+}
+
+// ListByStudent returns the student's stored sets, newest first, without
+// their courses.
+func (h *HistoryStore) ListByStudent(studentID string, limit int) ([]RecommendationHistoryEntry, error) {
+	rows, err := h.db.Query(`SELECT id, student_id, semester, request_json, algorithm_version,
+		total_credits, goodness_score, status, created_at
+		FROM recommendation_history WHERE student_id = ? ORDER BY created_at DESC, id DESC LIMIT ?`,
+		studentID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []RecommendationHistoryEntry{}
+	for rows.Next() {
+		entry, err := scanHistoryEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, rows.Err()
+}
+
+// Get returns one stored set with its courses.
+func (h *HistoryStore) Get(id int64) (*RecommendationHistoryEntry, error) {
+	row := h.db.QueryRow(`SELECT id, student_id, semester, request_json, algorithm_version,
+		total_credits, goodness_score, status, created_at
+		FROM recommendation_history WHERE id = ?`, id)
+	entry, err := scanHistoryEntry(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errHistoryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := h.db.Query(`SELECT position, course_id, competency_code, course_name, credit_hours,
+		fit_score, competency_match_score, interest_alignment_score, program_progress_score, reason
+		FROM recommendation_history_course WHERE history_id = ? ORDER BY position`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entry.Courses = []HistoryCourse{}
+	for rows.Next() {
+		var c HistoryCourse
+		var courseID, code, name, reason sql.NullString
+		if err := rows.Scan(&c.Position, &courseID, &code, &name, &c.CreditHours,
+			&c.FitScore, &c.CompetencyMatchScore, &c.InterestAlignmentScore, &c.ProgramProgressScore, &reason); err != nil {
+			return nil, err
+		}
+		c.CourseID, c.CourseCode, c.CourseName, c.Reason = courseID.String, code.String, name.String, reason.String
+		entry.Courses = append(entry.Courses, c)
+	}
+	return entry, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanHistoryEntry(row rowScanner) (*RecommendationHistoryEntry, error) {
+	var entry RecommendationHistoryEntry
+	var semester, requestJSON, version, status sql.NullString
+	var createdAt string
+	if err := row.Scan(&entry.ID, &entry.StudentID, &semester, &requestJSON, &version,
+		&entry.TotalCredits, &entry.GoodnessScore, &status, &createdAt); err != nil {
+		return nil, err
+	}
+	entry.Semester, entry.AlgorithmVersion, entry.Status = semester.String, version.String, status.String
+	if requestJSON.Valid {
+		if err := json.Unmarshal([]byte(requestJSON.String), &entry.Request); err != nil {
+			return nil, fmt.Errorf("decode stored request %d: %w", entry.ID, err)
+		}
+	}
+	entry.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+	return &entry, nil
+}
+
+// CompareWithEnrollment marks which recommended courses appear in the
+// student's current record.
+func CompareWithEnrollment(entry *RecommendationHistoryEntry, profile *StudentProfile) *EnrollmentComparison {
+	taken := make(map[string]bool)
+	for code := range profile.Competencies {
+		taken[normalizeCode(code)] = true
+	}
+	for _, code := range profile.CompletedCourses {
+		taken[normalizeCode(code)] = true
+	}
+
+	cmp := &EnrollmentComparison{TakenCourses: []string{}, NotTakenCourses: []string{}}
+	for _, c := range entry.Courses {
+		if taken[normalizeCode(c.CourseCode)] || taken[normalizeCode(c.CourseID)] {
+			cmp.TakenCourses = append(cmp.TakenCourses, c.CourseCode)
+		} else {
+			cmp.NotTakenCourses = append(cmp.NotTakenCourses, c.CourseCode)
+		}
+	}
+	if len(entry.Courses) > 0 {
+		cmp.FollowRate = float64(len(cmp.TakenCourses)) / float64(len(entry.Courses))
+	}
+	return cmp
+}
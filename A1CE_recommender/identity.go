@@ -0,0 +1,444 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var normalizedCodePattern = regexp.MustCompile(`^([A-Z]{2,4})([0-9]{3}[A-Z]?)$`)
+
+// Confidence of a completion match, strongest first.
+const (
+	MatchExactCode = "exact_code"
+	MatchTemplate  = "template"
+	MatchIdentity  = "mapped_identity"
+	MatchFuzzyName = "fuzzy_name"
+)
+
+// EquivalenceResolver groups course codes into equivalence classes: codes
+// the identity map sends to the same identity, and codes in the same
+// curriculum equivalence group, are one class.
+type EquivalenceResolver struct {
+	classOf map[string]string   // normalised code or identity -> class name
+	members map[string][]string // class name -> course codes
+}
+
+// NewEquivalenceResolver builds the classes from the identity map (normalised
+// code -> identity) and the curriculum's equivalence groups.
+func NewEquivalenceResolver(identityMap map[string]string, groups [][]string) *EquivalenceResolver {
+	parent := make(map[string]string)
+	var find func(x string) string
+	find = func(x string) string {
+		if p, ok := parent[x]; ok && p != x {
+			root := find(p)
+			parent[x] = root
+			return root
+		}
+		parent[x] = x
+		return x
+	}
+	union := func(a, b string) {
+		ra, rb := find(a), find(b)
+		if ra != rb {
+			parent[ra] = rb
+		}
+	}
+
+	display := make(map[string]string)
+	identities := make(map[string]string)
+	for code, identity := range identityMap {
+		c, id := "code:"+normalizeCode(code), "id:"+normalizeCode(identity)
+		union(c, id)
+		identities[id] = identity
+		if _, ok := display[c]; !ok {
+			display[c] = displayCode(code)
+		}
+	}
+	for _, group := range groups {
+		for _, code := range group {
+			c := "code:" + normalizeCode(code)
+			display[c] = code
+			union(c, "code:"+normalizeCode(group[0]))
+		}
+	}
+
+	// Name each class after its identity (the smallest, if the groups merged
+	// several), or its smallest code.
+	byRoot := make(map[string][]string)
+	for node := range parent {
+		byRoot[find(node)] = append(byRoot[find(node)], node)
+	}
+	r := &EquivalenceResolver{classOf: make(map[string]string), members: make(map[string][]string)}
+	for _, nodes := range byRoot {
+		sort.Strings(nodes)
+		name := ""
+		var codes []string
+		for _, n := range nodes {
+			if strings.HasPrefix(n, "id:") {
+				if name == "" {
+					name = identities[n]
+				}
+			} else {
+				codes = append(codes, display[n])
+			}
+		}
+		if len(codes) == 0 {
+			continue
+		}
+		sort.Strings(codes)
+		if name == "" {
+			name = codes[0]
+		}
+		for _, n := range nodes {
+			r.classOf[strings.SplitN(n, ":", 2)[1]] = name
+		}
+		r.members[name] = codes
+	}
+	return r
+}
+
+// displayCode restores the dash in a normalised code such as ENI101, as the
+// identity map stores its codes that way.
+func displayCode(code string) string {
+	return normalizedCodePattern.ReplaceAllString(code, "$1-$2")
+}
+
+// Class returns the equivalence class of a code or identity.
+func (r *EquivalenceResolver) Class(code string) (string, bool) {
+	class, ok := r.classOf[normalizeCode(code)]
+	return class, ok
+}
+
+// Equivalents returns the codes in code's class, or just code.
+func (r *EquivalenceResolver) Equivalents(code string) []string {
+	if class, ok := r.Class(code); ok {
+		return r.members[class]
+	}
+	return []string{code}
+}
+
+// HistoryRecord is one course on a student's record.
+type HistoryRecord struct {
+	CourseCode   string   `json:"course_code,omitempty"`
+	CompetencyID string   `json:"competency_id,omitempty"`
+	TemplateID   string   `json:"template_id,omitempty"`
+	CourseName   string   `json:"course_name,omitempty"`
+	Semester     string   `json:"semester,omitempty"`
+	Grade        *float64 `json:"grade,omitempty"`
+	Class        string   `json:"equivalence_class,omitempty"`
+	Equivalents  []string `json:"equivalents,omitempty"`
+	Source       string   `json:"source"` // profile, semester_cards, in_progress or planned
+}
+
+// CompletionMatch explains why a course counts as completed.
+type CompletionMatch struct {
+	CourseCode  string `json:"course_code"`
+	Confidence  string `json:"confidence"`
+	MatchedBy   string `json:"matched_by"`
+	Explanation string `json:"explanation"`
+}
+
+// ResolvedHistory indexes a student's record for matching catalog courses.
+type ResolvedHistory struct {
+	Records []*HistoryRecord
+
+	resolver  *EquivalenceResolver
+	codes     map[string]*HistoryRecord
+	templates map[string]*HistoryRecord
+	classes   map[string]*HistoryRecord
+	names     map[string]*HistoryRecord
+}
+
+func newResolvedHistory(resolver *EquivalenceResolver) *ResolvedHistory {
+	return &ResolvedHistory{
+		resolver:  resolver,
+		codes:     make(map[string]*HistoryRecord),
+		templates: make(map[string]*HistoryRecord),
+		classes:   make(map[string]*HistoryRecord),
+		names:     make(map[string]*HistoryRecord),
+	}
+}
+
+// Add puts a record on the history, merging it into an existing record for
+// the same course code.
+func (h *ResolvedHistory) Add(rec HistoryRecord) {
+	var existing *HistoryRecord
+	if rec.CourseCode != "" {
+		existing = h.codes[normalizeCode(rec.CourseCode)]
+	} else if rec.TemplateID != "" {
+		existing = h.templates[normalizeCode(rec.TemplateID)]
+	}
+	if existing != nil {
+		if existing.CompetencyID == "" {
+			existing.CompetencyID = rec.CompetencyID
+		}
+		if existing.TemplateID == "" {
+			existing.TemplateID = rec.TemplateID
+		}
+		if existing.CourseName == "" {
+			existing.CourseName = rec.CourseName
+		}
+		if existing.Semester == "" {
+			existing.Semester = rec.Semester
+		}
+		if existing.Grade == nil {
+			existing.Grade = rec.Grade
+		}
+		h.index(existing)
+		return
+	}
+
+	r := &rec
+	h.Records = append(h.Records, r)
+	h.index(r)
+}
+
+// index files the record under each of its keys, resolving its class first.
+func (h *ResolvedHistory) index(r *HistoryRecord) {
+	for _, key := range []string{r.CourseCode, r.TemplateID} {
+		if class, ok := h.resolver.Class(key); ok && key != "" && r.Class == "" {
+			r.Class = class
+			r.Equivalents = h.resolver.members[class]
+		}
+	}
+	if r.CourseCode != "" {
+		h.codes[normalizeCode(r.CourseCode)] = r
+	}
+	if r.CompetencyID != "" {
+		h.codes[normalizeCode(r.CompetencyID)] = r
+	}
+	if r.TemplateID != "" {
+		h.templates[normalizeCode(r.TemplateID)] = r
+	}
+	if r.Class != "" {
+		h.classes[r.Class] = r
+	}
+	if r.CourseName != "" {
+		h.names[smartCleanName(r.CourseName)] = r
+	}
+}
+
+// Match reports whether course is on the record, trying the exact code, the
+// template, the equivalence class and the cleaned name in that order.
+func (h *ResolvedHistory) Match(course Course) (CompletionMatch, bool) {
+	m := CompletionMatch{CourseCode: course.CourseCode}
+	for _, code := range []string{course.CourseCode, course.CourseID} {
+		if rec, ok := h.codes[normalizeCode(code)]; ok && code != "" {
+			m.Confidence, m.MatchedBy = MatchExactCode, recordLabel(rec)
+			m.Explanation = fmt.Sprintf("%s is on the student's record", code)
+			return m, true
+		}
+	}
+	if course.TemplateID != "" {
+		if rec, ok := h.templates[normalizeCode(course.TemplateID)]; ok {
+			m.Confidence, m.MatchedBy = MatchTemplate, recordLabel(rec)
+			m.Explanation = fmt.Sprintf("%s shares template %s with %s on the record", course.CourseCode, course.TemplateID, recordLabel(rec))
+			return m, true
+		}
+	}
+	for _, key := range []string{course.CourseCode, course.TemplateID} {
+		if key == "" {
+			continue
+		}
+		if class, ok := h.resolver.Class(key); ok {
+			if rec, ok := h.classes[class]; ok {
+				m.Confidence, m.MatchedBy = MatchIdentity, recordLabel(rec)
+				m.Explanation = fmt.Sprintf("%s and %s are equivalent (%s)", course.CourseCode, recordLabel(rec), class)
+				return m, true
+			}
+		}
+	}
+	if course.CourseName != "" {
+		if rec, ok := h.names[smartCleanName(course.CourseName)]; ok {
+			m.Confidence, m.MatchedBy = MatchFuzzyName, recordLabel(rec)
+			m.Explanation = fmt.Sprintf("%q matches the name of %s (%q) on the record", course.CourseName, recordLabel(rec), rec.CourseName)
+			return m, true
+		}
+	}
+	return m, false
+}
+
+// Completed reports whether course is on the record by any match.
+func (h *ResolvedHistory) Completed(course Course) bool {
+	_, ok := h.Match(course)
+	return ok
+}
+
+// HasCode reports whether the code, or a course equivalent to it, is on the
+// record.
+func (h *ResolvedHistory) HasCode(code string) bool {
+	if _, ok := h.codes[normalizeCode(code)]; ok {
+		return true
+	}
+	if class, ok := h.resolver.Class(code); ok {
+		_, ok := h.classes[class]
+		return ok
+	}
+	return false
+}
+
+// CodeSet lists the normalised codes on the record and their equivalents,
+// for code-level checks such as prerequisites.
+func (h *ResolvedHistory) CodeSet() map[string]bool {
+	set := make(map[string]bool)
+	for code := range h.codes {
+		set[code] = true
+	}
+	for class := range h.classes {
+		for _, code := range h.resolver.members[class] {
+			set[normalizeCode(code)] = true
+		}
+	}
+	return set
+}
+
+func recordLabel(rec *HistoryRecord) string {
+	if rec.CourseCode != "" {
+		return rec.CourseCode
+	}
+	if rec.TemplateID != "" {
+		return rec.TemplateID
+	}
+	return rec.CompetencyID
+}
+
+// resolver builds the equivalence classes for the student's curriculum.
+func (s *RecommenderService) resolver(profile *StudentProfile) *EquivalenceResolver {
+	var groups [][]string
+	if c, ok := s.curricula.Lookup(profile.UniversityCode, profile.CurriculumVersion); ok {
+		groups = c.EquivalenceGroups
+	}
+	return NewEquivalenceResolver(s.identityMap, groups)
+}
+
+// ResolveHistory gathers the student's record from the profile and from the
+// cards of every semester they studied in.
+func (s *RecommenderService) ResolveHistory(ctx context.Context, studentID string, profile *StudentProfile) *ResolvedHistory {
+	history := newResolvedHistory(s.resolver(profile))
+
+	for _, code := range profile.CompletedCourses {
+		if grade, ok := profile.Competencies[code]; ok {
+			history.Add(HistoryRecord{CourseCode: code, Semester: profile.CourseSemesters[code], Grade: &grade, Source: "profile"})
+		} else {
+			// Completed courses also list their template IDs.
+			history.Add(HistoryRecord{TemplateID: code, Source: "profile"})
+		}
+	}
+
+	uniqueSemesters := make(map[string]bool)
+	for _, sem := range profile.CourseSemesters {
+		if sem != "" {
+			uniqueSemesters[sem] = true
+		}
+	}
+
+	debugf("Scanning %d semesters for identity codes...", len(uniqueSemesters))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	for sem := range uniqueSemesters {
+		wg.Add(1)
+		go func(sem string) {
+			defer wg.Done()
+			cards, err := s.source.GetSemesterCompetencies(ctx, studentID, sem)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				profile.Completeness.Record(ProfilePartSemesterCards, fmt.Errorf("%s: %w", sem, err))
+				return
+			}
+			profile.Completeness.Record(ProfilePartSemesterCards, nil)
+			for _, card := range cards {
+				grade := card.Grade
+				history.Add(HistoryRecord{
+					CourseCode:   card.CourseCode,
+					CompetencyID: card.CompetencyID,
+					TemplateID:   card.TemplateID,
+					CourseName:   card.CourseName,
+					Semester:     card.Semester,
+					Grade:        &grade,
+					Source:       "semester_cards",
+				})
+			}
+		}(sem)
+	}
+	wg.Wait()
+
+	debugf("History scan complete. %d records", len(history.Records))
+	return history
+}
+
+// addPlanned puts a course planned in a roadmap on the history.
+func (h *ResolvedHistory) addPlanned(course Course, semester string) {
+	h.Add(HistoryRecord{
+		CourseCode:   course.CourseCode,
+		CompetencyID: course.CourseID,
+		TemplateID:   course.TemplateID,
+		CourseName:   course.CourseName,
+		Semester:     semester,
+		Source:       "planned",
+	})
+}
+
+// drop takes the records of the given normalised codes off the history.
+func (h *ResolvedHistory) drop(codes map[string]bool) {
+	kept := h.Records[:0]
+	for _, r := range h.Records {
+		if !codes[normalizeCode(r.CourseCode)] && !codes[normalizeCode(r.CompetencyID)] {
+			kept = append(kept, r)
+		}
+	}
+	h.Records = kept
+	h.codes = make(map[string]*HistoryRecord)
+	h.templates = make(map[string]*HistoryRecord)
+	h.classes = make(map[string]*HistoryRecord)
+	h.names = make(map[string]*HistoryRecord)
+	for _, r := range h.Records {
+		h.index(r)
+	}
+}
+
+// StudentHistory is a student's resolved record, with the catalog courses it
+// marks as completed when a semester is given.
+type StudentHistory struct {
+	StudentID           string              `json:"student_id"`
+	Semester            string              `json:"semester,omitempty"`
+	Records             []*HistoryRecord    `json:"records"`
+	CatalogMatches      []CompletionMatch   `json:"catalog_matches,omitempty"`
+	ProfileCompleteness ProfileCompleteness `json:"profile_completeness"`
+}
+
+// ExplainHistory resolves the student's record and, for a semester, says
+// which of its catalog courses count as completed and why.
+func (s *RecommenderService) ExplainHistory(ctx context.Context, studentID, semester string) (*StudentHistory, error) {
+	profile, err := s.source.GetStudentProfile(ctx, studentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch student profile: %w", err)
+	}
+	history := s.ResolveHistory(ctx, studentID, profile)
+	out := &StudentHistory{StudentID: studentID, Semester: semester, Records: history.Records}
+	sort.SliceStable(out.Records, func(i, j int) bool {
+		return recordLabel(out.Records[i]) < recordLabel(out.Records[j])
+	})
+
+	if semester != "" {
+		catalog, err := s.source.GetCourseCatalog(ctx, semester, profile.CurriculumVersion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch course catalog: %w", err)
+		}
+		s.ApplyIdentityMap(catalog.Courses)
+		out.CatalogMatches = []CompletionMatch{}
+		for _, course := range catalog.Courses {
+			if m, ok := history.Match(course); ok {
+				out.CatalogMatches = append(out.CatalogMatches, m)
+			}
+		}
+	}
+	out.ProfileCompleteness = profile.Completeness
+	return out, nil
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,49 +12,156 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
+var (
+	appConfig    = DefaultConfig()
+	historyStore *HistoryStore
+	roadmapStore *RoadmapStore
+)
+
 func main() {
-	if len(os.Args) > 1 && os.Args[1] == "eval" {
-		if err := EvaluateAllStudentsFromSQLite("a1ce_recommendation.db"); err != nil {
-			log.Fatalf("evaluation failed: %v", err)
+	command, args := splitCommand(os.Args[1:])
+
+	fs := flag.NewFlagSet("a1ce_recommender", flag.ExitOnError)
+	var mint mintTokenOptions
+	var roadmap roadmapOptions
+	var eval evalOptions
+	switch command {
+	case "eval":
+		if len(args) > 0 && args[0] == "tune" {
+			eval.tune, args = true, args[1:]
 		}
-		return
+		eval.register(fs)
+	case "mint-token":
+		mint.register(fs)
+	case "roadmap":
+		roadmap.register(fs)
 	}
-	
-	rules, err := loadCurriculumRules("curriculum_rules.json")
+	cfg, err := LoadConfig(fs, args)
 	if err != nil {
-		log.Println("(!) CRITICAL ERROR: Could not load curriculum_rules.json")
-	} else {
-		count := 0
-		for _, req := range rules {
-			if req {
-				count++
-			}
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	appConfig = cfg
+
+	switch command {
+	case "":
+		runServer(cfg)
+	case "eval":
+		if err := eval.run(cfg); err != nil {
+			log.Fatalf("evaluation failed: %v", err)
 		}
-		log.Printf("(✓) SUCCESS: Loaded %d REQUIRED rules from curriculum_rules.json\n", count)
+	case "mint-token":
+		if err := mint.run(cfg); err != nil {
+			log.Fatalf("mint-token failed: %v", err)
+		}
+	case "roadmap":
+		if err := roadmap.run(cfg); err != nil {
+			log.Fatalf("roadmap failed: %v", err)
+		}
+	default:
+		log.Fatalf("unknown command %q (expected eval, mint-token, roadmap or no command)", command)
 	}
+}
+
+// splitCommand separates a leading subcommand ("eval", "mint-token", "roadmap") from the flags.
+// "eval tune" is split off by main.
+func splitCommand(args []string) (string, []string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return args[0], args[1:]
+	}
+	return "", args
+}
+
+func runServer(cfg *Config) {
+	cfg.LogEffective()
 
-	// Load Identity Map on startup
-	idMap, err := loadIdentityMap("course_identities.json")
+	if err := checkPrereqGraph(cfg.DatabasePath); err != nil {
+		log.Fatalf("Invalid prerequisite graph: %v", err)
+	}
+	refs := referenceStoreFor(cfg)
+	if err := refs.Current().Err(); err != nil {
+		log.Fatalf("Invalid reference data: %v", err)
+	}
+	if cfg.ReferenceReloadInterval > 0 {
+		go refs.Watch(context.Background(), cfg.ReferenceReloadInterval)
+	}
+
+	profiles, err := loadWeightProfiles(cfg.WeightProfilesPath)
+	if err != nil {
+		log.Fatalf("Invalid weight profiles: %v", err)
+	}
+	if _, err := profiles.Get(cfg.WeightProfile); err != nil {
+		log.Fatalf("weight_profile: %v", err)
+	}
+	log.Printf("(✓) SUCCESS: Weight profiles: %s; requests default to %s", strings.Join(profiles.Names(), ", "), cfg.WeightProfile)
+
+	reg, err := loadCurricula(cfg.CurriculumDir)
 	if err != nil {
-		log.Println("(!) WARNING: Could not load course_identities.json")
+		log.Fatalf("Invalid curriculum definition: %v", err)
+	}
+	for _, c := range reg.Curricula {
+		log.Printf("(✓) SUCCESS: Loaded curriculum %s: %d required courses, %d areas, %d elective pools, %.0f credits",
+			c, len(c.RequiredCourses), len(c.DistributionAreas), len(c.ElectivePools), c.TotalCredits)
+		if missing := c.missingRequired(refs.Current().CurriculumRules); len(missing) > 0 {
+			log.Printf("(!) WARNING: Curriculum %s does not require %d course(s) %s marks required: %s",
+				c, len(missing), cfg.CurriculumRulesPath, strings.Join(missing, ", "))
+		}
+	}
+
+	if cfg.CatalogCacheEnabled {
+		catalogCache = NewCatalogCache(cfg.CatalogCacheTTL, cfg.CatalogCacheFile)
+	}
+
+	if _, err := NewDataSource(cfg, ""); err != nil {
+		log.Fatalf("Data source %q unavailable: %v", cfg.DataSource, err)
+	}
+
+	if cfg.HistoryEnabled {
+		historyStore, err = NewHistoryStore(cfg.HistoryDBPath)
+		if err != nil {
+			log.Fatalf("Recommendation history unavailable: %v", err)
+		}
+		defer historyStore.Close()
+	}
+
+	if cfg.RoadmapPersist {
+		roadmapStore, err = NewRoadmapStore(cfg.RoadmapDBPath)
+		if err != nil {
+			log.Printf("(!) WARNING: Roadmaps will not be stored: %v", err)
+		} else {
+			defer roadmapStore.Close()
+		}
+	}
+
+	var verifier *JWTVerifier
+	if cfg.AuthEnabled {
+		verifier, err = NewJWTVerifier(cfg)
+		if err != nil {
+			log.Fatalf("Authentication setup failed: %v (set AUTH_ENABLED=false to run without it)", err)
+		}
 	} else {
-		log.Printf("(✓) SUCCESS: Loaded %d IDENTITY mappings.", len(idMap))
+		log.Println("(!) WARNING: Authentication is disabled; any caller can read any student's data")
 	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/v1/recommendations", handleRecommendations)
+	mux.HandleFunc("GET /api/v1/recommendations/history", handleRecommendationHistory)
+	mux.HandleFunc("GET /api/v1/recommendations/{id}", handleRecommendationByID)
+	mux.HandleFunc("POST /api/v1/roadmap", handleRoadmap)
 	mux.HandleFunc("/api/v1/student-data", handleStudentData)
+	mux.HandleFunc("GET /api/v1/student-history", handleStudentHistory)
 	mux.HandleFunc("/api/v1/course-catalog", handleCourseCatalog)
 	mux.HandleFunc("/api/v1/health", handleHealth)
+	mux.HandleFunc("GET /api/v1/admin/catalog-cache", handleCatalogCacheStats)
+	mux.HandleFunc("POST /api/v1/admin/catalog-cache/invalidate", handleCatalogCacheInvalidate)
+	mux.HandleFunc("GET /api/v1/admin/reference-data", handleReferenceData)
 
-	handler := corsMiddleware(loggingMiddleware(authMiddleware(mux)))
+	handler := corsMiddleware(loggingMiddleware(authMiddleware(verifier, mux)))
 
 	server := &http.Server{
-		Addr:         ":8080",
+		Addr:         ":" + cfg.ServerPort,
 		Handler:      handler,
 		ReadTimeout:  60 * time.Second,
 		WriteTimeout: 30 * time.Second,
@@ -61,97 +171,18 @@ func main() {
 	log.Println("===========================================")
 	log.Println("A1CE Course Recommender API Server")
 	log.Println("===========================================")
-	log.Println("Server listening on: http://localhost:8080")
+	log.Printf("Server listening on: http://localhost:%s", cfg.ServerPort)
 
 	if err := server.ListenAndServe(); err != nil {
 		log.Fatalf("Server failed to start: %v", err)
 	}
 }
 
-// --- HELPER: Fetch Full History ---
-func fetchAllCompletedIdentityCodes(client *A1CEClient, studentID string, profile *StudentProfile, idMap map[string]string) map[string]bool {
-	completed := make(map[string]bool)
-
-	// 1. Codes from main profile
-	for _, c := range profile.CompletedCourses {
-		normC := normalizeCode(c)
-		completed[normC] = true
-		if mappedID, ok := idMap[normC]; ok {
-			completed[normalizeCode(mappedID)] = true
-		}
-	}
-
-	uniqueSemesters := make(map[string]bool)
-	for _, sem := range profile.CourseSemesters {
-		if sem != "" {
-			uniqueSemesters[sem] = true
-		}
-	}
-
-	log.Printf("Scanning %d semesters for identity codes...", len(uniqueSemesters))
-
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-
-	for sem := range uniqueSemesters {
-		wg.Add(1)
-		go func(s string) {
-			defer wg.Done()
-			cards, err := client.GetSemesterCompetencies(studentID, s)
-			if err == nil {
-				mu.Lock()
-				defer mu.Unlock()
-				for _, card := range cards {
-					completed[normalizeCode(card.CourseCode)] = true
-					completed[normalizeCode(card.CompetencyID)] = true
-					if card.TemplateID != "" {
-						completed[normalizeCode(card.TemplateID)] = true
-					}
-					if card.CourseName != "" {
-						completed["NAME:"+smartCleanName(card.CourseName)] = true
-					}
-					// Map check
-					if mappedID, ok := idMap[normalizeCode(card.CourseCode)]; ok {
-						completed[normalizeCode(mappedID)] = true
-					}
-				}
-			}
-		}(sem)
-	}
-	wg.Wait()
-
-	log.Printf("History scan complete. Total unique markers: %d", len(completed))
-	return completed
-}
-
-func loadIdentityMap(filename string) (map[string]string, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-	var mapping map[string]string
-	if err := json.NewDecoder(file).Decode(&mapping); err != nil {
-		return nil, err
-	}
-	normalized := make(map[string]string)
-	for k, v := range mapping {
-		normalized[normalizeCode(k)] = v
-	}
-	return normalized, nil
-}
-
-func loadCurriculumRules(filename string) (map[string]bool, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
+// debugf logs only when LOG_LEVEL is debug.
+func debugf(format string, args ...interface{}) {
+	if appConfig.LogLevel == "debug" {
+		log.Printf(format, args...)
 	}
-	defer file.Close()
-	var rules map[string]bool
-	if err := json.NewDecoder(file).Decode(&rules); err != nil {
-		return nil, err
-	}
-	return rules, nil
 }
 
 func normalizeCode(s string) string {
@@ -179,17 +210,49 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
 
+// handleStudentHistory shows how the student's record was resolved and, with
+// semester, which catalog courses it counts as completed.
+func handleStudentHistory(w http.ResponseWriter, r *http.Request) {
+	studentID := r.URL.Query().Get("student_id")
+	if studentID == "" {
+		sendError(w, http.StatusBadRequest, "MISSING_PARAM", "student_id is required", "")
+		return
+	}
+	if !authorizeStudent(w, r, studentID) {
+		return
+	}
+	source, err := NewDataSource(appConfig, getAuthorzationCred(r, "token"))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "DATA_SOURCE_ERROR", "Failed to open data source", err.Error())
+		return
+	}
+	history, err := NewRecommenderService(appConfig, source).ExplainHistory(r.Context(), studentID, r.URL.Query().Get("semester"))
+	if err != nil {
+		sendSourceError(w, err, "API_ERROR", "Failed to resolve student history")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
 func handleStudentData(w http.ResponseWriter, r *http.Request) {
 	studentID := r.URL.Query().Get("student_id")
 	if studentID == "" {
 		sendError(w, http.StatusBadRequest, "MISSING_PARAM", "student_id is required", "")
 		return
 	}
-	client := NewA1CEClient()
-	client.JWTToken = getAuthorzationCred(r, "token")
-	profile, err := client.GetStudentProfile(studentID)
+	if !authorizeStudent(w, r, studentID) {
+		return
+	}
+	source, err := NewDataSource(appConfig, getAuthorzationCred(r, "token"))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "DATA_SOURCE_ERROR", "Failed to open data source", err.Error())
+		return
+	}
+	profile, err := source.GetStudentProfile(r.Context(), studentID)
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, "API_ERROR", "Failed to fetch student data", err.Error())
+		sendSourceError(w, err, "API_ERROR", "Failed to fetch student data")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -204,40 +267,18 @@ func handleCourseCatalog(w http.ResponseWriter, r *http.Request) {
 		sendError(w, http.StatusBadRequest, "MISSING_REQUIRED_FIELD", "semester/version required", "")
 		return
 	}
-	client := NewA1CEClient()
-	client.JWTToken = getAuthorzationCred(r, "token")
-	client.UniversityCode = "CMKL"
-	catalog, err := client.GetCourseCatalog(semester, curriculumVersion)
+	source, err := NewDataSource(appConfig, getAuthorzationCred(r, "token"))
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, "A1CE_API_ERROR", "Failed to fetch catalog", err.Error())
+		sendError(w, http.StatusInternalServerError, "DATA_SOURCE_ERROR", "Failed to open data source", err.Error())
 		return
 	}
-
-	idMap, _ := loadIdentityMap("course_identities.json")
-	rules, _ := loadCurriculumRules("curriculum_rules.json")
-	normRules := make(map[string]bool)
-	if rules != nil {
-		for code, isReq := range rules {
-			if isReq {
-				normRules[normalizeCode(code)] = true
-			}
-		}
+	catalog, err := source.GetCourseCatalog(r.Context(), semester, curriculumVersion)
+	if err != nil {
+		sendSourceError(w, err, "A1CE_API_ERROR", "Failed to fetch catalog")
+		return
 	}
 
-	for i := range catalog.Courses {
-		c := &catalog.Courses[i]
-		normCode := normalizeCode(c.CourseCode)
-
-		// Inject Identity ID
-		if val, ok := idMap[normCode]; ok {
-			c.TemplateID = val
-		}
-		// Inject Required Status
-		if normRules[normCode] || normRules[normalizeCode(c.CourseID)] {
-			c.IsRequired = true
-			c.IsCore = true
-		}
-	}
+	NewRecommenderService(appConfig, source).ApplyIdentityMap(catalog.Courses)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(catalog)
@@ -254,203 +295,207 @@ func handleRecommendations(w http.ResponseWriter, r *http.Request) {
 		sendError(w, http.StatusBadRequest, "INVALID_REQUEST", "Failed to parse request body", err.Error())
 		return
 	}
+	if !validOptimizer(req.Optimizer) {
+		sendError(w, http.StatusBadRequest, "INVALID_REQUEST", "Unknown optimizer", req.Optimizer)
+		return
+	}
+	if req.Constraints != nil {
+		if err := req.Constraints.validate(); err != nil {
+			sendError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid constraints", err.Error())
+			return
+		}
+	}
+	if !authorizeStudent(w, r, req.StudentID) {
+		return
+	}
 
-	client := NewA1CEClient()
-	client.JWTToken = getAuthorzationCred(r, "token")
+	source, err := NewDataSource(appConfig, getAuthorzationCred(r, "token"))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "DATA_SOURCE_ERROR", "Failed to open data source", err.Error())
+		return
+	}
 
-	profile, err := client.GetStudentProfile(req.StudentID)
+	service := NewRecommenderService(appConfig, source)
+	service.History = historyStore
+	response, err := service.GenerateRecommendations(r.Context(), &req)
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, "A1CE_API_ERROR", "Failed to fetch profile", err.Error())
+		sendSourceError(w, err, "A1CE_API_ERROR", "Failed to generate recommendations")
 		return
 	}
 
-	idMap, _ := loadIdentityMap("course_identities.json")
-	completedMap := fetchAllCompletedIdentityCodes(client, req.StudentID, profile, idMap)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
 
-	// Interests
-	var successfulCourses []string
-	if req.PreviousSemester == "ALL" {
-		for courseCode, grade := range profile.Competencies {
-			if grade > 1.0 {
-				successfulCourses = append(successfulCourses, courseCode)
-			}
-		}
-	} else if req.PreviousSemester != "" {
-		semesterCards, err := client.GetSemesterCompetencies(req.StudentID, req.PreviousSemester)
-		if err == nil {
-			for _, card := range semesterCards {
-				if card.Grade > 1.0 {
-					successfulCourses = append(successfulCourses, card.CourseCode)
-				}
-			}
-		}
+func handleRoadmap(w http.ResponseWriter, r *http.Request) {
+	var req RoadmapRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "INVALID_REQUEST", "Failed to parse request body", err.Error())
+		return
+	}
+	if err := req.validate(); err != nil {
+		sendError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid roadmap request", err.Error())
+		return
+	}
+	if !authorizeStudent(w, r, req.StudentID) {
+		return
 	}
 
-	// Catalog
-	catalog, err := client.GetCourseCatalog(req.Semester, profile.CurriculumVersion)
+	source, err := NewDataSource(appConfig, getAuthorzationCred(r, "token"))
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, "A1CE_API_ERROR", "Failed to fetch catalog", err.Error())
+		sendError(w, http.StatusInternalServerError, "DATA_SOURCE_ERROR", "Failed to open data source", err.Error())
 		return
 	}
 
-	// Inject IDs into Catalog
-	for i := range catalog.Courses {
-		c := &catalog.Courses[i]
-		if val, ok := idMap[normalizeCode(c.CourseCode)]; ok {
-			c.TemplateID = val
-		}
+	service := NewRecommenderService(appConfig, source)
+	service.Roadmaps = roadmapStore
+	roadmap, err := service.GenerateRoadmap(r.Context(), &req)
+	if err != nil {
+		sendSourceError(w, err, "ROADMAP_ERROR", "Failed to generate roadmap")
+		return
 	}
 
-	profile.InterestWeights = make(map[string]float64)
-	if len(successfulCourses) > 0 {
-		for _, successCode := range successfulCourses {
-			parts := strings.Split(successCode, "-")
-			if len(parts) > 0 {
-				prefix := parts[0]
-				for _, course := range catalog.Courses {
-					if strings.HasPrefix(course.CourseCode, prefix) {
-						profile.InterestWeights[course.SubdomainID] += 5.0
-					}
-				}
-			}
-		}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(roadmap)
+}
+
+func handleRecommendationHistory(w http.ResponseWriter, r *http.Request) {
+	studentID := r.URL.Query().Get("student_id")
+	if studentID == "" {
+		sendError(w, http.StatusBadRequest, "MISSING_PARAM", "student_id is required", "")
+		return
 	}
-	totalWeight := 0.0
-	for _, w := range profile.InterestWeights {
-		totalWeight += w
+	if !authorizeStudent(w, r, studentID) {
+		return
 	}
-	if totalWeight > 0 {
-		for k := range profile.InterestWeights {
-			profile.InterestWeights[k] /= totalWeight
-		}
+	if historyStore == nil {
+		sendError(w, http.StatusNotImplemented, "HISTORY_DISABLED", "Recommendation history is disabled", "")
+		return
 	}
 
-	requirements := &CurriculumRequirements{
-		CurriculumVersion:    profile.CurriculumVersion,
-		RequiredCompetencies: profile.RequiredCompetencies,
-		TotalCreditsRequired: float64(profile.TotalCredits.Required),
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			sendError(w, http.StatusBadRequest, "INVALID_PARAM", "limit must be a positive integer", "")
+			return
+		}
+		limit = n
 	}
 
-	isCurriculumReq := make(map[string]bool)
-	rules, _ := loadCurriculumRules("curriculum_rules.json")
-	if rules != nil {
-		for code, req := range rules {
-			if req {
-				isCurriculumReq[normalizeCode(code)] = true
-			}
-		}
+	entries, err := historyStore.ListByStudent(studentID, limit)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "HISTORY_ERROR", "Failed to load recommendation history", err.Error())
+		return
 	}
 
-	var scoredCourses []RecommendedCourse
-	for _, course := range catalog.Courses {
-		// --- FILTERING ---
-		isCompleted := false
-		if course.TemplateID != "" && completedMap[normalizeCode(course.TemplateID)] {
-			isCompleted = true
-		}
-		if course.CourseName != "" {
-			cName := "NAME:" + smartCleanName(course.CourseName)
-			if completedMap[cName] {
-				isCompleted = true
-			}
-		}
-		if completedMap[normalizeCode(course.CourseCode)] {
-			isCompleted = true
-		}
-		if completedMap[normalizeCode(course.CourseID)] {
-			isCompleted = true
-		}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":          "success",
+		"student_id":      studentID,
+		"recommendations": entries,
+	})
+}
 
-		if isCompleted {
-			continue
-		}
+func handleRecommendationByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "INVALID_PARAM", "recommendation id must be numeric", "")
+		return
+	}
+	if historyStore == nil {
+		sendError(w, http.StatusNotImplemented, "HISTORY_DISABLED", "Recommendation history is disabled", "")
+		return
+	}
 
-		if !CheckPrerequisites(course, profile) {
-			continue
-		}
-		if strings.HasPrefix(course.CourseCode, "SOF-") {
-			continue
-		}
-		if course.SemesterOffered != "" && !strings.EqualFold(course.SemesterOffered, req.Semester) {
-			continue
+	// Another student's set answers like a missing one, so ids cannot be
+	// probed.
+	entry, err := historyStore.Get(id)
+	if err == nil {
+		if claims := claimsFromContext(r.Context()); claims != nil && !claims.CanAccessStudent(entry.StudentID) {
+			err = errHistoryNotFound
 		}
+	}
+	if errors.Is(err, errHistoryNotFound) {
+		sendError(w, http.StatusNotFound, "NOT_FOUND", "Recommendation not found", "")
+		return
+	}
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "HISTORY_ERROR", "Failed to load recommendation", err.Error())
+		return
+	}
 
-		compScore := CalculateCompetencyMatchScore(course, profile)
-		interestScore := CalculateInterestScore(course, profile)
-		progScore := CalculateProgramProgressScore(course, profile, requirements)
-		fitScore := 0.2*compScore + 0.6*interestScore + 0.2*progScore
-
-		displayCourse := CourseOutput{
-			CourseID:             course.CourseID,
-			TemplateID:           course.TemplateID,
-			CourseCode:           course.CourseCode,
-			CourseName:           course.CourseName,
-			Description:          course.Description,
-			CreditHours:          course.CreditHours,
-			SubdomainID:          course.SubdomainID,
-			TeachesCompetencies:  course.TeachesCompetencies,
-			SemesterOffered:      course.SemesterOffered,
-			RequiredCompetencies: make(map[string]string),
+	// Compare with what the student has actually taken since.
+	source, err := NewDataSource(appConfig, getAuthorzationCred(r, "token"))
+	if err == nil {
+		var profile *StudentProfile
+		profile, err = source.GetStudentProfile(r.Context(), entry.StudentID)
+		if err == nil {
+			entry.Enrollment = CompareWithEnrollment(entry, profile)
 		}
+	}
+	if err != nil {
+		entry.Enrollment = &EnrollmentComparison{Error: err.Error()}
+	}
 
-		if isCurriculumReq[normalizeCode(course.CourseCode)] ||
-			(course.TemplateID != "" && isCurriculumReq[normalizeCode(course.TemplateID)]) {
-			displayCourse.RequiredCompetencies["Required"] = "-"
-		} else {
-			displayCourse.RequiredCompetencies["Not Required"] = "-"
-		}
-		for _, missing := range profile.RequiredCompetencies {
-			if normalizeCode(missing) == normalizeCode(course.CourseCode) {
-				displayCourse.RequiredCompetencies["Required"] = "-"
-			}
-		}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entry)
+}
 
-		scoredCourses = append(scoredCourses, RecommendedCourse{
-			Course:                 course,
-			DisplayCourse:          displayCourse,
-			FitScore:               fitScore,
-			CompetencyMatchScore:   compScore,
-			InterestAlignmentScore: interestScore,
-			ProgramProgressScore:   progScore,
-			Reason:                 fmt.Sprintf("Interest Score: %.2f", interestScore),
-		})
-	}
-
-	for i := 0; i < len(scoredCourses); i++ {
-		for j := i + 1; j < len(scoredCourses); j++ {
-			if scoredCourses[i].FitScore < scoredCourses[j].FitScore {
-				scoredCourses[i], scoredCourses[j] = scoredCourses[j], scoredCourses[i]
-			}
-		}
+// handleReferenceData reports the loaded identity map, rules and curricula
+// with their versions and validation warnings.
+func handleReferenceData(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, "admin") {
+		return
 	}
-	recommendedSet := OptimizeCourseSet(scoredCourses, profile, requirements, req.MaxCreditLoad)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(referenceDataReport(appConfig))
+}
 
-	totalCredits := 0.0
-	for _, r := range recommendedSet {
-		totalCredits += r.Course.CreditHours
+func handleCatalogCacheStats(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, "admin") {
+		return
+	}
+	if catalogCache == nil {
+		sendError(w, http.StatusNotImplemented, "CACHE_DISABLED", "Catalog cache is disabled", "")
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(catalogCache.Stats())
+}
 
-	warningMsg := ""
-	if req.MaxCreditLoad > 60 {
-		warningMsg = "The student is currently doing a credit overload, make sure to already contact CMKL staff"
+// handleCatalogCacheInvalidate drops cached catalogs. The optional semester,
+// curriculum_version and university_code parameters narrow what is dropped.
+func handleCatalogCacheInvalidate(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, "admin") {
+		return
+	}
+	if catalogCache == nil {
+		sendError(w, http.StatusNotImplemented, "CACHE_DISABLED", "Catalog cache is disabled", "")
+		return
 	}
 
-	response := RecommendationSet{
-		StudentID:      req.StudentID,
-		Semester:       req.Semester,
-		RecommendedSet: recommendedSet,
-		TotalCredits:   totalCredits,
-		Metrics:        EvaluationMetrics{GoodnessScore: 0.85},
-		Metadata: RecommendationMetadata{
-			GenerationTimestamp: time.Now(),
-			AlgorithmVersion:    "1.31-Identity-JSON-Label",
-		},
-		Status:  "success",
-		Warning: warningMsg,
+	q := r.URL.Query()
+	semester := q.Get("semester")
+	universityCode := q.Get("university_code")
+	curriculumVersion := -1
+	if v := q.Get("curriculum_version"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "INVALID_PARAM", "curriculum_version must be an integer", "")
+			return
+		}
+		curriculumVersion = n
 	}
 
+	removed := catalogCache.Invalidate(func(k CatalogCacheKey) bool {
+		return (semester == "" || k.Semester == semester) &&
+			(universityCode == "" || k.UniversityCode == universityCode) &&
+			(curriculumVersion < 0 || k.CurriculumVersion == curriculumVersion)
+	})
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "invalidated": removed})
 }
 
 // ... (Standard Helpers: containsString, min, sendError, getAuthorzationCred, corsMiddleware, loggingMiddleware, authMiddleware) ...
@@ -481,6 +526,26 @@ func sendError(w http.ResponseWriter, statusCode int, errorCode, message, detail
 	})
 }
 
+// sendSourceError maps a data source failure to 401, 404 or 503 by its kind;
+// anything else is a 500 with errorCode.
+func sendSourceError(w http.ResponseWriter, err error, errorCode, message string) {
+	switch {
+	case errors.Is(err, ErrUnknownWeightProfile):
+		sendError(w, http.StatusBadRequest, "INVALID_REQUEST", message, err.Error())
+	case errors.Is(err, ErrIncompleteProfile):
+		sendError(w, http.StatusServiceUnavailable, "INCOMPLETE_PROFILE", message, err.Error())
+	case errors.Is(err, ErrUnauthorized):
+		sendError(w, http.StatusUnauthorized, "UPSTREAM_UNAUTHORIZED", message, err.Error())
+	case errors.Is(err, ErrNotFound):
+		sendError(w, http.StatusNotFound, "NOT_FOUND", message, err.Error())
+	case errors.Is(err, ErrUpstreamUnavailable), errors.Is(err, context.DeadlineExceeded):
+		w.Header().Set("Retry-After", strconv.Itoa(int(appConfig.BreakerCooldown.Seconds())))
+		sendError(w, http.StatusServiceUnavailable, "UPSTREAM_UNAVAILABLE", message, err.Error())
+	default:
+		sendError(w, http.StatusInternalServerError, errorCode, message, err.Error())
+	}
+}
+
 func getAuthorzationCred(r *http.Request, target_type string) string {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader != "" {
@@ -514,8 +579,149 @@ func loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func authMiddleware(next http.Handler) http.Handler {
+// authMiddleware validates the bearer token on every API call except the
+// health check and attaches its claims to the request context. A nil
+// verifier disables authentication.
+func authMiddleware(verifier *JWTVerifier, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		next.ServeHTTP(w, r)
+		if verifier == nil || r.URL.Path == "/api/v1/health" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := getAuthorzationCred(r, "token")
+		if token == "" {
+			sendError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Authentication required", errMissingToken.Error())
+			return
+		}
+		claims, err := verifier.Verify(token)
+		if err != nil {
+			sendError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Invalid token", err.Error())
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authContextKey{}, claims)))
 	})
 }
+
+type mintTokenOptions struct {
+	subject   string
+	studentID string
+	role      string
+	ttl       time.Duration
+}
+
+func (o *mintTokenOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.subject, "sub", "", "token subject")
+	fs.StringVar(&o.studentID, "student-id", "", "student_id claim (defaults to -sub)")
+	fs.StringVar(&o.role, "role", "student", "role claim: student, advisor or admin")
+	fs.DurationVar(&o.ttl, "ttl", time.Hour, "token lifetime")
+}
+
+// run prints an HS256 token signed with the configured secret, for exercising
+// the API locally.
+func (o *mintTokenOptions) run(cfg *Config) error {
+	if cfg.JWTSecret == "" {
+		return fmt.Errorf("jwt_secret is not set")
+	}
+	if o.subject == "" {
+		return fmt.Errorf("-sub is required")
+	}
+	studentID := o.studentID
+	if studentID == "" {
+		studentID = o.subject
+	}
+	claims := map[string]interface{}{
+		"sub":        o.subject,
+		"student_id": studentID,
+		"role":       o.role,
+		"exp":        time.Now().Add(o.ttl).Unix(),
+	}
+	if cfg.JWTIssuer != "" {
+		claims["iss"] = cfg.JWTIssuer
+	}
+	token, err := SignHS256(cfg.JWTSecret, claims)
+	if err != nil {
+		return err
+	}
+	fmt.Println(token)
+	return nil
+}
+
+type evalOptions struct {
+	compare string
+	tune    bool
+	tuning  tuneOptions
+}
+
+func (o *evalOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.compare, "compare", "", "comma-separated recommenders to evaluate side by side: "+strings.Join(RecommenderNames(), ", "))
+	o.tuning.register(fs)
+}
+
+// run evaluates eval_algorithm, with -compare every listed recommender on
+// the same split, or as "eval tune" searches eval_algorithm's parameters.
+func (o *evalOptions) run(cfg *Config) error {
+	if o.tune {
+		return TuneRecommender(cfg, &o.tuning)
+	}
+	if o.compare == "" {
+		return EvaluateAllStudentsFromSQLite(cfg)
+	}
+	var names []string
+	for _, name := range strings.Split(o.compare, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, evalRecommenderName(name))
+		}
+	}
+	return CompareRecommenders(cfg, names)
+}
+
+type roadmapOptions struct {
+	req   RoadmapRequest
+	token string
+}
+
+func (o *roadmapOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.req.StudentID, "student", "", "student ID")
+	fs.StringVar(&o.req.StartSemester, "start", "", "first semester to plan, e.g. \"Fall 2025\"")
+	fs.Float64Var(&o.req.MaxCreditLoad, "max-credits-per-semester", 24, "credit load per semester")
+	fs.IntVar(&o.req.MaxSemesters, "max-semesters", defaultRoadmapSemesters, "most semesters to plan")
+	fs.StringVar(&o.token, "token", "", "A1CE token (a1ce data source only)")
+}
+
+// run prints the student's roadmap as JSON and stores it when
+// roadmap_persist is set.
+func (o *roadmapOptions) run(cfg *Config) error {
+	if err := o.req.validate(); err != nil {
+		return err
+	}
+	if err := checkPrereqGraph(cfg.DatabasePath); err != nil {
+		return fmt.Errorf("invalid prerequisite graph: %w", err)
+	}
+	if err := referenceStoreFor(cfg).Current().Err(); err != nil {
+		return fmt.Errorf("invalid reference data: %w", err)
+	}
+	source, err := NewDataSource(cfg, o.token)
+	if err != nil {
+		return err
+	}
+
+	service := NewRecommenderService(cfg, source)
+	if cfg.RoadmapPersist {
+		store, err := NewRoadmapStore(cfg.RoadmapDBPath)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		service.Roadmaps = store
+	}
+
+	roadmap, err := service.GenerateRoadmap(context.Background(), &o.req)
+	if err != nil {
+		return err
+	}
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	return out.Encode(roadmap)
+}
@@ -10,12 +10,51 @@ type RecommendationRequest struct {
 	MaxSets          int                    `json:"max_sets"`
 	Constraints      *RecommendationFilters `json:"constraints,omitempty"`
 	PreviousSemester string                 `json:"previous_semester,omitempty"`
+	// StrictProfile refuses to recommend when the student's history could
+	// not be loaded.
+	StrictProfile bool `json:"strict_profile,omitempty"`
+	// Optimizer selects "greedy" or "exact"; empty uses the server default.
+	Optimizer string `json:"optimizer,omitempty"`
+	// WeightProfile selects the scoring weights; empty uses the server
+	// default.
+	WeightProfile string `json:"weight_profile,omitempty"`
 }
 
 type RecommendationFilters struct {
 	PreferredSubdomains []string `json:"preferred_subdomains,omitempty"`
-	ExcludeCourses      []string `json:"exclude_courses,omitempty"`
-	TimePreferences     string   `json:"time_preferences,omitempty"`
+	// PreferredSubdomainMode is "boost" (default) to favour preferred
+	// subdomains or "only" to drop every other subdomain.
+	PreferredSubdomainMode string   `json:"preferred_subdomain_mode,omitempty"`
+	ExcludeCourses         []string `json:"exclude_courses,omitempty"`
+	// IncludeCourses are "must take" courses placed in the set first.
+	IncludeCourses  []string         `json:"include_courses,omitempty"`
+	TimePreferences *TimePreferences `json:"time_preferences,omitempty"`
+}
+
+// TimePreferences says when the student wants classes. It decodes from an
+// object or, as older clients send, from a string such as
+// "mornings, no fridays".
+type TimePreferences struct {
+	Periods []string `json:"periods,omitempty"`  // morning, afternoon, evening
+	DaysOff []string `json:"days_off,omitempty"` // Mon ... Sun
+	// Mode is "prefer" (default) to rank matching courses higher or
+	// "require" to drop courses with no matching section.
+	Mode string `json:"mode,omitempty"`
+	Raw  string `json:"raw,omitempty"`
+}
+
+// CourseSection is one scheduled section of a course.
+type CourseSection struct {
+	CourseCode string    `json:"course_code"`
+	SectionID  string    `json:"section_id"`
+	Semester   string    `json:"semester,omitempty"`
+	Meetings   []Meeting `json:"meetings"`
+}
+
+type Meeting struct {
+	Day   string `json:"day"`   // Mon ... Sun
+	Start string `json:"start"` // 15:04
+	End   string `json:"end"`
 }
 
 // Student profile structures
@@ -32,6 +71,37 @@ type StudentProfile struct {
 	InterestWeights      map[string]float64    `json:"interest_weights"`
 	MaxCreditLoad        float64               `json:"max_credit_load"`
 	Semester             string                `json:"semester"`
+	Completeness         ProfileCompleteness   `json:"completeness"`
+
+	// PlannedCourses holds the normalised codes a roadmap has scheduled in
+	// earlier semesters.
+	PlannedCourses map[string]bool `json:"-"`
+	// InProgressCourses holds the normalised codes of the cards still in
+	// progress.
+	InProgressCourses map[string]bool `json:"-"`
+	// InterestSources lists, by subdomain, the completed courses behind
+	// InterestWeights.
+	InterestSources map[string][]string `json:"-"`
+}
+
+// Parts of a student profile that are fetched separately.
+const (
+	ProfilePartIdentity      = "identity"
+	ProfilePartHistory       = "competencies"
+	ProfilePartGraduation    = "graduation_status"
+	ProfilePartSemesterCards = "semester_cards"
+)
+
+// ProfileCompleteness reports which parts of a profile were loaded.
+type ProfileCompleteness struct {
+	Complete bool          `json:"complete"`
+	Parts    []ProfilePart `json:"parts"`
+}
+
+type ProfilePart struct {
+	Name   string `json:"name"`
+	Loaded bool   `json:"loaded"`
+	Error  string `json:"error,omitempty"`
 }
 
 // Course structures - Internal Logic & Catalog Response
@@ -70,25 +140,36 @@ type CourseOutput struct {
 // Curriculum requirements
 type CurriculumRequirements struct {
 	CurriculumVersion        int                `json:"curriculum_version"`
+	Curriculum               string             `json:"curriculum,omitempty"`
 	RequiredCompetencies     []string           `json:"required_competencies"`
 	DistributionRequirements map[string]float64 `json:"distribution_requirements"`
 	TotalCreditsRequired     float64            `json:"total_credits_required"`
+	DistributionAreas        []DistributionArea `json:"distribution_areas,omitempty"`
+	ElectivePools            []ElectivePool     `json:"elective_pools,omitempty"`
+	EquivalenceGroups        [][]string         `json:"equivalence_groups,omitempty"`
+	// AreaCredits holds the credits earned (or planned) per area and pool.
+	AreaCredits map[string]float64 `json:"area_credits,omitempty"`
 }
 
 // Recommendation output structures
 type RecommendedCourse struct {
-	Course                 Course       `json:"-"`
-	DisplayCourse          CourseOutput `json:"course"`
-	FitScore               float64      `json:"fit_score"`
-	MatchedCompetencies    []string     `json:"matched_competencies,omitempty"`
-	MissingCompetencies    []string     `json:"missing_competencies,omitempty"`
-	CompetencyMatchScore   float64      `json:"competency_match_score"`
-	InterestAlignmentScore float64      `json:"interest_alignment_score"`
-	ProgramProgressScore   float64      `json:"program_progress_score"`
-	Reason                 string       `json:"reason"`
+	Course                 Course            `json:"-"`
+	DisplayCourse          CourseOutput      `json:"course"`
+	FitScore               float64           `json:"fit_score"`
+	MatchedCompetencies    []string          `json:"matched_competencies,omitempty"`
+	MissingCompetencies    []string          `json:"missing_competencies,omitempty"`
+	CompetencyMatchScore   float64           `json:"competency_match_score"`
+	InterestAlignmentScore float64           `json:"interest_alignment_score"`
+	ProgramProgressScore   float64           `json:"program_progress_score"`
+	UnlockScore            float64           `json:"unlock_score"`
+	Reason                 string            `json:"reason"`
+	Explanation            *ScoreExplanation `json:"explanation,omitempty"`
+	Pinned                 bool              `json:"pinned,omitempty"`
+	Section                *CourseSection    `json:"section,omitempty"`
 }
 
 type RecommendationSet struct {
+	RecommendationID     int64                  `json:"recommendation_id,omitempty"`
 	StudentID            string                 `json:"student_id"`
 	Semester             string                 `json:"semester"`
 	RecommendedSet       []RecommendedCourse    `json:"recommended_set"`
@@ -98,6 +179,21 @@ type RecommendationSet struct {
 	Metadata             RecommendationMetadata `json:"metadata"`
 	Status               string                 `json:"status"`
 	Warning              string                 `json:"warning,omitempty"`
+	ProfileCompleteness  *ProfileCompleteness   `json:"profile_completeness,omitempty"`
+	// Label names the emphasis of RecommendedSet when alternatives are asked
+	// for with MaxSets; Alternatives holds the other plans.
+	Label        string            `json:"label,omitempty"`
+	Alternatives []AlternativePlan `json:"alternatives,omitempty"`
+}
+
+// AlternativePlan is one more course set offered next to the main one.
+type AlternativePlan struct {
+	Label                string              `json:"label"`
+	Emphasis             string              `json:"emphasis"`
+	RecommendedSet       []RecommendedCourse `json:"recommended_set"`
+	TotalCredits         float64             `json:"total_credits"`
+	Metrics              EvaluationMetrics   `json:"metrics"`
+	DistributionCoverage map[string]float64  `json:"distribution_coverage"`
 }
 
 type EvaluationMetrics struct {
@@ -111,6 +207,10 @@ type RecommendationMetadata struct {
 	GenerationTimestamp time.Time `json:"generation_timestamp"`
 	AlgorithmVersion    string    `json:"algorithm_version"`
 	ProcessingTimeMs    int64     `json:"processing_time_ms"`
+	Optimizer           string    `json:"optimizer"`
+	// WeightProfile names the scoring weights used; Weights are their values.
+	WeightProfile string         `json:"weight_profile,omitempty"`
+	Weights       *WeightProfile `json:"weights,omitempty"`
 }
 
 // A1CE API response structures
@@ -147,9 +247,16 @@ type A1CEGraduationStatus struct {
 }
 
 type CourseCatalogResponse struct {
-	Status            string   `json:"status"`
-	Semester          string   `json:"semester"`
-	CurriculumVersion int      `json:"curriculum_version"`
-	Courses           []Course `json:"courses"`
-	TotalCourses      int      `json:"total_courses"`
+	Status            string             `json:"status"`
+	Semester          string             `json:"semester"`
+	CurriculumVersion int                `json:"curriculum_version"`
+	Courses           []Course           `json:"courses"`
+	TotalCourses      int                `json:"total_courses"`
+	FailedSubdomains  []SubdomainFailure `json:"failed_subdomains,omitempty"`
+}
+
+// SubdomainFailure records a subdomain missing from a partial catalog.
+type SubdomainFailure struct {
+	SubdomainID string `json:"subdomain_id"`
+	Error       string `json:"error"`
 }
@@ -2,6 +2,14 @@ package main
 
 import "math"
 
+const (
+	// maxPerSubdomain caps how many courses one subdomain contributes to a set.
+	maxPerSubdomain = 10
+	// targetPriorityCount is how many missing graduation requirements a set
+	// tries to include before anything else.
+	targetPriorityCount = 3
+)
+
 // OptimizeCourseSet selects optimal combination of courses for the semester
 func OptimizeCourseSet(
 	scoredCourses []RecommendedCourse,
@@ -17,33 +25,12 @@ func OptimizeCourseSet(
 	targetCredits := maxCreditLoad // math.Min(maxCreditLoad, absoluteMax) removed
 
 	subdomainCount := make(map[string]int)
-	maxPerSubdomain := 10
 
 	// 1. Identify Graduation Requirements
-	graduationReqMap := make(map[string]bool)
-	for _, req := range requirements.RequiredCompetencies {
-		graduationReqMap[req] = true
-	}
+	graduationReqMap := graduationRequirementSet(requirements)
 
 	// 2. Priority Selection: Pick up to 3 distinct Graduation Requirements first
 	priorityCount := 0
-	targetPriorityCount := 3
-
-	// Helper to check if course satisfies a missing graduation requirement
-	isGraduationRequirement := func(c Course) bool {
-		if graduationReqMap[c.CourseCode] {
-			return true
-		}
-		if graduationReqMap[c.CourseID] {
-			return true
-		}
-		for _, taught := range c.TeachesCompetencies {
-			if graduationReqMap[taught] {
-				return true
-			}
-		}
-		return false
-	}
 
 	// Phase 1: Priority Pass
 	for _, courseRec := range scoredCourses {
@@ -53,7 +40,7 @@ func OptimizeCourseSet(
 
 		course := courseRec.Course
 
-		if !isGraduationRequirement(course) {
+		if !isGraduationRequirement(course, graduationReqMap) {
 			continue
 		}
 
@@ -98,19 +85,48 @@ func OptimizeCourseSet(
 	return selectedCourses
 }
 
+// graduationRequirementSet holds the outstanding required codes and their
+// equivalents.
+func graduationRequirementSet(requirements *CurriculumRequirements) map[string]bool {
+	graduationReqMap := make(map[string]bool)
+	for _, req := range requirements.RequiredCompetencies {
+		for _, code := range requirements.equivalents(req) {
+			graduationReqMap[code] = true
+		}
+	}
+	return graduationReqMap
+}
+
+// isGraduationRequirement checks if course satisfies a missing graduation requirement
+func isGraduationRequirement(c Course, graduationReqMap map[string]bool) bool {
+	if graduationReqMap[c.CourseCode] {
+		return true
+	}
+	if graduationReqMap[c.CourseID] {
+		return true
+	}
+	for _, taught := range c.TeachesCompetencies {
+		if graduationReqMap[taught] {
+			return true
+		}
+	}
+	return false
+}
+
 // EvaluateRecommendationSet calculates quality metrics
 func EvaluateRecommendationSet(
 	recommendedSet []RecommendedCourse,
 	studentProfile *StudentProfile,
 	requirements *CurriculumRequirements,
+	weights GoodnessWeights,
 ) *EvaluationMetrics {
 	skillCoverage := CalculateSkillCoverage(recommendedSet, studentProfile, requirements)
 	prereqCompliance := CalculatePrerequisiteCompliance(recommendedSet, studentProfile)
 	programProgressFit := CalculateProgramProgressFit(recommendedSet, studentProfile, requirements)
 
-	goodnessScore := 0.3*skillCoverage +
-		0.3*prereqCompliance +
-		0.4*programProgressFit
+	goodnessScore := weights.SkillCoverage*skillCoverage +
+		weights.PrerequisiteCompliance*prereqCompliance +
+		weights.ProgramProgress*programProgressFit
 
 	return &EvaluationMetrics{
 		GoodnessScore:           goodnessScore,
@@ -177,8 +193,11 @@ func CalculateProgramProgressFit(
 	if len(missingRequired) > 0 {
 		var covered []string
 		for _, courseRec := range recommendedSet {
-			taught := courseRec.Course.TeachesCompetencies
-			covered = append(covered, intersection(taught, missingRequired)...)
+			for _, req := range missingRequired {
+				if requirements.satisfies(courseRec.Course, req) {
+					covered = append(covered, req)
+				}
+			}
 		}
 		covered = unique(covered)
 		competencyProgress = float64(len(covered)) / float64(len(missingRequired))
@@ -187,20 +206,14 @@ func CalculateProgramProgressFit(
 	var distributionProgressScores []float64
 	distributionCoverage := make(map[string]float64)
 	for _, courseRec := range recommendedSet {
-		subdomain := courseRec.Course.SubdomainID
-		distributionCoverage[subdomain] += courseRec.Course.CreditHours
-	}
-
-	for subdomain, requiredCredits := range requirements.DistributionRequirements {
-		currentCredits := 0.0
-		if credits, exists := studentProfile.DistributionCredits[subdomain]; exists {
-			currentCredits = float64(credits.Earned)
+		if area, _, ok := requirements.areaFor(courseRec.Course); ok {
+			distributionCoverage[area] += courseRec.Course.CreditHours
 		}
+	}
 
-		recommendedCredits := 0.0
-		if credits, exists := distributionCoverage[subdomain]; exists {
-			recommendedCredits = credits
-		}
+	for area, requiredCredits := range requirements.DistributionRequirements {
+		currentCredits := requirements.AreaCredits[area]
+		recommendedCredits := distributionCoverage[area]
 
 		remainingGap := math.Max(0, requiredCredits-currentCredits)
 		areaProgress := 1.0
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// Optimizers a request can choose between.
+const (
+	OptimizerGreedy = "greedy"
+	OptimizerExact  = "exact"
+)
+
+// exactSearchBudget bounds the nodes the branch-and-bound search visits
+// before it settles for the best set found so far.
+const exactSearchBudget = 500000
+
+func validOptimizer(name string) bool {
+	return name == "" || name == OptimizerGreedy || name == OptimizerExact
+}
+
+type exactItem struct {
+	rec         RecommendedCourse
+	credits     float64
+	fit         float64
+	requirement bool
+	subdomain   string
+}
+
+// exactSolver is a depth-first branch and bound over include/exclude
+// decisions. The objective is lexicographic: first as many missing
+// graduation requirements as possible up to targetPriorityCount, then the
+// highest total fit, all within the credit load and maxPerSubdomain.
+//
+// When credits are whole (or half) numbers the bound is a knapsack dynamic
+// programme over the remaining items that ignores only the subdomain caps, so
+// the search usually just confirms the DP optimum. Otherwise it falls back to
+// the fractional-knapsack bound.
+type exactSolver struct {
+	items     []exactItem // requirements first, then by fit per credit
+	byDensity []int       // item indexes by fit per credit, for the bound
+	reqAfter  []int       // requirement items at or after each index
+	capacity  float64
+	reqBonus  float64
+
+	scale  float64       // credits -> DP units; 0 when the DP bound is unused
+	suffix [][][]float64 // suffix[i][c][r]: best fit from items i.. in c units with r requirements
+
+	chosen    []bool
+	subCount  map[string]int
+	best      []bool
+	bestValue float64
+	nodes     int
+}
+
+// OptimizeCourseSetExact picks the set maximising total FitScore under the
+// same constraints OptimizeCourseSet applies greedily. The greedy set seeds
+// the search, so the result is never worse; complete is false when the search
+// budget ran out before optimality was proven.
+func OptimizeCourseSetExact(
+	scoredCourses []RecommendedCourse,
+	studentProfile *StudentProfile,
+	requirements *CurriculumRequirements,
+	maxCreditLoad float64,
+) (selected []RecommendedCourse, complete bool) {
+	graduationReqMap := graduationRequirementSet(requirements)
+
+	seen := make(map[string]bool)
+	var items []exactItem
+	for _, rec := range scoredCourses {
+		if seen[rec.Course.CourseID] || rec.Course.CreditHours > maxCreditLoad {
+			continue
+		}
+		seen[rec.Course.CourseID] = true
+		items = append(items, exactItem{
+			rec:         rec,
+			credits:     math.Max(rec.Course.CreditHours, 0),
+			fit:         rec.FitScore,
+			requirement: isGraduationRequirement(rec.Course, graduationReqMap),
+			subdomain:   rec.Course.SubdomainID,
+		})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].requirement != items[j].requirement {
+			return items[i].requirement
+		}
+		return density(items[i]) > density(items[j])
+	})
+
+	s := &exactSolver{
+		items:    items,
+		capacity: maxCreditLoad,
+		reqBonus: 1,
+		chosen:   make([]bool, len(items)),
+		subCount: make(map[string]int),
+		reqAfter: make([]int, len(items)+1),
+	}
+	for i := len(items) - 1; i >= 0; i-- {
+		s.reqAfter[i] = s.reqAfter[i+1]
+		if items[i].requirement {
+			s.reqAfter[i]++
+		}
+		s.reqBonus += math.Max(items[i].fit, 0)
+	}
+	s.byDensity = make([]int, len(items))
+	for i := range items {
+		s.byDensity[i] = i
+	}
+	sort.SliceStable(s.byDensity, func(a, b int) bool {
+		return density(items[s.byDensity[a]]) > density(items[s.byDensity[b]])
+	})
+
+	s.buildSuffixBound()
+	s.seed(OptimizeCourseSet(scoredCourses, studentProfile, requirements, maxCreditLoad))
+	s.search(0, 0, 0, 0)
+
+	for i, item := range s.items {
+		if s.best[i] {
+			selected = append(selected, item.rec)
+		}
+	}
+	// Same presentation as the greedy set: requirements first, then by fit.
+	sort.SliceStable(selected, func(i, j int) bool {
+		ri := isGraduationRequirement(selected[i].Course, graduationReqMap)
+		rj := isGraduationRequirement(selected[j].Course, graduationReqMap)
+		if ri != rj {
+			return ri
+		}
+		return selected[i].FitScore > selected[j].FitScore
+	})
+	return selected, s.nodes < exactSearchBudget
+}
+
+func density(item exactItem) float64 {
+	if item.credits == 0 {
+		return math.Inf(1)
+	}
+	return item.fit / item.credits
+}
+
+func (s *exactSolver) value(fit float64, reqCount int) float64 {
+	return fit + s.reqBonus*float64(min(reqCount, targetPriorityCount))
+}
+
+// seed makes the greedy set the incumbent.
+func (s *exactSolver) seed(greedy []RecommendedCourse) {
+	s.best = make([]bool, len(s.items))
+	picked := make(map[string]bool)
+	for _, rec := range greedy {
+		picked[rec.Course.CourseID] = true
+	}
+	fit, reqCount := 0.0, 0
+	for i, item := range s.items {
+		if picked[item.rec.Course.CourseID] {
+			s.best[i] = true
+			fit += item.fit
+			if item.requirement {
+				reqCount++
+			}
+		}
+	}
+	s.bestValue = s.value(fit, reqCount)
+}
+
+func (s *exactSolver) search(i int, credits, fit float64, reqCount int) {
+	s.nodes++
+	if v := s.value(fit, reqCount); v > s.bestValue+1e-9 {
+		s.bestValue = v
+		copy(s.best, s.chosen)
+	}
+	if i == len(s.items) || s.nodes >= exactSearchBudget {
+		return
+	}
+	if s.bound(i, credits, fit, reqCount) <= s.bestValue+1e-9 {
+		return
+	}
+
+	item := s.items[i]
+	if credits+item.credits <= s.capacity && s.subCount[item.subdomain] < maxPerSubdomain {
+		s.chosen[i] = true
+		s.subCount[item.subdomain]++
+		next := reqCount
+		if item.requirement {
+			next++
+		}
+		s.search(i+1, credits+item.credits, fit+item.fit, next)
+		s.subCount[item.subdomain]--
+		s.chosen[i] = false
+	}
+	s.search(i+1, credits, fit, reqCount)
+}
+
+// dpCellLimit keeps the suffix tables to a few megabytes.
+const dpCellLimit = 4000000
+
+// buildSuffixBound fills s.suffix when every credit value is a multiple of
+// one unit or a half unit.
+func (s *exactSolver) buildSuffixBound() {
+	if s.capacity < 0 {
+		return
+	}
+	scale := 0.0
+	for _, candidate := range []float64{1, 2} {
+		whole := true
+		for _, item := range s.items {
+			scaled := item.credits * candidate
+			if math.Abs(scaled-math.Round(scaled)) > 1e-9 {
+				whole = false
+				break
+			}
+		}
+		if whole {
+			scale = candidate
+			break
+		}
+	}
+	units := int(math.Floor(s.capacity*scale + 1e-9))
+	if scale == 0 || (len(s.items)+1)*(units+1)*(targetPriorityCount+1) > dpCellLimit {
+		return
+	}
+
+	negInf := math.Inf(-1)
+	n := len(s.items)
+	s.suffix = make([][][]float64, n+1)
+	for i := range s.suffix {
+		s.suffix[i] = make([][]float64, units+1)
+		for c := range s.suffix[i] {
+			s.suffix[i][c] = make([]float64, targetPriorityCount+1)
+			for r := range s.suffix[i][c] {
+				s.suffix[i][c][r] = negInf
+			}
+		}
+	}
+	for c := 0; c <= units; c++ {
+		s.suffix[n][c][0] = 0
+	}
+
+	for i := n - 1; i >= 0; i-- {
+		item := s.items[i]
+		w := int(math.Round(item.credits * scale))
+		for c := 0; c <= units; c++ {
+			copy(s.suffix[i][c], s.suffix[i+1][c])
+			if w > c {
+				continue
+			}
+			for r, rest := range s.suffix[i+1][c-w] {
+				if math.IsInf(rest, -1) {
+					continue
+				}
+				with := r
+				if item.requirement {
+					with = min(r+1, targetPriorityCount)
+				}
+				if v := rest + item.fit; v > s.suffix[i][c][with] {
+					s.suffix[i][c][with] = v
+				}
+			}
+		}
+	}
+	s.scale = scale
+}
+
+// bound is an upper limit on the objective reachable from item i: the
+// suffix DP when available, else the fractional-knapsack relaxation of the
+// remaining items plus every requirement still reachable.
+func (s *exactSolver) bound(i int, credits, fit float64, reqCount int) float64 {
+	if s.scale > 0 {
+		units := int(math.Floor((s.capacity-credits)*s.scale + 1e-9))
+		best := math.Inf(-1)
+		for r, rest := range s.suffix[i][units] {
+			if v := s.value(fit+rest, reqCount+r); !math.IsInf(rest, -1) && v > best {
+				best = v
+			}
+		}
+		return best
+	}
+
+	remaining := s.capacity - credits
+	for _, j := range s.byDensity {
+		item := s.items[j]
+		if remaining <= 0 && item.credits > 0 {
+			break
+		}
+		if j < i || item.fit <= 0 || s.subCount[item.subdomain] >= maxPerSubdomain {
+			continue
+		}
+		if item.credits <= remaining {
+			fit += item.fit
+			remaining -= item.credits
+		} else {
+			fit += item.fit * remaining / item.credits
+			remaining = 0
+		}
+	}
+	return s.value(fit, reqCount+s.reqAfter[i])
+}
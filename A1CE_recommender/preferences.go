@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SubdomainModeBoost = "boost"
+	SubdomainModeOnly  = "only"
+
+	TimeModePrefer  = "prefer"
+	TimeModeRequire = "require"
+)
+
+var dayNames = map[string]string{
+	"mon": "Mon", "monday": "Mon", "mondays": "Mon",
+	"tue": "Tue", "tues": "Tue", "tuesday": "Tue", "tuesdays": "Tue",
+	"wed": "Wed", "wednesday": "Wed", "wednesdays": "Wed",
+	"thu": "Thu", "thur": "Thu", "thurs": "Thu", "thursday": "Thu", "thursdays": "Thu",
+	"fri": "Fri", "friday": "Fri", "fridays": "Fri",
+	"sat": "Sat", "saturday": "Sat", "saturdays": "Sat",
+	"sun": "Sun", "sunday": "Sun", "sundays": "Sun",
+}
+
+func (t *TimePreferences) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*t = parseTimePreferences(text)
+		return nil
+	}
+	type plain TimePreferences
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*t = TimePreferences(p)
+	for i, day := range t.DaysOff {
+		if canonical, ok := dayNames[strings.ToLower(day)]; ok {
+			t.DaysOff[i] = canonical
+		}
+	}
+	for i, period := range t.Periods {
+		t.Periods[i] = strings.ToLower(period)
+	}
+	return nil
+}
+
+// parseTimePreferences reads the free-text form: period words (morning,
+// afternoon, evening), days off written "no fridays" or "friday off", and
+// "only" for require mode. Anything else is ignored.
+func parseTimePreferences(text string) TimePreferences {
+	prefs := TimePreferences{Raw: text}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z')
+	})
+	for i, word := range words {
+		switch {
+		case strings.HasPrefix(word, "morning"):
+			prefs.Periods = append(prefs.Periods, "morning")
+		case strings.HasPrefix(word, "afternoon"):
+			prefs.Periods = append(prefs.Periods, "afternoon")
+		case strings.HasPrefix(word, "evening"):
+			prefs.Periods = append(prefs.Periods, "evening")
+		case word == "only" || word == "strict":
+			prefs.Mode = TimeModeRequire
+		}
+		if day, ok := dayNames[word]; ok {
+			negated := i > 0 && (words[i-1] == "no" || words[i-1] == "not")
+			off := i+1 < len(words) && (words[i+1] == "off" || words[i+1] == "free")
+			if negated || off {
+				prefs.DaysOff = append(prefs.DaysOff, day)
+			}
+		}
+	}
+	return prefs
+}
+
+func (t *TimePreferences) empty() bool {
+	return t == nil || (len(t.Periods) == 0 && len(t.DaysOff) == 0)
+}
+
+// meetingPeriod classifies a meeting by its start time.
+func meetingPeriod(start string) (string, error) {
+	at, err := time.Parse("15:04", start)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case at.Hour() < 12:
+		return "morning", nil
+	case at.Hour() < 17:
+		return "afternoon", nil
+	default:
+		return "evening", nil
+	}
+}
+
+// suits reports whether every meeting of section falls in a wanted period
+// and on a day the student has not kept free.
+func (t *TimePreferences) suits(section CourseSection) bool {
+	for _, m := range section.Meetings {
+		if containsString(t.DaysOff, m.Day) {
+			return false
+		}
+		if len(t.Periods) > 0 {
+			period, err := meetingPeriod(m.Start)
+			if err != nil || !containsString(t.Periods, period) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ScheduleSource provides the sections offered in a semester.
+type ScheduleSource interface {
+	GetSections(ctx context.Context, semester string) ([]CourseSection, error)
+}
+
+// FileScheduleSource reads sections from a JSON file:
+//
+//	{"sections": [{"course_code": "AIC-201", "section_id": "A", "semester": "Spring 2026",
+//	               "meetings": [{"day": "Mon", "start": "09:00", "end": "10:30"}]}]}
+//
+// Sections without a semester are offered every semester.
+type FileScheduleSource struct {
+	Path string
+}
+
+func (f *FileScheduleSource) GetSections(ctx context.Context, semester string) ([]CourseSection, error) {
+	var input struct {
+		Sections []CourseSection `json:"sections"`
+	}
+	if err := readJSONFile(f.Path, &input); err != nil {
+		return nil, err
+	}
+	sections := []CourseSection{}
+	for _, s := range input.Sections {
+		if s.Semester == "" || strings.EqualFold(s.Semester, semester) {
+			sections = append(sections, s)
+		}
+	}
+	return sections, nil
+}
+
+// validate rejects unknown modes.
+func (f *RecommendationFilters) validate() error {
+	switch f.PreferredSubdomainMode {
+	case "", SubdomainModeBoost, SubdomainModeOnly:
+	default:
+		return fmt.Errorf("preferred_subdomain_mode must be %s or %s; got %q", SubdomainModeBoost, SubdomainModeOnly, f.PreferredSubdomainMode)
+	}
+	if t := f.TimePreferences; t != nil {
+		switch t.Mode {
+		case "", TimeModePrefer, TimeModeRequire:
+		default:
+			return fmt.Errorf("time_preferences.mode must be %s or %s; got %q", TimeModePrefer, TimeModeRequire, t.Mode)
+		}
+		for _, p := range t.Periods {
+			if p != "morning" && p != "afternoon" && p != "evening" {
+				return fmt.Errorf("unknown time period %q", p)
+			}
+		}
+	}
+	return nil
+}
+
+// inPreferredSubdomain matches by subdomain ID or name.
+func inPreferredSubdomain(course Course, preferred []string) bool {
+	for _, p := range preferred {
+		if strings.EqualFold(p, course.SubdomainID) || (course.SubdomainName != "" && strings.EqualFold(p, course.SubdomainName)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPinned(course Course, constraints *RecommendationFilters) bool {
+	return constraints != nil && matchesCourseCode(course, constraints.IncludeCourses)
+}
+
+// applyPreferences adjusts scored courses for preferred subdomains and time
+// preferences by weights, attaches the best-suited section to each course, drops courses
+// the student's "require" time preferences rule out, and re-sorts by fit.
+// Pinned courses are never dropped.
+func applyPreferences(scored []RecommendedCourse, constraints *RecommendationFilters, sections []CourseSection, weights PreferenceWeights) []RecommendedCourse {
+	if constraints == nil {
+		return scored
+	}
+
+	byCourse := make(map[string][]CourseSection)
+	for _, s := range sections {
+		code := normalizeCode(s.CourseCode)
+		byCourse[code] = append(byCourse[code], s)
+	}
+
+	prefs := constraints.TimePreferences
+	kept := scored[:0]
+	for _, rec := range scored {
+		adjusted := make(map[string]float64)
+		if constraints.PreferredSubdomainMode != SubdomainModeOnly && inPreferredSubdomain(rec.Course, constraints.PreferredSubdomains) {
+			rec.FitScore += weights.PreferredSubdomain
+			adjusted["preferred_subdomain"] = weights.PreferredSubdomain
+		}
+
+		courseSections := byCourse[normalizeCode(rec.Course.CourseCode)]
+		if len(courseSections) > 0 {
+			rec.Section = &courseSections[0]
+			if !prefs.empty() {
+				var match *CourseSection
+				for i := range courseSections {
+					if prefs.suits(courseSections[i]) {
+						match = &courseSections[i]
+						break
+					}
+				}
+				if match != nil {
+					rec.Section = match
+				} else if prefs.Mode == TimeModeRequire && !rec.Pinned {
+					continue
+				} else {
+					rec.FitScore -= weights.TimeMismatch
+					adjusted["time_mismatch"] = -weights.TimeMismatch
+				}
+			}
+		}
+		rec.Explanation.addPreferences(adjusted)
+		kept = append(kept, rec)
+	}
+
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].FitScore > kept[j].FitScore
+	})
+	return kept
+}
+
+// splitPinned separates the pinned courses, keeping at most maxCreditLoad of
+// them in request order, from the rest. dropped lists pinned courses that did
+// not fit.
+func splitPinned(scored []RecommendedCourse, constraints *RecommendationFilters, maxCreditLoad float64) (pinned, rest []RecommendedCourse, dropped []string) {
+	if constraints == nil || len(constraints.IncludeCourses) == 0 {
+		return nil, scored, nil
+	}
+
+	byCode := make(map[string]RecommendedCourse)
+	for _, rec := range scored {
+		if rec.Pinned {
+			byCode[normalizeCode(rec.Course.CourseCode)] = rec
+			byCode[normalizeCode(rec.Course.CourseID)] = rec
+		} else {
+			rest = append(rest, rec)
+		}
+	}
+
+	credits := 0.0
+	taken := make(map[string]bool)
+	for _, code := range constraints.IncludeCourses {
+		rec, ok := byCode[normalizeCode(code)]
+		if !ok || taken[rec.Course.CourseID] {
+			continue
+		}
+		taken[rec.Course.CourseID] = true
+		if credits+rec.Course.CreditHours > maxCreditLoad {
+			dropped = append(dropped, rec.Course.CourseCode)
+			continue
+		}
+		credits += rec.Course.CreditHours
+		pinned = append(pinned, rec)
+	}
+	return pinned, rest, dropped
+}
+
+// missingPinned lists the requested include courses that are not among the
+// candidates, e.g. because they are completed, not offered or lack
+// prerequisites.
+func missingPinned(constraints *RecommendationFilters, candidates []Course) []string {
+	if constraints == nil {
+		return nil
+	}
+	var missing []string
+	for _, code := range constraints.IncludeCourses {
+		found := false
+		for _, c := range candidates {
+			if matchesCourseCode(c, []string{code}) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, code)
+		}
+	}
+	return missing
+}
+
+// loadSections reads the schedule when time preferences need it.
+func (s *RecommenderService) loadSections(ctx context.Context, semester string) ([]CourseSection, error) {
+	if s.schedule == nil {
+		return nil, nil
+	}
+	sections, err := s.schedule.GetSections(ctx, semester)
+	if err != nil {
+		return nil, fmt.Errorf("load schedule: %w", err)
+	}
+	return sections, nil
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// PrereqGraph is the prerequisite DAG: each course points at the courses it
+// requires. Codes are stored normalised; the first spelling seen is kept for
+// reports.
+type PrereqGraph struct {
+	prereqs    map[string][]string
+	dependents map[string][]string
+	known      map[string]bool
+	display    map[string]string
+}
+
+// PrereqIssues lists what Validate found. Dangling edges name a prerequisite
+// that is not a known course; they are still enforced.
+type PrereqIssues struct {
+	Cycles   [][]string
+	Dangling [][2]string // {course, prerequisite}
+}
+
+func (p PrereqIssues) Empty() bool {
+	return len(p.Cycles) == 0 && len(p.Dangling) == 0
+}
+
+func (p PrereqIssues) String() string {
+	var parts []string
+	for _, cycle := range p.Cycles {
+		parts = append(parts, "cycle "+strings.Join(cycle, " -> "))
+	}
+	for _, edge := range p.Dangling {
+		parts = append(parts, fmt.Sprintf("%s requires unknown %s", edge[0], edge[1]))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func NewPrereqGraph() *PrereqGraph {
+	return &PrereqGraph{
+		prereqs:    make(map[string][]string),
+		dependents: make(map[string][]string),
+		known:      make(map[string]bool),
+		display:    make(map[string]string),
+	}
+}
+
+func (g *PrereqGraph) name(code string) string {
+	norm := normalizeCode(code)
+	if _, ok := g.display[norm]; !ok {
+		g.display[norm] = strings.TrimSpace(code)
+	}
+	return norm
+}
+
+// AddCourse marks code as a course that exists in the catalog.
+func (g *PrereqGraph) AddCourse(code string) {
+	if code != "" {
+		g.known[g.name(code)] = true
+	}
+}
+
+// AddEdge records that course requires prereq. Duplicates are ignored.
+func (g *PrereqGraph) AddEdge(course, prereq string) {
+	if course == "" || prereq == "" {
+		return
+	}
+	c, p := g.name(course), g.name(prereq)
+	if containsString(g.prereqs[c], p) {
+		return
+	}
+	g.prereqs[c] = append(g.prereqs[c], p)
+	g.dependents[p] = append(g.dependents[p], c)
+}
+
+// Clone copies the graph so a request can add its catalog without touching
+// the shared one.
+func (g *PrereqGraph) Clone() *PrereqGraph {
+	c := NewPrereqGraph()
+	for k, v := range g.prereqs {
+		c.prereqs[k] = append([]string(nil), v...)
+	}
+	for k, v := range g.dependents {
+		c.dependents[k] = append([]string(nil), v...)
+	}
+	for k := range g.known {
+		c.known[k] = true
+	}
+	for k, v := range g.display {
+		c.display[k] = v
+	}
+	return c
+}
+
+// WithCourses returns a copy of g extended with the catalog's courses and
+// the prerequisites A1CE reports for them.
+func (g *PrereqGraph) WithCourses(courses []Course) *PrereqGraph {
+	c := g.Clone()
+	for _, course := range courses {
+		c.AddCourse(course.CourseCode)
+		for _, pre := range course.Prerequisites {
+			c.AddEdge(course.CourseCode, pre)
+		}
+	}
+	return c
+}
+
+// Validate reports every cycle (once, starting from its smallest code) and
+// every edge to an unknown course.
+func (g *PrereqGraph) Validate() PrereqIssues {
+	var issues PrereqIssues
+
+	const (
+		unvisited = iota
+		active
+		done
+	)
+	state := make(map[string]int)
+	var stack []string
+	var visit func(code string)
+	visit = func(code string) {
+		state[code] = active
+		stack = append(stack, code)
+		for _, pre := range g.prereqs[code] {
+			switch state[pre] {
+			case unvisited:
+				visit(pre)
+			case active:
+				start := len(stack) - 1
+				for stack[start] != pre {
+					start--
+				}
+				issues.Cycles = append(issues.Cycles, g.cycleNames(stack[start:]))
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[code] = done
+	}
+	for _, code := range g.codes() {
+		if state[code] == unvisited {
+			visit(code)
+		}
+	}
+
+	for _, code := range g.codes() {
+		for _, pre := range g.prereqs[code] {
+			if !g.known[pre] {
+				issues.Dangling = append(issues.Dangling, [2]string{g.display[code], g.display[pre]})
+			}
+		}
+	}
+	return issues
+}
+
+// cycleNames rotates a cycle to start at its smallest code and closes it.
+func (g *PrereqGraph) cycleNames(cycle []string) []string {
+	first := 0
+	for i, code := range cycle {
+		if code < cycle[first] {
+			first = i
+		}
+	}
+	var names []string
+	for i := range cycle {
+		names = append(names, g.display[cycle[(first+i)%len(cycle)]])
+	}
+	return append(names, names[0])
+}
+
+// codes lists every code in the graph in a stable order.
+func (g *PrereqGraph) codes() []string {
+	seen := make(map[string]bool)
+	for code := range g.known {
+		seen[code] = true
+	}
+	for code, pres := range g.prereqs {
+		seen[code] = true
+		for _, p := range pres {
+			seen[p] = true
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for code := range seen {
+		out = append(out, code)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Prerequisites returns the direct prerequisites of code.
+func (g *PrereqGraph) Prerequisites(code string) []string {
+	return g.names(g.prereqs[normalizeCode(code)])
+}
+
+// TransitivePrerequisites returns every course that must come before code.
+func (g *PrereqGraph) TransitivePrerequisites(code string) []string {
+	return g.names(g.reach(normalizeCode(code), g.prereqs))
+}
+
+// Unlocks counts the courses that directly or transitively require code.
+func (g *PrereqGraph) Unlocks(code string) int {
+	return len(g.reach(normalizeCode(code), g.dependents))
+}
+
+// reach walks edges from code, stopping at codes already seen so cycles
+// terminate.
+func (g *PrereqGraph) reach(code string, edges map[string][]string) []string {
+	seen := map[string]bool{code: true}
+	var out []string
+	queue := []string{code}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, c := range edges[next] {
+			if !seen[c] {
+				seen[c] = true
+				out = append(out, c)
+				queue = append(queue, c)
+			}
+		}
+	}
+	return out
+}
+
+func (g *PrereqGraph) names(codes []string) []string {
+	out := make([]string, 0, len(codes))
+	for _, c := range codes {
+		out = append(out, g.display[c])
+	}
+	sort.Strings(out)
+	return out
+}
+
+// MissingPrerequisites lists the direct prerequisites of course the student
+// has not completed. Completed prerequisites imply theirs, so the direct ones
+// are enough.
+func (g *PrereqGraph) MissingPrerequisites(course Course, completedMap map[string]bool) []string {
+	var missing []string
+	for _, pre := range g.prereqs[normalizeCode(course.CourseCode)] {
+		if !completedMap[pre] {
+			missing = append(missing, g.display[pre])
+		}
+	}
+	return missing
+}
+
+// UnlockScores scores each candidate by the share of the not yet completed
+// courses it unlocks, relative to the best gateway among the candidates, so
+// the scores run from 0 to 1.
+func (g *PrereqGraph) UnlockScores(candidates []Course, completedMap map[string]bool) map[string]float64 {
+	counts := make(map[string]int)
+	most := 0
+	for _, c := range candidates {
+		n := 0
+		for _, dep := range g.reach(normalizeCode(c.CourseCode), g.dependents) {
+			if !completedMap[dep] {
+				n++
+			}
+		}
+		counts[c.CourseID] = n
+		most = max(most, n)
+	}
+	scores := make(map[string]float64)
+	for id, n := range counts {
+		if most > 0 {
+			scores[id] = float64(n) / float64(most)
+		}
+	}
+	return scores
+}
+
+// loadPrereqGraph builds the graph from the Competency_prerequisites and
+// competency_data tables. A missing database gives an empty graph.
+func loadPrereqGraph(dbPath string) (*PrereqGraph, error) {
+	g := NewPrereqGraph()
+	if _, err := os.Stat(dbPath); err != nil {
+		return g, nil
+	}
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("open prerequisite db: %w", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT competency_code FROM competency_data`)
+	if err != nil {
+		return nil, fmt.Errorf("load competency codes: %w", err)
+	}
+	for rows.Next() {
+		var code sql.NullString
+		if err := rows.Scan(&code); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		g.AddCourse(code.String)
+	}
+	rows.Close()
+
+	prereqs, err := loadPrerequisites(db)
+	if err != nil {
+		return nil, fmt.Errorf("load prerequisites: %w", err)
+	}
+	for course, pres := range prereqs {
+		for _, pre := range pres {
+			g.AddEdge(course, pre)
+		}
+	}
+	return g, nil
+}
+
+var (
+	prereqGraphsMu sync.Mutex
+	prereqGraphs   = make(map[string]*PrereqGraph)
+)
+
+// prereqGraphFor loads the graph of dbPath once and logs what Validate finds.
+// Callers must Clone or WithCourses before adding to it.
+func prereqGraphFor(dbPath string) *PrereqGraph {
+	prereqGraphsMu.Lock()
+	defer prereqGraphsMu.Unlock()
+
+	if g, ok := prereqGraphs[dbPath]; ok {
+		return g
+	}
+	g, err := loadPrereqGraph(dbPath)
+	if err != nil {
+		log.Printf("(!) WARNING: Prerequisite graph unavailable: %v", err)
+		g = NewPrereqGraph()
+	} else if issues := g.Validate(); !issues.Empty() {
+		log.Printf("(!) WARNING: Prerequisite graph from %s: %s", dbPath, issues)
+	}
+	prereqGraphs[dbPath] = g
+	return g
+}
+
+// checkPrereqGraph refuses the graph of dbPath when it has cycles, which
+// would leave every course on them unschedulable. Dangling edges are only
+// logged.
+func checkPrereqGraph(dbPath string) error {
+	if issues := prereqGraphFor(dbPath).Validate(); len(issues.Cycles) > 0 {
+		return fmt.Errorf("%s: %s", dbPath, PrereqIssues{Cycles: issues.Cycles})
+	}
+	return nil
+}
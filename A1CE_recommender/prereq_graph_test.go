@@ -0,0 +1,54 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// prereqDB writes a database with the given courses and prerequisite edges
+// ({course, prerequisite}).
+func prereqDB(t *testing.T, courses []string, edges [][2]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "prereqs.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		`CREATE TABLE competency_data (competency_code TEXT)`,
+		`CREATE TABLE Competency_prerequisites (competency_code TEXT, prerequisite_code TEXT)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range courses {
+		if _, err := db.Exec(`INSERT INTO competency_data VALUES (?)`, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, e := range edges {
+		if _, err := db.Exec(`INSERT INTO Competency_prerequisites VALUES (?, ?)`, e[0], e[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestCheckPrereqGraph(t *testing.T) {
+	courses := []string{"TST-101", "TST-201", "TST-301"}
+
+	acyclic := prereqDB(t, courses, [][2]string{{"TST-201", "TST-101"}, {"TST-301", "TST-201"}, {"TST-301", "TST-999"}})
+	if err := checkPrereqGraph(acyclic); err != nil {
+		t.Errorf("acyclic graph with a dangling edge: %v", err)
+	}
+
+	cyclic := prereqDB(t, courses, [][2]string{{"TST-201", "TST-101"}, {"TST-301", "TST-201"}, {"TST-101", "TST-301"}})
+	err := checkPrereqGraph(cyclic)
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("cyclic graph: err = %v, want a cycle", err)
+	}
+}
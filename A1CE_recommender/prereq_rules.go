@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// maxMasteryGrade is the top of the A1CE mastery scale.
+const maxMasteryGrade = 4.0
+
+// passingGrade is the lowest grade that passes a course.
+const passingGrade = 1.0
+
+// PrerequisiteRules is the versioned rules file:
+//
+//	{"version": "2025-09", "description": "...",
+//	 "rules": [{"course": "AIC-201", "prerequisite": "AIC-101", "min_grade": 2.0}]}
+//
+// A rule means course may only be taken once the student holds prerequisite
+// at min_grade or better. The description is for the people editing it.
+type PrerequisiteRules struct {
+	Version     string             `json:"version"`
+	Description string             `json:"description,omitempty"`
+	Rules       []PrerequisiteRule `json:"rules"`
+}
+
+type PrerequisiteRule struct {
+	Course       string  `json:"course"`
+	Prerequisite string  `json:"prerequisite"`
+	MinGrade     float64 `json:"min_grade"`
+}
+
+// loadPrerequisiteRules reads and validates the rules file. A missing file
+// means there are no grade thresholds.
+func loadPrerequisiteRules(filename string) (*PrerequisiteRules, error) {
+	rules := &PrerequisiteRules{}
+	if err := readJSONFile(filename, rules); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return rules, nil
+		}
+		return nil, fmt.Errorf("read %s: %w", filename, err)
+	}
+	if err := rules.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return rules, nil
+}
+
+func (p *PrerequisiteRules) validate() error {
+	if len(p.Rules) > 0 && p.Version == "" {
+		return fmt.Errorf("version is required")
+	}
+	seen := make(map[[2]string]bool)
+	for i, r := range p.Rules {
+		if r.Course == "" || r.Prerequisite == "" {
+			return fmt.Errorf("rule %d: course and prerequisite are required", i+1)
+		}
+		if r.MinGrade <= 0 || r.MinGrade > maxMasteryGrade {
+			return fmt.Errorf("rule %d (%s): min_grade must be in (0, %.0f]", i+1, r.Course, maxMasteryGrade)
+		}
+		key := [2]string{normalizeCode(r.Course), normalizeCode(r.Prerequisite)}
+		if seen[key] {
+			return fmt.Errorf("rule %d: duplicate rule for %s requiring %s", i+1, r.Course, r.Prerequisite)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// byCourse indexes the thresholds by normalised course code.
+func (p *PrerequisiteRules) byCourse() map[string]map[string]float64 {
+	out := make(map[string]map[string]float64)
+	for _, r := range p.Rules {
+		code := normalizeCode(r.Course)
+		if out[code] == nil {
+			out[code] = make(map[string]float64)
+		}
+		out[code][r.Prerequisite] = r.MinGrade
+	}
+	return out
+}
+
+// studentGrade looks up the student's grade in code, matching codes
+// normalised. Courses planned in a roadmap have no grade yet and are assumed
+// to meet any threshold.
+func studentGrade(profile *StudentProfile, code string) (float64, bool) {
+	norm := normalizeCode(code)
+	if profile.PlannedCourses[norm] {
+		return maxMasteryGrade, true
+	}
+	if grade, ok := profile.Competencies[code]; ok {
+		return grade, true
+	}
+	for comp, grade := range profile.Competencies {
+		if normalizeCode(comp) == norm {
+			return grade, true
+		}
+	}
+	return 0, false
+}
+
+// passed reports whether grade passes code: it must reach passingGrade and
+// every threshold a rule sets on code as a prerequisite, since a course the
+// student cannot build on has to be taken again.
+func (s *RecommenderService) passed(code string, grade float64) bool {
+	if grade < passingGrade {
+		return false
+	}
+	norm := normalizeCode(code)
+	for _, prereqs := range s.gradeRules {
+		for prereq, minGrade := range prereqs {
+			if normalizeCode(prereq) == norm && grade < minGrade {
+				return false
+			}
+		}
+	}
+	return true
+}
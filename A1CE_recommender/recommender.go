@@ -2,6 +2,7 @@ package main
 
 import (
 	"math"
+	"sort"
 )
 
 // CheckPrerequisites verifies if student meets course requirements
@@ -15,8 +16,8 @@ func CheckPrerequisites(course Course, profile *StudentProfile) bool {
 
 	// Check competency prerequisites with grade requirements
 	for comp, minGrade := range course.RequiredCompetencies {
-		studentGrade, hasComp := profile.Competencies[comp]
-		if !hasComp || studentGrade < minGrade {
+		grade, hasComp := studentGrade(profile, comp)
+		if !hasComp || grade < minGrade {
 			return false
 		}
 	}
@@ -24,14 +25,26 @@ func CheckPrerequisites(course Course, profile *StudentProfile) bool {
 	return true
 }
 
-// CalculateCompetencyMatchScore measures how well student's competencies match course
-func CalculateCompetencyMatchScore(course Course, profile *StudentProfile) float64 {
+// competencyMatchParts are the inputs CalculateCompetencyMatchScore weights.
+type competencyMatchParts struct {
+	Prerequisites float64 // share of the required competencies the student holds
+	Grades        float64 // how well the student's grades meet the required ones
+	SkillGap      float64 // share of the taught competencies that are new
+}
+
+// competencyMatch works out the competency match inputs for course.
+func competencyMatch(course Course, profile *StudentProfile) competencyMatchParts {
 	requiredComps := getMapKeys(course.RequiredCompetencies)
 	studentComps := getMapKeys(profile.Competencies)
 	taughtComps := course.TeachesCompetencies
 
 	// Matched: Student has these required competencies
-	matched := intersection(requiredComps, studentComps)
+	var matched []string
+	for _, comp := range requiredComps {
+		if _, ok := studentGrade(profile, comp); ok {
+			matched = append(matched, comp)
+		}
+	}
 	// New skills: Course teaches competencies student doesn't have
 	newSkills := difference(taughtComps, studentComps)
 
@@ -52,11 +65,11 @@ func CalculateCompetencyMatchScore(course Course, profile *StudentProfile) float
 	if len(matched) > 0 {
 		for _, comp := range matched {
 			requiredGrade := course.RequiredCompetencies[comp]
-			studentGrade := profile.Competencies[comp]
-			if studentGrade >= requiredGrade {
+			grade, _ := studentGrade(profile, comp)
+			if grade >= requiredGrade {
 				gradeMatchScore += 1.0
 			} else {
-				gradeMatchScore += studentGrade / requiredGrade
+				gradeMatchScore += grade / requiredGrade
 			}
 		}
 		gradeMatchScore /= float64(len(matched))
@@ -64,15 +77,24 @@ func CalculateCompetencyMatchScore(course Course, profile *StudentProfile) float
 		gradeMatchScore = 1.0
 	}
 
-	// Weighted combination
-	return 0.4*prereqSatisfaction + 0.3*gradeMatchScore + 0.3*skillGapFill
+	return competencyMatchParts{Prerequisites: prereqSatisfaction, Grades: gradeMatchScore, SkillGap: skillGapFill}
+}
+
+// score weighs the parts into the competency match score.
+func (p competencyMatchParts) score(weights CompetencyMatchWeights) float64 {
+	return weights.Prerequisites*p.Prerequisites + weights.Grades*p.Grades + weights.SkillGap*p.SkillGap
+}
+
+// CalculateCompetencyMatchScore measures how well student's competencies match course
+func CalculateCompetencyMatchScore(course Course, profile *StudentProfile, weights CompetencyMatchWeights) float64 {
+	return competencyMatch(course, profile).score(weights)
 }
 
 // CalculateInterestScore measures alignment with student's interests
-func CalculateInterestScore(course Course, profile *StudentProfile) float64 {
+func CalculateInterestScore(course Course, profile *StudentProfile, weights InterestAlignmentWeights) float64 {
 	subdomain := course.SubdomainID
 
-	baseInterest := 0.1 // Default for unexplored areas
+	baseInterest := weights.Unexplored // Default for unexplored areas
 	if weight, exists := profile.InterestWeights[subdomain]; exists {
 		baseInterest = weight
 	}
@@ -85,59 +107,78 @@ func CalculateInterestScore(course Course, profile *StudentProfile) float64 {
 	return baseInterest
 }
 
-// CalculateProgramProgressScore measures how much course advances degree completion
-func CalculateProgramProgressScore(
-	course Course,
-	profile *StudentProfile,
-	requirements *CurriculumRequirements,
-) float64 {
+// programProgressParts are the inputs CalculateProgramProgressScore weights.
+type programProgressParts struct {
+	Required     float64  // share of the outstanding required courses satisfied
+	Distribution float64  // how much of its area's credit gap the course fills
+	Remaining    float64  // share of the degree still to complete
+	Urgency      float64  // multiplier for students early in their degree
+	Filled       []string // the required courses it satisfies
+	Area         string   // the area or elective pool it counts towards
+	AreaGap      float64  // credits that area still needs
+}
+
+// programProgress works out the program progress inputs for course.
+func programProgress(course Course, profile *StudentProfile, requirements *CurriculumRequirements) programProgressParts {
+	var parts programProgressParts
+
 	// Component 1: Required Competency Satisfaction
 	missingRequired := difference(requirements.RequiredCompetencies, getMapKeys(profile.Competencies))
-	taughtByCourse := course.TeachesCompetencies
-	requiredTaught := intersection(missingRequired, taughtByCourse)
-
-	requiredCompScore := 0.0
-	if len(missingRequired) > 0 {
-		requiredCompScore = float64(len(requiredTaught)) / float64(len(missingRequired))
+	for _, req := range missingRequired {
+		if requirements.satisfies(course, req) {
+			parts.Filled = append(parts.Filled, req)
+		}
 	}
-
-	// Component 2: Distribution Area Progress
-	subdomain := course.SubdomainID
-	requiredCredits := 0.0
-	if req, exists := requirements.DistributionRequirements[subdomain]; exists {
-		requiredCredits = req
+	if len(missingRequired) > 0 {
+		parts.Required = float64(len(parts.Filled)) / float64(len(missingRequired))
 	}
 
-	completedCredits := 0.0
-	if credits, exists := profile.DistributionCredits[subdomain]; exists {
-		completedCredits = float64(credits.Earned)
-	}
+	// Component 2: Distribution Area Progress (areas and elective pools of
+	// the curriculum definition)
+	area, requiredCredits, _ := requirements.areaFor(course)
+	completedCredits := requirements.AreaCredits[area]
+	parts.Area = area
 
-	distributionScore := 0.0
 	if requiredCredits > 0 {
 		creditGap := math.Max(0, requiredCredits-completedCredits)
+		parts.AreaGap = creditGap
 		if creditGap > 0 {
 			gapPercentage := creditGap / requiredCredits
-			distributionScore = math.Min(1.0, course.CreditHours/creditGap) * gapPercentage
+			parts.Distribution = math.Min(1.0, course.CreditHours/creditGap) * gapPercentage
 		} else {
-			distributionScore = 0.2 // Area already satisfied
+			parts.Distribution = 0.2 // Area already satisfied
 		}
 	} else {
-		distributionScore = 0.3 // Elective
+		parts.Distribution = 0.3 // Elective
 	}
 
 	// Component 3: Overall Degree Progress
 	totalProgress := float64(profile.TotalCredits.Earned) / requirements.TotalCreditsRequired
-	urgencyMultiplier := 1.0
+	parts.Remaining = 1.0 - totalProgress
+	parts.Urgency = 1.0
 	if totalProgress < 0.5 {
-		urgencyMultiplier = 1.2
+		parts.Urgency = 1.2
 	} else if totalProgress < 0.75 {
-		urgencyMultiplier = 1.1
+		parts.Urgency = 1.1
 	}
+	return parts
+}
+
+// CalculateProgramProgressScore measures how much course advances degree completion
+func CalculateProgramProgressScore(
+	course Course,
+	profile *StudentProfile,
+	requirements *CurriculumRequirements,
+	weights ProgramProgressWeights,
+) float64 {
+	return programProgress(course, profile, requirements).score(weights)
+}
 
-	progressScore := (0.5*requiredCompScore +
-		0.4*distributionScore +
-		0.1*(1.0-totalProgress)) * urgencyMultiplier
+// score weighs the parts into the program progress score, capped at 1.
+func (p programProgressParts) score(weights ProgramProgressWeights) float64 {
+	progressScore := (weights.Required*p.Required +
+		weights.Distribution*p.Distribution +
+		weights.Overall*p.Remaining) * p.Urgency
 
 	if progressScore > 1.0 {
 		progressScore = 1.0
@@ -202,16 +243,28 @@ func InferInterestAreas(completedCourses []string, courseCatalog []Course, compe
 
 // GetMatchedCompetencies returns competencies student has for this course
 func GetMatchedCompetencies(course Course, profile *StudentProfile) []string {
-	requiredComps := getMapKeys(course.RequiredCompetencies)
-	studentComps := getMapKeys(profile.Competencies)
-	return intersection(requiredComps, studentComps)
+	matched, _ := splitByGrade(course, profile)
+	return matched
 }
 
-// GetMissingCompetencies returns competencies student lacks for this course
+// GetMissingCompetencies returns competencies student lacks for this course,
+// or holds below the required grade
 func GetMissingCompetencies(course Course, profile *StudentProfile) []string {
-	requiredComps := getMapKeys(course.RequiredCompetencies)
-	studentComps := getMapKeys(profile.Competencies)
-	return difference(requiredComps, studentComps)
+	_, missing := splitByGrade(course, profile)
+	return missing
+}
+
+func splitByGrade(course Course, profile *StudentProfile) (met, unmet []string) {
+	for comp, minGrade := range course.RequiredCompetencies {
+		if grade, ok := studentGrade(profile, comp); ok && grade >= minGrade {
+			met = append(met, comp)
+		} else {
+			unmet = append(unmet, comp)
+		}
+	}
+	sort.Strings(met)
+	sort.Strings(unmet)
+	return met, unmet
 }
 
 // --- SHARED HELPER FUNCTIONS (Used by both recommender and optimizer) ---
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReferenceFileStatus describes one reference file as last loaded. Version is
+// the version the file declares, or a checksum of its content when it has
+// none. Error is the last failed reload; the previous content stays in use.
+type ReferenceFileStatus struct {
+	Path     string    `json:"path"`
+	Version  string    `json:"version,omitempty"`
+	Entries  int       `json:"entries"`
+	ModTime  time.Time `json:"modified_at"`
+	Size     int64     `json:"size_bytes"`
+	LoadedAt time.Time `json:"loaded_at"`
+	Warnings []string  `json:"warnings,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// ReferenceData is one consistent snapshot of the reference files. It is
+// never modified once published; a reload builds a new one.
+type ReferenceData struct {
+	IdentityMap     map[string]string             // normalised code -> identity
+	CurriculumRules map[string]bool               // normalised required codes
+	GradeRules      map[string]map[string]float64 // see PrerequisiteRules.byCourse
+
+	IdentityMapFile       ReferenceFileStatus
+	CurriculumRulesFile   ReferenceFileStatus
+	PrerequisiteRulesFile ReferenceFileStatus
+}
+
+// Err reports the files that failed to load. On the first load a failed
+// file has no content to fall back on, so runServer refuses to start.
+func (d *ReferenceData) Err() error {
+	var errs []error
+	for _, f := range d.Files() {
+		if f.Error != "" {
+			errs = append(errs, fmt.Errorf("%s: %s", f.Path, f.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Files lists the file statuses in a fixed order.
+func (d *ReferenceData) Files() []ReferenceFileStatus {
+	return []ReferenceFileStatus{d.IdentityMapFile, d.CurriculumRulesFile, d.PrerequisiteRulesFile}
+}
+
+// ReferenceStore loads the identity map, curriculum rules and prerequisite
+// rules once and swaps in a new snapshot when one of the files changes.
+// Codes are checked against competency_data in dbPath.
+type ReferenceStore struct {
+	identityPath string
+	rulesPath    string
+	prereqPath   string
+	dbPath       string
+
+	mu      sync.Mutex // serialises reloads
+	current atomic.Pointer[ReferenceData]
+	reloads atomic.Int64
+}
+
+// NewReferenceStore loads the files for the first time.
+func NewReferenceStore(identityPath, rulesPath, prereqPath, dbPath string) *ReferenceStore {
+	r := &ReferenceStore{identityPath: identityPath, rulesPath: rulesPath, prereqPath: prereqPath, dbPath: dbPath}
+	r.Reload()
+	return r
+}
+
+// Current returns the snapshot in use. Callers should take it once per
+// request so every lookup sees the same data.
+func (r *ReferenceStore) Current() *ReferenceData {
+	return r.current.Load()
+}
+
+// Reloads counts the snapshots swapped in after the first load.
+func (r *ReferenceStore) Reloads() int64 {
+	return r.reloads.Load()
+}
+
+// Reload re-reads the files whose size or modification time changed and
+// publishes a new snapshot if any did. A file that no longer loads keeps its
+// previous content.
+func (r *ReferenceStore) Reload() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev := r.current.Load()
+	next := &ReferenceData{}
+	if prev != nil {
+		*next = *prev
+	}
+	known := prereqGraphFor(r.dbPath)
+	changed := false
+
+	if prev == nil || fileChanged(r.identityPath, prev.IdentityMapFile) {
+		m, st := loadIdentityMap(r.identityPath, known)
+		if st.Error != "" && prev != nil {
+			keepPrevious(&st, prev.IdentityMapFile)
+		} else {
+			next.IdentityMap = m
+		}
+		next.IdentityMapFile = st
+		logReferenceFile("identity map", st)
+		changed = true
+	}
+	if prev == nil || fileChanged(r.rulesPath, prev.CurriculumRulesFile) {
+		m, st := loadCurriculumRules(r.rulesPath, known)
+		if st.Error != "" && prev != nil {
+			keepPrevious(&st, prev.CurriculumRulesFile)
+		} else {
+			next.CurriculumRules = m
+		}
+		next.CurriculumRulesFile = st
+		logReferenceFile("curriculum rules", st)
+		changed = true
+	}
+	if prev == nil || fileChanged(r.prereqPath, prev.PrerequisiteRulesFile) {
+		m, st := loadGradeRules(r.prereqPath, known)
+		if st.Error != "" && prev != nil {
+			keepPrevious(&st, prev.PrerequisiteRulesFile)
+		} else {
+			next.GradeRules = m
+		}
+		next.PrerequisiteRulesFile = st
+		logReferenceFile("prerequisite rules", st)
+		changed = true
+	}
+
+	if !changed {
+		return false
+	}
+	r.current.Store(next)
+	if prev != nil {
+		r.reloads.Add(1)
+	}
+	return true
+}
+
+// Watch polls the files every interval until ctx is done.
+func (r *ReferenceStore) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Reload()
+		}
+	}
+}
+
+// fileChanged compares the file on disk with the stamp it was loaded with.
+// A file that is still missing has not changed.
+func fileChanged(path string, st ReferenceFileStatus) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return !st.ModTime.IsZero()
+	}
+	return !info.ModTime().Equal(st.ModTime) || info.Size() != st.Size
+}
+
+// keepPrevious reports the content still in use after a failed reload, along
+// with the new error.
+func keepPrevious(st *ReferenceFileStatus, prev ReferenceFileStatus) {
+	st.Version, st.Entries, st.Warnings, st.LoadedAt = prev.Version, prev.Entries, prev.Warnings, prev.LoadedAt
+}
+
+func logReferenceFile(what string, st ReferenceFileStatus) {
+	if st.Error != "" {
+		log.Printf("(!) WARNING: Could not load %s %s: %s", what, st.Path, st.Error)
+		return
+	}
+	log.Printf("(✓) SUCCESS: Loaded %d %s entries from %s (version %s)", st.Entries, what, st.Path, st.Version)
+	for _, w := range st.Warnings {
+		log.Printf("(!) WARNING: %s: %s", st.Path, w)
+	}
+}
+
+// readReferenceFile reads path and stamps st with its size, time and
+// checksum.
+func readReferenceFile(path string) ([]byte, ReferenceFileStatus, error) {
+	st := ReferenceFileStatus{Path: path, LoadedAt: time.Now()}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, st, err
+	}
+	st.ModTime, st.Size = info.ModTime(), info.Size()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, st, err
+	}
+	sum := sha256.Sum256(data)
+	st.Version = "sha256:" + hex.EncodeToString(sum[:6])
+	return data, st, nil
+}
+
+type jsonEntry struct {
+	Key   string
+	Value json.RawMessage
+}
+
+// jsonObjectEntries decodes a JSON object keeping every key in file order,
+// so duplicates can be reported rather than silently overwritten.
+func jsonObjectEntries(data []byte) ([]jsonEntry, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+	var entries []jsonEntry
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		entries = append(entries, jsonEntry{Key: tok.(string), Value: value})
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// codeChecker collects the validation warnings for the codes in one file.
+type codeChecker struct {
+	known    *PrereqGraph
+	seen     map[string]string
+	unknown  []string
+	warnings []string
+}
+
+func newCodeChecker(known *PrereqGraph) *codeChecker {
+	return &codeChecker{known: known, seen: make(map[string]string)}
+}
+
+// check reports whether code is well formed and not a duplicate, noting
+// codes missing from competency_data.
+func (c *codeChecker) check(code string) bool {
+	if !courseCodePattern.MatchString(code) {
+		c.warnings = append(c.warnings, fmt.Sprintf("malformed course code %q skipped", code))
+		return false
+	}
+	norm := normalizeCode(code)
+	if first, ok := c.seen[norm]; ok {
+		c.warnings = append(c.warnings, fmt.Sprintf("duplicate key %s (also listed as %s); the later entry is used", code, first))
+		return true
+	}
+	c.seen[norm] = code
+	if len(c.known.known) > 0 && !c.known.known[norm] {
+		c.unknown = append(c.unknown, code)
+	}
+	return true
+}
+
+func (c *codeChecker) result() []string {
+	if len(c.unknown) > 0 {
+		sort.Strings(c.unknown)
+		c.warnings = append(c.warnings, fmt.Sprintf("not in competency_data (%d): %s", len(c.unknown), strings.Join(c.unknown, ", ")))
+	}
+	return c.warnings
+}
+
+// loadIdentityMap reads the course code -> identity map.
+func loadIdentityMap(path string, known *PrereqGraph) (map[string]string, ReferenceFileStatus) {
+	mapping := make(map[string]string)
+	data, st, err := readReferenceFile(path)
+	if err != nil {
+		st.Error = err.Error()
+		return mapping, st
+	}
+	entries, err := jsonObjectEntries(data)
+	if err != nil {
+		st.Error = fmt.Sprintf("invalid JSON: %v", err)
+		return mapping, st
+	}
+
+	codes := newCodeChecker(known)
+	for _, e := range entries {
+		var identity string
+		if err := json.Unmarshal(e.Value, &identity); err != nil || identity == "" {
+			codes.warnings = append(codes.warnings, fmt.Sprintf("%s: identity must be a non-empty string", e.Key))
+			continue
+		}
+		if codes.check(e.Key) {
+			mapping[normalizeCode(e.Key)] = identity
+		}
+	}
+	st.Entries = len(mapping)
+	st.Warnings = codes.result()
+	return mapping, st
+}
+
+// loadCurriculumRules reads the course code -> required flags and keeps the
+// required codes.
+func loadCurriculumRules(path string, known *PrereqGraph) (map[string]bool, ReferenceFileStatus) {
+	required := make(map[string]bool)
+	data, st, err := readReferenceFile(path)
+	if err != nil {
+		st.Error = err.Error()
+		return required, st
+	}
+	entries, err := jsonObjectEntries(data)
+	if err != nil {
+		st.Error = fmt.Sprintf("invalid JSON: %v", err)
+		return required, st
+	}
+
+	codes := newCodeChecker(known)
+	for _, e := range entries {
+		var isRequired bool
+		if err := json.Unmarshal(e.Value, &isRequired); err != nil {
+			codes.warnings = append(codes.warnings, fmt.Sprintf("%s: value must be true or false", e.Key))
+			continue
+		}
+		if codes.check(e.Key) {
+			required[normalizeCode(e.Key)] = isRequired
+		}
+	}
+	st.Entries = len(required)
+	for code, isRequired := range required {
+		if !isRequired {
+			delete(required, code)
+		}
+	}
+	st.Warnings = codes.result()
+	return required, st
+}
+
+// loadGradeRules reads the prerequisite grade thresholds. A missing file
+// means there are none.
+func loadGradeRules(path string, known *PrereqGraph) (map[string]map[string]float64, ReferenceFileStatus) {
+	_, st, err := readReferenceFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		st.Error = err.Error()
+		return map[string]map[string]float64{}, st
+	}
+	rules, err := loadPrerequisiteRules(path)
+	if err != nil {
+		st.Error = err.Error()
+		return map[string]map[string]float64{}, st
+	}
+	if rules.Version != "" {
+		st.Version = rules.Version
+	}
+	st.Entries = len(rules.Rules)
+
+	var unknown []string
+	seen := make(map[string]bool)
+	for _, r := range rules.Rules {
+		for _, code := range []string{r.Course, r.Prerequisite} {
+			norm := normalizeCode(code)
+			if !courseCodePattern.MatchString(code) {
+				st.Warnings = append(st.Warnings, fmt.Sprintf("malformed course code %q", code))
+			} else if len(known.known) > 0 && !known.known[norm] && !seen[norm] {
+				unknown = append(unknown, code)
+			}
+			seen[norm] = true
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		st.Warnings = append(st.Warnings, fmt.Sprintf("not in competency_data (%d): %s", len(unknown), strings.Join(unknown, ", ")))
+	}
+	return rules.byCourse(), st
+}
+
+var (
+	referenceStoresMu sync.Mutex
+	referenceStores   = make(map[string]*ReferenceStore)
+)
+
+// referenceStoreFor returns the shared store for cfg's reference files,
+// loading them on first use.
+func referenceStoreFor(cfg *Config) *ReferenceStore {
+	key := strings.Join([]string{cfg.IdentityMapPath, cfg.CurriculumRulesPath, cfg.PrerequisiteRulesPath, cfg.DatabasePath}, "|")
+
+	referenceStoresMu.Lock()
+	defer referenceStoresMu.Unlock()
+
+	if r, ok := referenceStores[key]; ok {
+		return r
+	}
+	r := NewReferenceStore(cfg.IdentityMapPath, cfg.CurriculumRulesPath, cfg.PrerequisiteRulesPath, cfg.DatabasePath)
+	referenceStores[key] = r
+	return r
+}
+
+// ReferenceDataReport is returned by the admin reference-data endpoint.
+type ReferenceDataReport struct {
+	Files          []ReferenceFileStatus `json:"files"`
+	Curricula      []CurriculumSummary   `json:"curricula"`
+	Reloads        int64                 `json:"reloads"`
+	ReloadInterval string                `json:"reload_interval"`
+}
+
+// CurriculumSummary describes one loaded curriculum definition.
+type CurriculumSummary struct {
+	Curriculum        string  `json:"curriculum"`
+	File              string  `json:"file"`
+	Default           bool    `json:"default"`
+	RequiredCourses   int     `json:"required_courses"`
+	DistributionAreas int     `json:"distribution_areas"`
+	ElectivePools     int     `json:"elective_pools"`
+	EquivalenceGroups int     `json:"equivalence_groups"`
+	TotalCredits      float64 `json:"total_credits"`
+}
+
+func referenceDataReport(cfg *Config) ReferenceDataReport {
+	store := referenceStoreFor(cfg)
+	report := ReferenceDataReport{
+		Files:          store.Current().Files(),
+		Curricula:      []CurriculumSummary{},
+		Reloads:        store.Reloads(),
+		ReloadInterval: cfg.ReferenceReloadInterval.String(),
+	}
+	for _, c := range curriculaFor(cfg.CurriculumDir).Curricula {
+		report.Curricula = append(report.Curricula, CurriculumSummary{
+			Curriculum:        c.String(),
+			File:              c.file,
+			Default:           c.Default,
+			RequiredCourses:   len(c.RequiredCourses),
+			DistributionAreas: len(c.DistributionAreas),
+			ElectivePools:     len(c.ElectivePools),
+			EquivalenceGroups: len(c.EquivalenceGroups),
+			TotalCredits:      c.TotalCredits,
+		})
+	}
+	return report
+}
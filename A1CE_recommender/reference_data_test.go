@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeReferenceFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReferenceStoreFirstLoad(t *testing.T) {
+	valid := map[string]string{
+		"identities.json": `{"AIC-101": "AI-INTRO"}`,
+		"rules.json":      `{"AIC-101": true}`,
+		"prereqs.json":    `{"version": "1", "rules": [{"course": "AIC-201", "prerequisite": "AIC-101", "min_grade": 2.0}]}`,
+	}
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr bool
+	}{
+		{"all valid", valid, false},
+		{"missing identity map", map[string]string{"rules.json": valid["rules.json"]}, true},
+		{"missing curriculum rules", map[string]string{"identities.json": valid["identities.json"]}, true},
+		{"missing prerequisite rules", map[string]string{"identities.json": valid["identities.json"], "rules.json": valid["rules.json"]}, false},
+		{"invalid identity map", map[string]string{"identities.json": `{"AIC-101":`, "rules.json": valid["rules.json"]}, true},
+		{"invalid prerequisite rules", map[string]string{"identities.json": valid["identities.json"], "rules.json": valid["rules.json"], "prereqs.json": `{"rules": [{"course": "AIC-201"}]}`}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeReferenceFiles(t, dir, tt.files)
+			store := NewReferenceStore(filepath.Join(dir, "identities.json"), filepath.Join(dir, "rules.json"),
+				filepath.Join(dir, "prereqs.json"), filepath.Join(dir, "missing.db"))
+			if err := store.Current().Err(); (err != nil) != tt.wantErr {
+				t.Errorf("Err() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReferenceStoreReloadKeepsLastGoodCopy(t *testing.T) {
+	dir := t.TempDir()
+	writeReferenceFiles(t, dir, map[string]string{
+		"identities.json": `{"AIC-101": "AI-INTRO"}`,
+		"rules.json":      `{"AIC-101": true}`,
+	})
+	store := NewReferenceStore(filepath.Join(dir, "identities.json"), filepath.Join(dir, "rules.json"),
+		filepath.Join(dir, "prereqs.json"), filepath.Join(dir, "missing.db"))
+	if err := store.Current().Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	writeReferenceFiles(t, dir, map[string]string{"identities.json": `{"AIC-101": "AI-INTRO", broken`})
+	if !store.Reload() {
+		t.Fatal("changed identity map was not reloaded")
+	}
+	refs := store.Current()
+	if refs.IdentityMap["AIC101"] != "AI-INTRO" {
+		t.Errorf("identity map = %v, want the last good copy", refs.IdentityMap)
+	}
+	if refs.IdentityMapFile.Error == "" {
+		t.Error("failed reload is not reported")
+	}
+}
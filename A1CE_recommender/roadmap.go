@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultRoadmapSemesters bounds a roadmap when the request gives no limit.
+const defaultRoadmapSemesters = 12
+
+// maxEmptyRoadmapSemesters is how many semesters in a row may have nothing
+// to schedule before a roadmap gives up; a course offered only in the other
+// term leaves one semester empty.
+const maxEmptyRoadmapSemesters = 2
+
+// RoadmapRequest asks for a plan of every remaining semester.
+type RoadmapRequest struct {
+	StudentID     string                 `json:"student_id"`
+	StartSemester string                 `json:"start_semester"`
+	MaxCreditLoad float64                `json:"max_credit_load"`
+	MaxSemesters  int                    `json:"max_semesters,omitempty"`
+	Optimizer     string                 `json:"optimizer,omitempty"`
+	WeightProfile string                 `json:"weight_profile,omitempty"`
+	Constraints   *RecommendationFilters `json:"constraints,omitempty"`
+}
+
+// Roadmap is a semester-by-semester plan up to graduation.
+type Roadmap struct {
+	StudentID             string                 `json:"student_id"`
+	CurriculumVersion     int                    `json:"curriculum_version"`
+	CreditsCompleted      float64                `json:"credits_completed"`
+	CreditsRequired       float64                `json:"credits_required"`
+	CreditsPlanned        float64                `json:"credits_planned"`
+	Semesters             []RoadmapSemester      `json:"semesters"`
+	RemainingRequirements []string               `json:"remaining_requirements"`
+	ReachesGraduation     bool                   `json:"reaches_graduation"`
+	Metadata              RecommendationMetadata `json:"metadata"`
+	Status                string                 `json:"status"`
+	Warning               string                 `json:"warning,omitempty"`
+}
+
+type RoadmapSemester struct {
+	Semester          string              `json:"semester"`
+	Courses           []RecommendedCourse `json:"courses"`
+	TotalCredits      float64             `json:"total_credits"`
+	CumulativeCredits float64             `json:"cumulative_credits"`
+}
+
+func (r *RoadmapRequest) validate() error {
+	if r.StudentID == "" {
+		return fmt.Errorf("student_id is required")
+	}
+	if r.StartSemester == "" {
+		return fmt.Errorf("start_semester is required")
+	}
+	if r.MaxCreditLoad <= 0 {
+		return fmt.Errorf("max_credit_load must be positive")
+	}
+	if r.MaxSemesters < 0 {
+		return fmt.Errorf("max_semesters must not be negative")
+	}
+	if !validOptimizer(r.Optimizer) {
+		return fmt.Errorf("unknown optimizer %q", r.Optimizer)
+	}
+	if r.Constraints != nil {
+		return r.Constraints.validate()
+	}
+	return nil
+}
+
+// nextSemester steps "Fall 2025" -> "Spring 2026" -> "Fall 2026". Names it
+// does not recognise become "<name> +1".
+func nextSemester(semester string) string {
+	parts := strings.Fields(semester)
+	if len(parts) == 2 {
+		if year, err := strconv.Atoi(parts[1]); err == nil {
+			switch strings.ToLower(parts[0]) {
+			case "fall":
+				return fmt.Sprintf("Spring %d", year+1)
+			case "spring", "summer":
+				return fmt.Sprintf("Fall %d", year)
+			}
+		}
+	}
+	return semester + " +1"
+}
+
+// GenerateRoadmap plans semester after semester from StartSemester until the
+// student's planned credits reach the curriculum total. Each semester is
+// chosen like a single recommendation, treating the courses planned before
+// it as completed, so prerequisites are ordered and semester_offered is
+// honoured. Courses the student is currently taking count as completed;
+// courses failed, or passed below a prerequisite grade rule, are planned
+// again.
+func (s *RecommenderService) GenerateRoadmap(ctx context.Context, req *RoadmapRequest) (*Roadmap, error) {
+	startTime := time.Now()
+
+	profile, err := s.source.GetStudentProfile(ctx, req.StudentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch student profile: %w", err)
+	}
+	if s.strictProfile && profile.Completeness.Missing(ProfilePartHistory) {
+		return nil, fmt.Errorf("%w: %s", ErrIncompleteProfile, partError(profile.Completeness, ProfilePartHistory))
+	}
+	profile.MaxCreditLoad = req.MaxCreditLoad
+
+	// Failed courses leave the record so they can be planned again.
+	failed := make(map[string]bool)
+	for code, grade := range profile.Competencies {
+		if !profile.InProgressCourses[normalizeCode(code)] && !s.passed(code, grade) {
+			failed[normalizeCode(code)] = true
+		}
+	}
+	profile.CompletedCourses = slices.DeleteFunc(profile.CompletedCourses, func(code string) bool {
+		return failed[normalizeCode(code)]
+	})
+
+	history := s.ResolveHistory(ctx, req.StudentID, profile)
+	history.drop(failed)
+	profile.PlannedCourses = make(map[string]bool)
+	for code := range profile.Competencies {
+		if !profile.InProgressCourses[normalizeCode(code)] {
+			continue
+		}
+		// Still in progress: assume it will be passed.
+		profile.CompletedCourses = append(profile.CompletedCourses, code)
+		history.Add(HistoryRecord{CourseCode: code, Semester: profile.CourseSemesters[code], Source: "in_progress"})
+		profile.PlannedCourses[normalizeCode(code)] = true
+	}
+
+	catalog, err := s.source.GetCourseCatalog(ctx, req.StartSemester, profile.CurriculumVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch course catalog for %s: %w", req.StartSemester, err)
+	}
+	s.ApplyIdentityMap(catalog.Courses)
+	profile.InterestWeights = InferInterestAreas(profile.CompletedCourses, catalog.Courses, profile.Competencies)
+	profile.InterestSources = interestSources(profile.CompletedCourses, catalog.Courses)
+
+	requirements := s.curriculumRequirements(profile, history, catalog.Courses)
+	remaining := append([]string(nil), requirements.RequiredCompetencies...)
+	requirements.RequiredCompetencies = remaining
+
+	maxSemesters := req.MaxSemesters
+	if maxSemesters <= 0 {
+		maxSemesters = defaultRoadmapSemesters
+	}
+	optimizer := req.Optimizer
+	if optimizer == "" {
+		optimizer = s.optimizer
+	}
+	weightProfile, weights, err := s.weightsFor(req.WeightProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	roadmap := &Roadmap{
+		StudentID:         req.StudentID,
+		CurriculumVersion: profile.CurriculumVersion,
+		CreditsCompleted:  max(float64(profile.TotalCredits.Earned+profile.TotalCredits.Working)-s.failedCredits(catalog.Courses, failed), 0),
+		CreditsRequired:   requirements.TotalCreditsRequired,
+		Semesters:         []RoadmapSemester{},
+		Status:            "success",
+	}
+	cumulative := roadmap.CreditsCompleted
+
+	var warnings []string
+	empty := 0
+	semester := req.StartSemester
+	for i := 0; i < maxSemesters && (cumulative < requirements.TotalCreditsRequired || len(remaining) > 0); i++ {
+		if i > 0 {
+			catalog, err = s.source.GetCourseCatalog(ctx, semester, profile.CurriculumVersion)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch course catalog for %s: %w", semester, err)
+			}
+			s.ApplyIdentityMap(catalog.Courses)
+		}
+		if len(catalog.FailedSubdomains) > 0 {
+			roadmap.Status = "partial"
+			warnings = append(warnings, fmt.Sprintf("Course catalog for %s is incomplete", semester))
+		}
+
+		semReq := &RecommendationRequest{
+			StudentID:     req.StudentID,
+			Semester:      semester,
+			MaxCreditLoad: req.MaxCreditLoad,
+			Constraints:   req.Constraints,
+		}
+		profile.Semester = semester
+		graph := s.prereqs.WithCourses(catalog.Courses)
+		candidates := s.filterCandidateCourses(catalog.Courses, profile, history, semReq, graph)
+		scored := s.scoreCourses(candidates, profile, requirements, graph.UnlockScores(candidates, history.CodeSet()), weights)
+		for j := range scored {
+			scored[j].Pinned = isPinned(scored[j].Course, req.Constraints)
+		}
+		scored = applyPreferences(scored, req.Constraints, nil, weights.Preferences)
+		if cumulative >= requirements.TotalCreditsRequired {
+			// Enough credits: only the outstanding requirements are left.
+			scored = filterRequirementCourses(scored, graduationRequirementSet(requirements))
+		}
+		pinned, unpinned, _ := splitPinned(scored, req.Constraints, req.MaxCreditLoad)
+		planned, _ := optimizeSet(optimizer, pinned, unpinned, profile, requirements, req.MaxCreditLoad)
+
+		if len(planned) == 0 {
+			roadmap.Semesters = append(roadmap.Semesters, RoadmapSemester{
+				Semester:          semester,
+				Courses:           []RecommendedCourse{},
+				CumulativeCredits: cumulative,
+			})
+			if empty++; empty >= maxEmptyRoadmapSemesters {
+				break
+			}
+			semester = nextSemester(semester)
+			continue
+		}
+		empty = 0
+
+		credits := calculateTotalCredits(planned)
+		cumulative += credits
+		roadmap.Semesters = append(roadmap.Semesters, RoadmapSemester{
+			Semester:          semester,
+			Courses:           planned,
+			TotalCredits:      credits,
+			CumulativeCredits: cumulative,
+		})
+
+		// What is planned now counts as completed for later semesters.
+		for _, rec := range planned {
+			c := rec.Course
+			history.addPlanned(c, semester)
+			for _, code := range []string{c.CourseCode, c.CourseID, c.TemplateID} {
+				if code != "" {
+					profile.CompletedCourses = append(profile.CompletedCourses, code)
+					profile.PlannedCourses[normalizeCode(code)] = true
+				}
+			}
+			remaining = removeSatisfiedRequirements(remaining, c, requirements)
+			requirements.recordPlanned(c)
+		}
+		requirements.RequiredCompetencies = remaining
+		profile.RequiredCompetencies = remaining
+
+		semester = nextSemester(semester)
+	}
+
+	// Empty semesters at the end plan nothing towards graduation.
+	if empty > 0 {
+		stop := len(roadmap.Semesters) - empty
+		if empty >= maxEmptyRoadmapSemesters {
+			warnings = append(warnings, fmt.Sprintf("No further courses can be scheduled from %s", roadmap.Semesters[stop].Semester))
+		}
+		roadmap.Semesters = roadmap.Semesters[:stop]
+	}
+	for _, sem := range roadmap.Semesters {
+		if len(sem.Courses) == 0 {
+			warnings = append(warnings, fmt.Sprintf("No courses can be scheduled in %s", sem.Semester))
+		}
+	}
+
+	roadmap.CreditsPlanned = cumulative - roadmap.CreditsCompleted
+	roadmap.RemainingRequirements = remaining
+	roadmap.ReachesGraduation = cumulative >= requirements.TotalCreditsRequired && len(remaining) == 0
+	if !roadmap.ReachesGraduation {
+		roadmap.Status = "partial"
+		warnings = append(warnings, fmt.Sprintf("The roadmap does not reach graduation: %.0f of %.0f credits, %d required course(s) unscheduled",
+			cumulative, requirements.TotalCreditsRequired, len(remaining)))
+	}
+	for _, part := range profile.Completeness.Failed() {
+		roadmap.Status = "partial"
+		warnings = append(warnings, profilePartWarning(part))
+	}
+	roadmap.Warning = strings.Join(warnings, "; ")
+	roadmap.Metadata = RecommendationMetadata{
+		GenerationTimestamp: time.Now(),
+		AlgorithmVersion:    algorithmVersion,
+		ProcessingTimeMs:    time.Since(startTime).Milliseconds(),
+		Optimizer:           optimizer,
+		WeightProfile:       weightProfile,
+		Weights:             &weights,
+	}
+
+	if s.Roadmaps != nil {
+		if err := s.Roadmaps.Save(roadmap); err != nil {
+			log.Printf("(!) WARNING: Could not store roadmap: %v", err)
+		}
+	}
+	return roadmap, nil
+}
+
+// failedCredits is the credit of the failed courses, which the graduation
+// status counts as earned. Credits come from competency_data, or from
+// courses for those it does not list, since a failed course need not be
+// offered in the start semester.
+func (s *RecommenderService) failedCredits(courses []Course, failed map[string]bool) float64 {
+	offered := make(map[string]float64)
+	for _, c := range courses {
+		offered[normalizeCode(c.CourseCode)] = c.CreditHours
+	}
+	var credits float64
+	for code := range failed {
+		if c, ok := s.credits[code]; ok {
+			credits += c
+		} else {
+			credits += offered[code]
+		}
+	}
+	return credits
+}
+
+func filterRequirementCourses(scored []RecommendedCourse, graduationReqMap map[string]bool) []RecommendedCourse {
+	var out []RecommendedCourse
+	for _, rec := range scored {
+		if isGraduationRequirement(rec.Course, graduationReqMap) {
+			out = append(out, rec)
+		}
+	}
+	return out
+}
+
+// removeSatisfiedRequirements drops the required codes course satisfies.
+func removeSatisfiedRequirements(required []string, course Course, requirements *CurriculumRequirements) []string {
+	out := []string{}
+	for _, code := range required {
+		if !requirements.satisfies(course, code) {
+			out = append(out, code)
+		}
+	}
+	return out
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// RoadmapStore keeps each student's latest roadmap in the Recommended_roadmap
+// table of its own database, apart from the tracked snapshot.
+type RoadmapStore struct {
+	db *sql.DB
+}
+
+const roadmapSchema = `
+CREATE TABLE IF NOT EXISTS Recommended_roadmap (
+    student_id TEXT,
+    competency_code TEXT,
+    PRIMARY KEY (student_id, competency_code)
+);`
+
+// roadmapColumns are added to a two-column table, as the snapshot has, when
+// missing.
+var roadmapColumns = []struct{ name, decl string }{
+	{"semester", "TEXT"},
+	{"semester_index", "INTEGER"},
+	{"credits", "REAL"},
+	{"fit_score", "REAL"},
+	{"created_at", "TEXT"},
+}
+
+// NewRoadmapStore opens dbPath, creating it and Recommended_roadmap if needed
+// and adding the planning columns to it.
+func NewRoadmapStore(dbPath string) (*RoadmapStore, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("create roadmap db directory: %w", err)
+	}
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("open roadmap db: %w", err)
+	}
+	if _, err := db.Exec(roadmapSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create roadmap table: %w", err)
+	}
+
+	existing := make(map[string]bool)
+	rows, err := db.Query(`PRAGMA table_info(Recommended_roadmap)`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			rows.Close()
+			db.Close()
+			return nil, err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+
+	for _, col := range roadmapColumns {
+		if existing[col.name] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE Recommended_roadmap ADD COLUMN %s %s`, col.name, col.decl)); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("add roadmap column %s: %w", col.name, err)
+		}
+	}
+	return &RoadmapStore{db: db}, nil
+}
+
+func (r *RoadmapStore) Close() error {
+	return r.db.Close()
+}
+
+// Save replaces the student's stored roadmap with roadmap.
+func (r *RoadmapStore) Save(roadmap *Roadmap) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM Recommended_roadmap WHERE student_id = ?`, roadmap.StudentID); err != nil {
+		return fmt.Errorf("clear roadmap: %w", err)
+	}
+	createdAt := roadmap.Metadata.GenerationTimestamp
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	for i, sem := range roadmap.Semesters {
+		for _, rec := range sem.Courses {
+			_, err := tx.Exec(`INSERT OR REPLACE INTO Recommended_roadmap
+				(student_id, competency_code, semester, semester_index, credits, fit_score, created_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?)`,
+				roadmap.StudentID, rec.Course.CourseCode, sem.Semester, i+1, rec.Course.CreditHours, rec.FitScore,
+				createdAt.UTC().Format(time.RFC3339Nano))
+			if err != nil {
+				return fmt.Errorf("insert roadmap course: %w", err)
+			}
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// roadmapFixtures writes a small catalog, where TST-202 runs only in the
+// fall, and student S1 (with cards) to a fixture directory. The TST codes
+// stay clear of the shipped reference data.
+func roadmapFixtures(t *testing.T, cards []A1CECompetencyCard, earned, working int) *FixtureDataSource {
+	t.Helper()
+	dir := t.TempDir()
+	course := func(code string, prereqs ...string) Course {
+		return Course{CourseID: code, CourseCode: code, CourseName: code, CreditHours: 3, SubdomainName: "Testing", Prerequisites: prereqs}
+	}
+	catalog := map[string][]Course{"courses": {
+		course("TST-101"), course("TST-102"), course("TST-201", "TST-101"), course("TST-202"),
+	}}
+	catalog["courses"][3].SemesterOffered = "Fall"
+	student := fixtureStudent{
+		Identity: A1CEStudentIdentity{StudentID: "S1", UniversityCode: "TST"},
+		Cards:    cards,
+		GraduationStatus: &A1CEGraduationStatus{
+			A1CECreditStatus: A1CECreditStatus{TotalCredits: A1CECredit{Earned: earned, Working: working, Required: 15}},
+		},
+	}
+	writeJSON := func(path string, v interface{}) {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, raw, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeJSON(filepath.Join(dir, "catalog.json"), catalog)
+	writeJSON(filepath.Join(dir, "students", "S1.json"), student)
+
+	source, err := NewFixtureDataSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return source
+}
+
+// plannedIn maps each planned course code to its roadmap semester.
+func plannedIn(roadmap *Roadmap) map[string]string {
+	out := make(map[string]string)
+	for _, sem := range roadmap.Semesters {
+		for _, rec := range sem.Courses {
+			out[rec.Course.CourseCode] = sem.Semester
+		}
+	}
+	return out
+}
+
+func TestRoadmapReplansFailedCourses(t *testing.T) {
+	source := roadmapFixtures(t, []A1CECompetencyCard{
+		{CourseCode: "TST-101", Grade: 0.5, Status: "Recorded", Semester: "Fall 2024"},
+		{CourseCode: "TST-102", Status: "In Progress", Semester: "Spring 2025"},
+	}, 3, 3)
+	svc := NewRecommenderService(DefaultConfig(), source)
+
+	roadmap, err := svc.GenerateRoadmap(context.Background(), &RoadmapRequest{
+		StudentID: "S1", StartSemester: "Fall 2025", MaxCreditLoad: 3, Optimizer: OptimizerGreedy,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	planned := plannedIn(roadmap)
+	if planned["TST-101"] == "" {
+		t.Errorf("failed TST-101 was not planned again: %v", planned)
+	}
+	if _, ok := planned["TST-102"]; ok {
+		t.Errorf("in-progress TST-102 was planned again: %v", planned)
+	}
+	if semesterKey(planned["TST-201"]) <= semesterKey(planned["TST-101"]) {
+		t.Errorf("TST-201 planned in %q, not after its prerequisite TST-101 (%q)", planned["TST-201"], planned["TST-101"])
+	}
+	if roadmap.CreditsCompleted != 3 {
+		t.Errorf("credits completed = %v, want 3 without the failed course", roadmap.CreditsCompleted)
+	}
+}
+
+// semesterCatalogSource serves, like the A1CE API, only the courses offered
+// in the requested semester.
+type semesterCatalogSource struct {
+	*FixtureDataSource
+}
+
+func (s semesterCatalogSource) GetCourseCatalog(ctx context.Context, semester string, curriculumVersion int) (*CourseCatalogResponse, error) {
+	catalog, err := s.FixtureDataSource.GetCourseCatalog(ctx, semester, curriculumVersion)
+	if err != nil {
+		return nil, err
+	}
+	catalog.Courses = slices.DeleteFunc(catalog.Courses, func(c Course) bool { return !offeredIn(c, semester) })
+	return catalog, nil
+}
+
+func TestRoadmapFailedCreditsOutsideStartCatalog(t *testing.T) {
+	source := roadmapFixtures(t, []A1CECompetencyCard{
+		{CourseCode: "TST-101", Grade: 3.0, Status: "Recorded", Semester: "Fall 2024"},
+		{CourseCode: "TST-202", Grade: 0.5, Status: "Recorded", Semester: "Fall 2024"},
+	}, 6, 0)
+	svc := NewRecommenderService(DefaultConfig(), semesterCatalogSource{source})
+	svc.credits = map[string]float64{"TST101": 3, "TST202": 3}
+
+	// TST-202 runs only in the fall, so the Spring 2026 catalog lacks it.
+	roadmap, err := svc.GenerateRoadmap(context.Background(), &RoadmapRequest{
+		StudentID: "S1", StartSemester: "Spring 2026", MaxCreditLoad: 3, Optimizer: OptimizerGreedy,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roadmap.CreditsCompleted != 3 {
+		t.Errorf("credits completed = %v, want 3 without the failed TST-202", roadmap.CreditsCompleted)
+	}
+}
+
+func TestRoadmapReplansCoursesBelowGradeRule(t *testing.T) {
+	source := roadmapFixtures(t, []A1CECompetencyCard{
+		{CourseCode: "TST-101", Grade: 1.5, Status: "Recorded", Semester: "Fall 2024"},
+		{CourseCode: "TST-102", Grade: 3.0, Status: "Recorded", Semester: "Fall 2024"},
+	}, 6, 0)
+	svc := NewRecommenderService(DefaultConfig(), source)
+	svc.gradeRules = map[string]map[string]float64{"TST201": {"TST-101": 2.0}}
+
+	roadmap, err := svc.GenerateRoadmap(context.Background(), &RoadmapRequest{
+		StudentID: "S1", StartSemester: "Fall 2025", MaxCreditLoad: 3, Optimizer: OptimizerGreedy,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	planned := plannedIn(roadmap)
+	if planned["TST-101"] == "" {
+		t.Errorf("TST-101 below the grade rule was not planned again: %v", planned)
+	}
+	if _, ok := planned["TST-102"]; ok {
+		t.Errorf("passed TST-102 was planned again: %v", planned)
+	}
+}
+
+func TestRoadmapSkipsEmptySemesters(t *testing.T) {
+	source := roadmapFixtures(t, []A1CECompetencyCard{
+		{CourseCode: "TST-101", Grade: 3.0, Status: "Recorded", Semester: "Fall 2024"},
+		{CourseCode: "TST-102", Grade: 3.0, Status: "Recorded", Semester: "Fall 2024"},
+		{CourseCode: "TST-201", Grade: 3.0, Status: "Recorded", Semester: "Spring 2025"},
+	}, 9, 0)
+	svc := NewRecommenderService(DefaultConfig(), source)
+
+	roadmap, err := svc.GenerateRoadmap(context.Background(), &RoadmapRequest{
+		StudentID: "S1", StartSemester: "Spring 2026", MaxCreditLoad: 3, Optimizer: OptimizerGreedy,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := plannedIn(roadmap)["TST-202"]; got != "Fall 2026" {
+		t.Errorf("TST-202 planned in %q, want Fall 2026", got)
+	}
+	var semesters []string
+	for _, sem := range roadmap.Semesters {
+		semesters = append(semesters, sem.Semester)
+	}
+	if len(semesters) != 2 || semesters[0] != "Spring 2026" || semesters[1] != "Fall 2026" {
+		t.Errorf("semesters = %v, want an empty Spring 2026, then Fall 2026", semesters)
+	}
+	if !strings.Contains(roadmap.Warning, "No further courses can be scheduled from Spring 2027") {
+		t.Errorf("warning = %q", roadmap.Warning)
+	}
+}
+
+func TestRoadmapStoreKeepsItsOwnFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "roadmaps.db")
+	store, err := NewRoadmapStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	roadmap := &Roadmap{StudentID: "S1", Semesters: []RoadmapSemester{{
+		Semester: "Fall 2025",
+		Courses:  []RecommendedCourse{{Course: Course{CourseCode: "TST-101", CreditHours: 3}, FitScore: 0.5}},
+	}}}
+	if err := store.Save(roadmap); err != nil {
+		t.Fatal(err)
+	}
+	var semester string
+	if err := store.db.QueryRow(`SELECT semester FROM Recommended_roadmap WHERE student_id = 'S1' AND competency_code = 'TST-101'`).Scan(&semester); err != nil {
+		t.Fatal(err)
+	}
+	if semester != "Fall 2025" {
+		t.Errorf("semester = %q, want Fall 2025", semester)
+	}
+}
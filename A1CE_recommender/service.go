@@ -1,72 +1,177 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"maps"
 	"sort"
+	"strings"
 	"time"
 )
 
+const algorithmVersion = "1.4-Service"
+
+// ErrIncompleteProfile is returned in strict mode when the student's history
+// could not be loaded.
+var ErrIncompleteProfile = errors.New("incomplete student profile")
+
 type RecommenderService struct {
-	a1ceClient *A1CEClient
+	source          DataSource
+	identityMap     map[string]string
+	curriculumRules map[string]bool
+	gradeRules      map[string]map[string]float64
+	strictProfile   bool
+	optimizer       string
+	weightProfile   string
+	weights         WeightProfiles
+	schedule        ScheduleSource
+	prereqs         *PrereqGraph
+	curricula       *CurriculumRegistry
+	credits         map[string]float64 // by normalised code
+
+	// History, when set, stores every generated set; Roadmaps, when set,
+	// stores each student's latest roadmap.
+	History  *HistoryStore
+	Roadmaps *RoadmapStore
 }
 
-func NewRecommenderService() *RecommenderService {
+// NewRecommenderService builds a service around a data source. For A1CE the
+// source must already carry the caller's credentials.
+func NewRecommenderService(cfg *Config, source DataSource) *RecommenderService {
+	refs := referenceStoreFor(cfg).Current()
+
+	var schedule ScheduleSource
+	if cfg.ScheduleFile != "" {
+		schedule = &FileScheduleSource{Path: cfg.ScheduleFile}
+	}
+
 	return &RecommenderService{
-		a1ceClient: NewA1CEClient(),
+		source:          source,
+		identityMap:     refs.IdentityMap,
+		curriculumRules: refs.CurriculumRules,
+		gradeRules:      refs.GradeRules,
+		strictProfile:   cfg.StrictProfile,
+		optimizer:       cfg.Optimizer,
+		weightProfile:   cfg.WeightProfile,
+		weights:         weightProfilesFor(cfg.WeightProfilesPath),
+		schedule:        schedule,
+		prereqs:         prereqGraphFor(cfg.DatabasePath),
+		curricula:       curriculaFor(cfg.CurriculumDir),
+		credits:         courseCreditsFor(cfg.DatabasePath),
 	}
 }
 
 // GenerateRecommendations is the main service method
-func (s *RecommenderService) GenerateRecommendations(req *RecommendationRequest) (*RecommendationSet, error) {
+func (s *RecommenderService) GenerateRecommendations(ctx context.Context, req *RecommendationRequest) (*RecommendationSet, error) {
 	startTime := time.Now()
 
+	weightProfile, weights, err := s.weightsFor(req.WeightProfile)
+	if err != nil {
+		return nil, err
+	}
+
 	// Step 1: Fetch student profile
-	studentProfile, err := s.a1ceClient.GetStudentProfile(req.StudentID)
-	fmt.Println("\tjwt token: ", s.a1ceClient.JWTToken)
+	studentProfile, err := s.source.GetStudentProfile(ctx, req.StudentID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch student profile: %w", err)
 	}
 	studentProfile.Semester = req.Semester
 	studentProfile.MaxCreditLoad = req.MaxCreditLoad
+	if (s.strictProfile || req.StrictProfile) && studentProfile.Completeness.Missing(ProfilePartHistory) {
+		return nil, fmt.Errorf("%w: %s", ErrIncompleteProfile, partError(studentProfile.Completeness, ProfilePartHistory))
+	}
+
+	// Step 2: Resolve the student's history against the equivalence classes
+	history := s.ResolveHistory(ctx, req.StudentID, studentProfile)
 
-	// Step 2: Fetch course catalog
-	catalog, err := s.a1ceClient.GetCourseCatalog(req.Semester, studentProfile.CurriculumVersion)
+	// Step 3: Fetch course catalog
+	catalog, err := s.source.GetCourseCatalog(ctx, req.Semester, studentProfile.CurriculumVersion)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch course catalog: %w", err)
 	}
+	s.ApplyIdentityMap(catalog.Courses)
 
-	// Step 3: Fetch curriculum requirements
-	requirements := &CurriculumRequirements{
-		CurriculumVersion:    studentProfile.CurriculumVersion,
-		RequiredCompetencies: studentProfile.RequiredCompetencies,
-		DistributionRequirements: map[string]float64{
-			"AI":   36,
-			"SE":   24,
-			"Math": 12,
-		},
-		TotalCreditsRequired: 120,
-	}
+	// Step 4: Fetch curriculum requirements
+	requirements := s.curriculumRequirements(studentProfile, history, catalog.Courses)
 
-	// Step 4: Infer interest areas
-	studentProfile.InterestWeights = InferInterestAreas(
-		studentProfile.CompletedCourses,
-		catalog.Courses,
-		studentProfile.Competencies,
-	)
+	// Step 5: Infer interest areas
+	studentProfile.InterestWeights = s.inferInterestWeights(ctx, req, studentProfile, catalog.Courses)
 
-	// Step 5: Generate candidate courses (filter)
-	candidateCourses := s.filterCandidateCourses(catalog.Courses, studentProfile, req.Constraints)
+	// Step 6: Generate candidate courses (filter)
+	graph := s.prereqs.WithCourses(catalog.Courses)
+	candidateCourses := s.filterCandidateCourses(catalog.Courses, studentProfile, history, req, graph)
 
-	// Step 6: Score each candidate course
-	scoredCourses := s.scoreCourses(candidateCourses, studentProfile, requirements)
+	// Step 7: Score each candidate course
+	unlock := graph.UnlockScores(candidateCourses, history.CodeSet())
+	scoredCourses := s.scoreCourses(candidateCourses, studentProfile, requirements, unlock, weights)
 
-	// Step 7: Optimize course set selection
-	recommendedSet := OptimizeCourseSet(scoredCourses, studentProfile, requirements, req.MaxCreditLoad)
+	// Step 7b: Apply preferred subdomains, time preferences and pins
+	var warnings []string
+	var sections []CourseSection
+	if c := req.Constraints; c != nil {
+		for i := range scoredCourses {
+			scoredCourses[i].Pinned = isPinned(scoredCourses[i].Course, c)
+		}
+		// Only time preferences need the schedule; pins name whole courses.
+		if !c.TimePreferences.empty() {
+			if s.schedule == nil {
+				warnings = append(warnings, "Time preferences were not applied: no schedule data is configured")
+			} else if sections, err = s.loadSections(ctx, req.Semester); err != nil {
+				log.Printf("(!) WARNING: %v", err)
+				warnings = append(warnings, "Time preferences were not applied: the schedule could not be loaded")
+			}
+		}
+		if missing := missingPinned(c, candidateCourses); len(missing) > 0 {
+			warnings = append(warnings, "Courses that cannot be taken this semester were not included: "+strings.Join(missing, ", "))
+		}
+	}
+	scoredCourses = applyPreferences(scoredCourses, req.Constraints, sections, weights.Preferences)
+	pinned, unpinned, dropped := splitPinned(scoredCourses, req.Constraints, req.MaxCreditLoad)
+	if len(dropped) > 0 {
+		warnings = append(warnings, "Included courses exceeding the credit load were left out: "+strings.Join(dropped, ", "))
+	}
 
-	// Step 8: Evaluate recommendation quality
-	metrics := EvaluateRecommendationSet(recommendedSet, studentProfile, requirements)
+	// Step 8: Optimize course set selection
+	optimizer := req.Optimizer
+	if optimizer == "" {
+		optimizer = s.optimizer
+	}
+	if optimizer != OptimizerExact {
+		optimizer = OptimizerGreedy
+	}
+	recommendedSet, complete := optimizeSet(optimizer, pinned, unpinned, studentProfile, requirements, req.MaxCreditLoad)
+	if !complete {
+		warnings = append(warnings, "Exact optimizer reached its search limit; the best set found is returned")
+	}
 
-	// Step 9: Build final response
+	// Step 8b: Alternative plans with a different emphasis
+	var alternatives []AlternativePlan
+	if req.MaxSets > 1 {
+		alternatives = buildAlternativePlans(recommendedSet, req.MaxSets-1, optimizer, pinned, unpinned, studentProfile, requirements, req.MaxCreditLoad, weights, s.weights)
+		if len(alternatives) < req.MaxSets-1 {
+			warnings = append(warnings, fmt.Sprintf("Only %d sufficiently different plan(s) could be built", len(alternatives)+1))
+		}
+	}
+
+	// Step 9: Evaluate recommendation quality
+	metrics := EvaluateRecommendationSet(recommendedSet, studentProfile, requirements, weights.Goodness)
+
+	status := "success"
+	for _, part := range studentProfile.Completeness.Failed() {
+		status = "partial"
+		warnings = append(warnings, profilePartWarning(part))
+	}
+	if req.MaxCreditLoad > 60 {
+		warnings = append(warnings, "The student is currently doing a credit overload, make sure to already contact CMKL staff")
+	}
+	if len(catalog.FailedSubdomains) > 0 {
+		status = "partial"
+		warnings = append(warnings, fmt.Sprintf("Course catalog is incomplete: %d subdomain(s) could not be loaded", len(catalog.FailedSubdomains)))
+	}
+
+	// Step 10: Build final response
 	result := &RecommendationSet{
 		StudentID:            req.StudentID,
 		Semester:             req.Semester,
@@ -76,36 +181,213 @@ func (s *RecommenderService) GenerateRecommendations(req *RecommendationRequest)
 		DistributionCoverage: calculateDistributionCoverage(recommendedSet),
 		Metadata: RecommendationMetadata{
 			GenerationTimestamp: time.Now(),
-			AlgorithmVersion:    "1.0",
+			AlgorithmVersion:    algorithmVersion,
 			ProcessingTimeMs:    time.Since(startTime).Milliseconds(),
+			Optimizer:           optimizer,
+			WeightProfile:       weightProfile,
+			Weights:             &weights,
 		},
-		Status: "success",
+		Status:              status,
+		Warning:             strings.Join(warnings, "; "),
+		ProfileCompleteness: &studentProfile.Completeness,
+		Alternatives:        alternatives,
+	}
+	if req.MaxSets > 1 {
+		result.Label = planStrategies[0].label
+	}
+
+	// Step 11: Record the set for advisors
+	if s.History != nil {
+		id, err := s.History.Save(req, result)
+		if err != nil {
+			log.Printf("(!) WARNING: Could not store recommendation history: %v", err)
+		} else {
+			result.RecommendationID = id
+		}
 	}
 
 	return result, nil
 }
 
+// curriculumRequirements looks up the student's curriculum definition and
+// derives the outstanding requirements from it. Course credits come from
+// competency_data, or from courses for those it offers.
+func (s *RecommenderService) curriculumRequirements(profile *StudentProfile, history *ResolvedHistory, courses []Course) *CurriculumRequirements {
+	curriculum, ok := s.curricula.Lookup(profile.UniversityCode, profile.CurriculumVersion)
+	if !ok {
+		curriculum = nil
+	}
+	credits := maps.Clone(s.credits)
+	if credits == nil {
+		credits = make(map[string]float64)
+	}
+	for _, c := range courses {
+		for _, code := range []string{c.CourseCode, c.CourseID, c.TemplateID} {
+			if code != "" {
+				credits[normalizeCode(code)] = c.CreditHours
+			}
+		}
+	}
+	return newCurriculumRequirements(profile, curriculum, history, credits)
+}
+
+// profilePartWarning explains what a missing profile part means for the
+// recommendations.
+func profilePartWarning(part ProfilePart) string {
+	switch part.Name {
+	case ProfilePartHistory:
+		return "Student history could not be loaded (" + part.Error + "); courses already passed may be recommended"
+	case ProfilePartGraduation:
+		return "Graduation status could not be loaded (" + part.Error + "); required courses and credits are not considered"
+	case ProfilePartSemesterCards:
+		return "Some semester records could not be loaded (" + part.Error + "); completed courses may be matched less reliably"
+	default:
+		return part.Name + " could not be loaded: " + part.Error
+	}
+}
+
+func partError(c ProfileCompleteness, name string) string {
+	for _, p := range c.Parts {
+		if p.Name == name {
+			return p.Error
+		}
+	}
+	return ""
+}
+
+// ApplyIdentityMap stamps each catalog course with its identity code,
+// curriculum-required status and prerequisite grade thresholds.
+func (s *RecommenderService) ApplyIdentityMap(courses []Course) {
+	for i := range courses {
+		c := &courses[i]
+		normCode := normalizeCode(c.CourseCode)
+
+		if val, ok := s.identityMap[normCode]; ok {
+			c.TemplateID = val
+		}
+		if s.curriculumRules[normCode] || s.curriculumRules[normalizeCode(c.CourseID)] {
+			c.IsRequired = true
+			c.IsCore = true
+		}
+		c.RequiredCompetencies = s.withGradeRules(*c)
+	}
+}
+
+// withGradeRules returns the course's competency thresholds merged with the
+// rules file, in a new map since cached catalogs share theirs.
+func (s *RecommenderService) withGradeRules(c Course) map[string]float64 {
+	merged := make(map[string]float64, len(c.RequiredCompetencies))
+	for comp, minGrade := range c.RequiredCompetencies {
+		merged[comp] = minGrade
+	}
+	seen := make(map[string]bool)
+	for _, code := range []string{c.CourseCode, c.CourseID, c.TemplateID} {
+		norm := normalizeCode(code)
+		if code == "" || seen[norm] {
+			continue
+		}
+		seen[norm] = true
+		for prereq, minGrade := range s.gradeRules[norm] {
+			merged[prereq] = max(merged[prereq], minGrade)
+		}
+	}
+	return merged
+}
+
+// inferInterestWeights derives subdomain interest from the courses the student
+// did well in. PreviousSemester selects the history window ("ALL" for the
+// whole record); without one, the catalog-based inference is used instead.
+func (s *RecommenderService) inferInterestWeights(ctx context.Context, req *RecommendationRequest, profile *StudentProfile, courses []Course) map[string]float64 {
+	var successfulCourses []string
+	if req.PreviousSemester == "ALL" {
+		for courseCode, grade := range profile.Competencies {
+			if grade > 1.0 {
+				successfulCourses = append(successfulCourses, courseCode)
+			}
+		}
+	} else if req.PreviousSemester != "" {
+		semesterCards, err := s.source.GetSemesterCompetencies(ctx, req.StudentID, req.PreviousSemester)
+		if err == nil {
+			for _, card := range semesterCards {
+				if card.Grade > 1.0 {
+					successfulCourses = append(successfulCourses, card.CourseCode)
+				}
+			}
+		}
+	}
+
+	if len(successfulCourses) == 0 {
+		profile.InterestSources = interestSources(profile.CompletedCourses, courses)
+		return InferInterestAreas(profile.CompletedCourses, courses, profile.Competencies)
+	}
+
+	sort.Strings(successfulCourses)
+	weights := make(map[string]float64)
+	profile.InterestSources = make(map[string][]string)
+	for _, successCode := range successfulCourses {
+		prefix := strings.Split(successCode, "-")[0]
+		for _, course := range courses {
+			if strings.HasPrefix(course.CourseCode, prefix) {
+				weights[course.SubdomainID] += 5.0
+				if !containsString(profile.InterestSources[course.SubdomainID], successCode) {
+					profile.InterestSources[course.SubdomainID] = append(profile.InterestSources[course.SubdomainID], successCode)
+				}
+			}
+		}
+	}
+
+	totalWeight := 0.0
+	for _, w := range weights {
+		totalWeight += w
+	}
+	if totalWeight > 0 {
+		for k := range weights {
+			weights[k] /= totalWeight
+		}
+	}
+	return weights
+}
+
 // filterCandidateCourses removes ineligible courses
 func (s *RecommenderService) filterCandidateCourses(
 	allCourses []Course,
 	profile *StudentProfile,
-	constraints *RecommendationFilters,
+	history *ResolvedHistory,
+	req *RecommendationRequest,
+	graph *PrereqGraph,
 ) []Course {
 	var candidates []Course
+	completedCodes := history.CodeSet()
 
 	for _, course := range allCourses {
 		// Filter 1: Already completed
-		if contains(profile.CompletedCourses, course.CourseID) {
+		if history.Completed(course) {
 			continue
 		}
 
 		// Filter 2: Prerequisites not satisfied
-		if !CheckPrerequisites(course, profile) {
+		if !CheckPrerequisites(course, profile) || len(graph.MissingPrerequisites(course, completedCodes)) > 0 {
+			continue
+		}
+
+		// Filter 3: Not a recommendable pillar
+		if strings.HasPrefix(course.CourseCode, "SOF-") {
+			continue
+		}
+
+		// Filter 4: Not offered in the requested semester
+		if !offeredIn(course, req.Semester) {
+			continue
+		}
+
+		// Filter 5: User constraints - excluded courses
+		if req.Constraints != nil && matchesCourseCode(course, req.Constraints.ExcludeCourses) {
 			continue
 		}
 
-		// Filter 3: User constraints - excluded courses
-		if constraints != nil && contains(constraints.ExcludeCourses, course.CourseID) {
+		// Filter 6: User constraints - only preferred subdomains
+		if c := req.Constraints; c != nil && c.PreferredSubdomainMode == SubdomainModeOnly && len(c.PreferredSubdomains) > 0 &&
+			!inPreferredSubdomain(course, c.PreferredSubdomains) && !isPinned(course, c) {
 			continue
 		}
 
@@ -115,31 +397,80 @@ func (s *RecommenderService) filterCandidateCourses(
 	return candidates
 }
 
-// scoreCourses calculates fit scores for all candidate courses
+// offeredIn reports whether course runs in semester. SemesterOffered may be
+// empty (every semester), a full name ("Spring 2026"), a term ("Spring") or
+// a list of either.
+func offeredIn(course Course, semester string) bool {
+	if course.SemesterOffered == "" {
+		return true
+	}
+	term := strings.Fields(semester)
+	for _, offered := range strings.FieldsFunc(course.SemesterOffered, func(r rune) bool { return r == ',' || r == '/' || r == ';' }) {
+		offered = strings.TrimSpace(offered)
+		if strings.EqualFold(offered, semester) || (len(term) > 0 && strings.EqualFold(offered, term[0])) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesCourseCode reports whether any of codes names course.
+func matchesCourseCode(course Course, codes []string) bool {
+	for _, code := range codes {
+		norm := normalizeCode(code)
+		if norm == normalizeCode(course.CourseID) || norm == normalizeCode(course.CourseCode) {
+			return true
+		}
+	}
+	return false
+}
+
+// weightsFor resolves a request's weight profile, falling back to the
+// configured default.
+func (s *RecommenderService) weightsFor(name string) (string, WeightProfile, error) {
+	if name == "" {
+		name = s.weightProfile
+	}
+	weights, err := s.weights.Get(name)
+	return name, weights, err
+}
+
+// scoreCourses calculates fit scores for all candidate courses. unlock holds
+// each course's unlock score by CourseID, which combineFit weighs in.
 func (s *RecommenderService) scoreCourses(
 	courses []Course,
 	profile *StudentProfile,
 	requirements *CurriculumRequirements,
+	unlock map[string]float64,
+	weights WeightProfile,
 ) []RecommendedCourse {
 	var scored []RecommendedCourse
 
 	for _, course := range courses {
-		compScore := CalculateCompetencyMatchScore(course, profile)
-		interestScore := CalculateInterestScore(course, profile)
-		progressScore := CalculateProgramProgressScore(course, profile, requirements)
+		scores := courseScores{
+			CompetencyParts: competencyMatch(course, profile),
+			ProgressParts:   programProgress(course, profile, requirements),
+			Interest:        CalculateInterestScore(course, profile, weights.InterestAlignment),
+			Unlock:          unlock[course.CourseID],
+		}
+		scores.Competency = scores.CompetencyParts.score(weights.CompetencyMatch)
+		scores.Progress = scores.ProgressParts.score(weights.ProgramProgress)
 
-		fitScore := 0.4*compScore + 0.3*interestScore + 0.3*progressScore
+		fitScore := combineFit(weights.Fit, scores.Competency, scores.Interest, scores.Progress, scores.Unlock)
 
 		recommended := RecommendedCourse{
 			Course:                 course,
+			DisplayCourse:          s.toCourseOutput(course, profile),
 			FitScore:               fitScore,
-			CompetencyMatchScore:   compScore,
-			InterestAlignmentScore: interestScore,
-			ProgramProgressScore:   progressScore,
+			CompetencyMatchScore:   scores.Competency,
+			InterestAlignmentScore: scores.Interest,
+			ProgramProgressScore:   scores.Progress,
+			UnlockScore:            scores.Unlock,
 			MatchedCompetencies:    GetMatchedCompetencies(course, profile),
 			MissingCompetencies:    GetMissingCompetencies(course, profile),
-			Reason:                 generateReason(course, fitScore, progressScore, interestScore),
 		}
+		recommended.Explanation = s.explain(course, profile, weights, scores)
+		recommended.Reason = recommended.Explanation.Text
 
 		scored = append(scored, recommended)
 	}
@@ -152,17 +483,43 @@ func (s *RecommenderService) scoreCourses(
 	return scored
 }
 
-func generateReason(course Course, fitScore, progressScore, interestScore float64) string {
-	if progressScore > 0.7 {
-		return fmt.Sprintf("Satisfies important graduation requirements in %s", course.SubdomainName)
+// combineFit weighs the course scores and the unlock score into the fit
+// score. The weights sum to 1, so the fit stays within [0, 1] like its parts.
+func combineFit(w FitWeights, compScore, interestScore, progressScore, unlock float64) float64 {
+	return w.Competency*compScore + w.Interest*interestScore + w.Progress*progressScore + w.Gateway*unlock
+}
+
+// toCourseOutput builds the response view of a course, labelling whether it
+// is a curriculum or graduation requirement.
+func (s *RecommenderService) toCourseOutput(course Course, profile *StudentProfile) CourseOutput {
+	out := CourseOutput{
+		CourseID:             course.CourseID,
+		TemplateID:           course.TemplateID,
+		CourseCode:           course.CourseCode,
+		CourseName:           course.CourseName,
+		Description:          course.Description,
+		CreditHours:          course.CreditHours,
+		SubdomainID:          course.SubdomainID,
+		SubdomainName:        course.SubdomainName,
+		TeachesCompetencies:  course.TeachesCompetencies,
+		SemesterOffered:      course.SemesterOffered,
+		RequiredCompetencies: make(map[string]string),
 	}
-	if interestScore > 0.7 {
-		return fmt.Sprintf("Strongly aligns with your interests in %s", course.SubdomainName)
+
+	required := s.curriculumRules[normalizeCode(course.CourseCode)] ||
+		(course.TemplateID != "" && s.curriculumRules[normalizeCode(course.TemplateID)])
+	for _, missing := range profile.RequiredCompetencies {
+		if normalizeCode(missing) == normalizeCode(course.CourseCode) {
+			required = true
+		}
 	}
-	if fitScore > 0.8 {
-		return "Excellent fit based on your competencies and academic goals"
+
+	if required {
+		out.RequiredCompetencies["Required"] = "-"
+	} else {
+		out.RequiredCompetencies["Not Required"] = "-"
 	}
-	return fmt.Sprintf("Good course option in %s that advances your degree progress", course.SubdomainName)
+	return out
 }
 
 func calculateTotalCredits(courses []RecommendedCourse) float64 {
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCombineFitStaysWithinOne(t *testing.T) {
+	for name, p := range builtinWeightProfiles {
+		if got := combineFit(p.Fit, 1, 1, 1, 1); math.Abs(got-1) > 1e-9 {
+			t.Errorf("%s: top scores give fit %v, want 1", name, got)
+		}
+		if got := combineFit(p.Fit, 0, 0, 0, 0); got != 0 {
+			t.Errorf("%s: zero scores give fit %v, want 0", name, got)
+		}
+	}
+	w := builtinWeightProfiles[DefaultWeightProfile].Fit
+	if combineFit(w, 0.5, 0.5, 0.5, 1) <= combineFit(w, 0.5, 0.5, 0.5, 0) {
+		t.Error("a gateway course does not rank above an otherwise equal course")
+	}
+}
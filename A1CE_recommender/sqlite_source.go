@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLiteDataSource serves students and the catalog from the
+// a1ce_recommendation.db snapshot (student, competency_data and
+// Competency_prerequisites tables).
+type SQLiteDataSource struct {
+	db                *sql.DB
+	StudentTable      string
+	UniversityCode    string
+	CurriculumVersion int
+	TotalCredits      int
+
+	metaMu sync.Mutex
+	meta   map[string]CompetencyMeta
+}
+
+func NewSQLiteDataSource(dbPath string) (*SQLiteDataSource, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite db: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open sqlite db: %w", err)
+	}
+	return &SQLiteDataSource{
+		db:             db,
+		StudentTable:   "student",
+		UniversityCode: "CMKL",
+		TotalCredits:   120,
+	}, nil
+}
+
+func (s *SQLiteDataSource) Close() error {
+	return s.db.Close()
+}
+
+// competencyMeta reads competency_data on first use and keeps it, so building
+// a profile does not scan the table again. A failed read is retried next time.
+func (s *SQLiteDataSource) competencyMeta() (map[string]CompetencyMeta, error) {
+	s.metaMu.Lock()
+	defer s.metaMu.Unlock()
+
+	if s.meta != nil {
+		return s.meta, nil
+	}
+	meta, err := loadCompetencyMeta(s.db)
+	if err != nil {
+		return nil, fmt.Errorf("load competency data: %w", err)
+	}
+	s.meta = meta
+	return meta, nil
+}
+
+func (s *SQLiteDataSource) GetStudentProfile(ctx context.Context, studentID string) (*StudentProfile, error) {
+	cards, err := s.studentCards(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	if len(cards) == 0 {
+		return nil, fmt.Errorf("student %s: %w", studentID, ErrNotFound)
+	}
+	return s.profileFromCards(studentID, cards), nil
+}
+
+// ProfileFromRows builds a student's profile from rows already read, as
+// GetStudentProfile would from the student table. The evaluator uses it for
+// the splits it makes itself.
+func (s *SQLiteDataSource) ProfileFromRows(studentID string, rows []TrainRow) (*StudentProfile, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("student %s: %w", studentID, ErrNotFound)
+	}
+	meta, err := s.competencyMeta()
+	if err != nil {
+		return nil, err
+	}
+	cards := make([]A1CECompetencyCard, 0, len(rows))
+	for _, r := range rows {
+		cards = append(cards, competencyCard(r.CompetencyCode, meta[r.CompetencyCode].Title, r.Grade, r.Graded))
+	}
+	return s.profileFromCards(studentID, cards), nil
+}
+
+func (s *SQLiteDataSource) profileFromCards(studentID string, cards []A1CECompetencyCard) *StudentProfile {
+	gradStatus, gradErr := s.graduationStatus(cards)
+
+	identity := &A1CEStudentIdentity{
+		StudentID:         studentID,
+		UniversityCode:    s.UniversityCode,
+		CurriculumVersion: s.CurriculumVersion,
+	}
+	profile := buildStudentProfile(studentID, identity, cards, gradStatus)
+	profile.Completeness.Record(ProfilePartHistory, nil)
+	profile.Completeness.Record(ProfilePartGraduation, gradErr)
+	return profile
+}
+
+// GetSemesterCompetencies returns no cards: the snapshot does not record
+// which semester a competency was taken in.
+func (s *SQLiteDataSource) GetSemesterCompetencies(ctx context.Context, studentID, semester string) ([]A1CECompetencyCard, error) {
+	return []A1CECompetencyCard{}, nil
+}
+
+func (s *SQLiteDataSource) GetGraduationStatus(ctx context.Context, studentID string) (*A1CEGraduationStatus, error) {
+	cards, err := s.studentCards(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	return s.graduationStatus(cards)
+}
+
+func (s *SQLiteDataSource) graduationStatus(cards []A1CECompetencyCard) (*A1CEGraduationStatus, error) {
+	meta, err := s.competencyMeta()
+	if err != nil {
+		return nil, err
+	}
+
+	taken := make(map[string]bool)
+	status := &A1CEGraduationStatus{RequiredCompetencies: []string{}}
+	status.DistributionCredits = make(map[string]A1CECredit)
+	status.TotalCredits.Required = s.TotalCredits
+
+	for _, card := range cards {
+		taken[card.CourseCode] = true
+		credits := meta[card.CourseCode].Credits
+		area := pillarPrefix(card.CourseCode)
+		areaCredit := status.DistributionCredits[area]
+		if card.Status == "Recorded" {
+			status.TotalCredits.Earned += credits
+			areaCredit.Earned += credits
+		} else {
+			status.TotalCredits.Working += credits
+			areaCredit.Working += credits
+		}
+		status.DistributionCredits[area] = areaCredit
+	}
+
+	for code, m := range meta {
+		if m.Required == 1 && !taken[code] {
+			status.RequiredCompetencies = append(status.RequiredCompetencies, code)
+		}
+	}
+
+	return status, nil
+}
+
+func (s *SQLiteDataSource) GetCourseCatalog(ctx context.Context, semester string, curriculumVersion int) (*CourseCatalogResponse, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT competency_code, title, description, domain_id, domain_title, credits, required FROM competency_data`)
+	if err != nil {
+		return nil, fmt.Errorf("load competency data: %w", err)
+	}
+	defer rows.Close()
+
+	prereqs, err := loadPrerequisites(s.db)
+	if err != nil {
+		return nil, fmt.Errorf("load prerequisites: %w", err)
+	}
+
+	catalog := &CourseCatalogResponse{
+		Status: "success", Semester: semester, CurriculumVersion: curriculumVersion, Courses: []Course{},
+	}
+
+	for rows.Next() {
+		var code, title, description, domainID, domainTitle sql.NullString
+		var credits sql.NullFloat64
+		var required sql.NullBool
+		if err := rows.Scan(&code, &title, &description, &domainID, &domainTitle, &credits, &required); err != nil {
+			return nil, err
+		}
+		if !code.Valid {
+			continue
+		}
+
+		prerequisites := prereqs[code.String]
+		if prerequisites == nil {
+			prerequisites = []string{}
+		}
+
+		catalog.Courses = append(catalog.Courses, Course{
+			CourseID:             code.String,
+			CourseCode:           code.String,
+			CourseName:           title.String,
+			Description:          strings.TrimSpace(description.String),
+			CreditHours:          credits.Float64,
+			SubdomainID:          domainID.String,
+			SubdomainName:        domainTitle.String,
+			IsCore:               required.Bool,
+			IsRequired:           required.Bool,
+			RequiredCompetencies: make(map[string]float64),
+			TeachesCompetencies:  []string{code.String},
+			Prerequisites:        prerequisites,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	catalog.TotalCourses = len(catalog.Courses)
+	return catalog, nil
+}
+
+// studentCards converts the student's rows into A1CE cards. Rows without a
+// usable grade (NULL, or the 99 placeholder) are treated as still in progress.
+func (s *SQLiteDataSource) studentCards(ctx context.Context, studentID string) ([]A1CECompetencyCard, error) {
+	query := fmt.Sprintf(`SELECT t.competency_code, c.title, t.Grade FROM %s t
+		LEFT JOIN competency_data c ON c.competency_code = t.competency_code
+		WHERE t.student_id = ?`, s.StudentTable)
+	rows, err := s.db.QueryContext(ctx, query, studentID)
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", s.StudentTable, err)
+	}
+	defer rows.Close()
+
+	var cards []A1CECompetencyCard
+	for rows.Next() {
+		var code, title, grade sql.NullString
+		if err := rows.Scan(&code, &title, &grade); err != nil {
+			return nil, err
+		}
+		if !code.Valid {
+			continue
+		}
+
+		g, err := strconv.ParseFloat(grade.String, 64)
+		cards = append(cards, competencyCard(code.String, title.String, g, err == nil))
+	}
+	return cards, rows.Err()
+}
+
+// competencyCard makes the card of one row. A grade that is missing or
+// above 4.0 leaves the competency in progress.
+func competencyCard(code, title string, grade float64, graded bool) A1CECompetencyCard {
+	card := A1CECompetencyCard{
+		CompetencyID: code,
+		CourseCode:   code,
+		CourseName:   title,
+		Status:       "In Progress",
+	}
+	if graded && grade <= 4.0 {
+		card.Grade = grade
+		card.Status = "Recorded"
+	}
+	return card
+}
+
+// pillarPrefix returns the pillar part of a competency code ("AIC-101" -> "AIC").
+func pillarPrefix(code string) string {
+	return strings.Split(code, "-")[0]
+}
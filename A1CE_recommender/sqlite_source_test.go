@@ -0,0 +1,38 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteSourceReadsCompetencyDataOnce(t *testing.T) {
+	source, err := NewSQLiteDataSource(filepath.Join(t.TempDir(), "snapshot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+	for _, stmt := range []string{
+		`CREATE TABLE competency_data (competency_code TEXT, title TEXT, description TEXT, domain_id TEXT, domain_title TEXT, credits INTEGER, required INTEGER)`,
+		`INSERT INTO competency_data VALUES ('TST-101', 'Testing I', '', 'T', 'Testing', 3, 1), ('TST-102', 'Testing II', '', 'T', 'Testing', 4, 1)`,
+	} {
+		if _, err := source.db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows := []TrainRow{{CompetencyCode: "TST-101", Grade: 3.0, Graded: true}}
+	if _, err := source.ProfileFromRows("S1", rows); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.db.Exec(`DROP TABLE competency_data`); err != nil {
+		t.Fatal(err)
+	}
+
+	profile, err := source.ProfileFromRows("S1", rows)
+	if err != nil {
+		t.Fatalf("second profile read competency_data again: %v", err)
+	}
+	if profile.TotalCredits.Earned != 3 || len(profile.RequiredCompetencies) != 1 || profile.RequiredCompetencies[0] != "TST-102" {
+		t.Errorf("credits = %+v, required = %v, want 3 earned and TST-102 outstanding", profile.TotalCredits, profile.RequiredCompetencies)
+	}
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// Recommender is one strategy for choosing a student's courses. It returns
+// the chosen courses best first, within opts.MaxCreditLoad credits, and must
+// not modify profile or catalog: eval hands every strategy the same ones.
+type Recommender interface {
+	Recommend(profile *StudentProfile, catalog *CourseCatalogResponse, opts RecommendOptions) []RecommendedCourse
+}
+
+// RecommendOptions are the settings every strategy honours.
+type RecommendOptions struct {
+	Semester      string
+	MaxCreditLoad float64
+}
+
+// RecommenderEnv is the shared data strategies are built from. Train holds
+// the training rows of the evaluation split; the baselines learn from them.
+// Weights, when set, replace the configured weight profile of fit-score.
+type RecommenderEnv struct {
+	Config     *Config
+	Similarity map[string]map[string]float64
+	Prereqs    *PrereqGraph
+	Train      []TrainRow
+	Seed       int64
+	Params     ContentSimilarityParams
+	Weights    *WeightProfile
+}
+
+// ContentSimilarityParams tune content-similarity. A competency the student
+// took is a top competency when grade/4, rating/5 and whether it is required
+// (0 or 1), weighted and summed, reach TopThreshold; each top competency
+// brings in its Neighbors most similar ones.
+type ContentSimilarityParams struct {
+	GradeWeight    float64 `json:"grade_weight"`
+	RatingWeight   float64 `json:"rating_weight"`
+	RequiredWeight float64 `json:"required_weight"`
+	TopThreshold   float64 `json:"top_threshold"`
+	Neighbors      int     `json:"neighbors"`
+}
+
+// defaultContentSimilarityParams are the values of the notebook.
+var defaultContentSimilarityParams = ContentSimilarityParams{
+	GradeWeight: 0.5, RatingWeight: 0.3, RequiredWeight: 0.2, TopThreshold: 0.8, Neighbors: 3,
+}
+
+func (p ContentSimilarityParams) validate() error {
+	if p.GradeWeight < 0 || p.RatingWeight < 0 || p.RequiredWeight < 0 {
+		return fmt.Errorf("similarity weights must not be negative")
+	}
+	if p.Neighbors <= 0 {
+		return fmt.Errorf("similarity_neighbors must be positive; got %d", p.Neighbors)
+	}
+	return nil
+}
+
+// RecommenderFactory builds a strategy from the shared data.
+type RecommenderFactory func(env *RecommenderEnv) (Recommender, error)
+
+// Registered strategies.
+const (
+	RecommenderContentSimilarity = "content-similarity"
+	RecommenderFitScore          = "fit-score"
+	RecommenderPopularity        = "popularity"
+	RecommenderRandom            = "random"
+)
+
+// ErrUnknownRecommender is returned for a strategy name nobody registered.
+var ErrUnknownRecommender = errors.New("unknown recommender")
+
+var recommenderRegistry = map[string]RecommenderFactory{
+	RecommenderContentSimilarity: newContentSimilarityRecommender,
+	RecommenderFitScore:          newFitScoreRecommender,
+	RecommenderPopularity:        newPopularityRecommender,
+	RecommenderRandom:            newRandomRecommender,
+}
+
+// RegisterRecommender adds a strategy, replacing any of the same name.
+func RegisterRecommender(name string, factory RecommenderFactory) {
+	recommenderRegistry[name] = factory
+}
+
+// NewRecommender builds the strategy registered as name.
+func NewRecommender(name string, env *RecommenderEnv) (Recommender, error) {
+	factory, ok := recommenderRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q (expected one of %s)", ErrUnknownRecommender, name, strings.Join(RecommenderNames(), ", "))
+	}
+	return factory(env)
+}
+
+// RecommenderNames lists the registered strategies in name order.
+func RecommenderNames() []string {
+	names := make([]string, 0, len(recommenderRegistry))
+	for name := range recommenderRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ---------------------------------------------------------
+// content-similarity: the neighbour algorithm from the notebook
+// ---------------------------------------------------------
+
+// contentSimilarityRecommender takes the student's best-rated competencies,
+// brings in the most similar competencies of each, filters them by
+// prerequisites and ranks them required first, then by how many courses they
+// unlock.
+type contentSimilarityRecommender struct {
+	sim     map[string]map[string]float64
+	prereqs *PrereqGraph
+	ratings map[string]map[string]float64 // student -> course -> overall rating
+	params  ContentSimilarityParams
+}
+
+func newContentSimilarityRecommender(env *RecommenderEnv) (Recommender, error) {
+	ratings := make(map[string]map[string]float64)
+	for _, r := range env.Train {
+		if ratings[r.StudentID] == nil {
+			ratings[r.StudentID] = make(map[string]float64)
+		}
+		ratings[r.StudentID][r.CompetencyCode] = r.OverallRating
+	}
+	if err := env.Params.validate(); err != nil {
+		return nil, err
+	}
+	return &contentSimilarityRecommender{sim: env.Similarity, prereqs: env.Prereqs, ratings: ratings, params: env.Params}, nil
+}
+
+func (r *contentSimilarityRecommender) Recommend(profile *StudentProfile, catalog *CourseCatalogResponse, opts RecommendOptions) []RecommendedCourse {
+	courses := catalogByCode(catalog)
+
+	topSet := make(map[string]bool)
+	completed := make(map[string]bool)
+	for code, grade := range profile.Competencies {
+		required := 0.0
+		if courses[code].IsRequired {
+			required = 1
+		}
+		p := r.params
+		score := (grade/4.0)*p.GradeWeight + (r.ratings[profile.StudentID][code]/5.0)*p.RatingWeight + required*p.RequiredWeight
+		if score >= p.TopThreshold {
+			topSet[code] = true
+			completed[normalizeCode(code)] = true
+		}
+	}
+	if len(topSet) == 0 {
+		log.Printf("Student %s: no top competencies (>=%g)\n", profile.StudentID, r.params.TopThreshold)
+		return nil
+	}
+
+	var candidates []RecommendedCourse
+	seen := make(map[string]bool)
+	for top := range topSet {
+		for _, code := range nearestNeighborsFromSim(r.sim, top, r.params.Neighbors) {
+			if seen[code] {
+				continue
+			}
+			seen[code] = true
+			if _, taken := profile.Competencies[code]; taken {
+				continue
+			}
+			if len(r.prereqs.MissingPrerequisites(Course{CourseCode: code}, completed)) > 0 {
+				continue
+			}
+			course, ok := courses[code]
+			if !ok {
+				course = Course{CourseID: code, CourseCode: code}
+			}
+			candidates = append(candidates, RecommendedCourse{
+				Course:      course,
+				FitScore:    float64(r.prereqs.Unlocks(code)),
+				UnlockScore: float64(r.prereqs.Unlocks(code)),
+				Reason:      "Similar to " + top,
+			})
+		}
+	}
+
+	// Required courses first, then gateways that unlock the most.
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i].Course, candidates[j].Course
+		if a.IsRequired != b.IsRequired {
+			return a.IsRequired
+		}
+		if candidates[i].UnlockScore != candidates[j].UnlockScore {
+			return candidates[i].UnlockScore > candidates[j].UnlockScore
+		}
+		if a.CreditHours != b.CreditHours {
+			return a.CreditHours < b.CreditHours
+		}
+		return a.CourseCode < b.CourseCode
+	})
+	return withinCreditLoad(candidates, opts.MaxCreditLoad)
+}
+
+// ---------------------------------------------------------
+// fit-score: the pipeline the server runs
+// ---------------------------------------------------------
+
+// fitScoreRecommender runs GenerateRecommendations on the profile and
+// catalog it is handed, with the configured weight profile and optimizer.
+type fitScoreRecommender struct {
+	service *RecommenderService
+}
+
+func newFitScoreRecommender(env *RecommenderEnv) (Recommender, error) {
+	service := NewRecommenderService(env.Config, nil)
+	if env.Weights != nil {
+		if err := env.Weights.validate(); err != nil {
+			return nil, err
+		}
+		service.weights = WeightProfiles{TunedWeightProfile: *env.Weights}
+		service.weightProfile = TunedWeightProfile
+	}
+	if _, _, err := service.weightsFor(""); err != nil {
+		return nil, fmt.Errorf("weight_profile: %w", err)
+	}
+	return &fitScoreRecommender{service: service}, nil
+}
+
+func (r *fitScoreRecommender) Recommend(profile *StudentProfile, catalog *CourseCatalogResponse, opts RecommendOptions) []RecommendedCourse {
+	service := *r.service
+	service.source = &profileSource{profile: profile, catalog: catalog}
+
+	set, err := service.GenerateRecommendations(context.Background(), &RecommendationRequest{
+		StudentID:     profile.StudentID,
+		Semester:      opts.Semester,
+		MaxCreditLoad: opts.MaxCreditLoad,
+		MaxSets:       1,
+	})
+	if err != nil {
+		log.Printf("(!) WARNING: Student %s: %v\n", profile.StudentID, err)
+		return nil
+	}
+	if set.Warning != "" {
+		log.Printf("Student %s: %s\n", profile.StudentID, set.Warning)
+	}
+	return set.RecommendedSet
+}
+
+// profileSource serves one profile and catalog that were already loaded.
+// Each call returns copies, since the service fills in both.
+type profileSource struct {
+	profile *StudentProfile
+	catalog *CourseCatalogResponse
+}
+
+func (s *profileSource) GetStudentProfile(ctx context.Context, studentID string) (*StudentProfile, error) {
+	profile := *s.profile
+	profile.Completeness.Parts = append([]ProfilePart(nil), s.profile.Completeness.Parts...)
+	return &profile, nil
+}
+
+func (s *profileSource) GetSemesterCompetencies(ctx context.Context, studentID, semester string) ([]A1CECompetencyCard, error) {
+	return []A1CECompetencyCard{}, nil
+}
+
+func (s *profileSource) GetGraduationStatus(ctx context.Context, studentID string) (*A1CEGraduationStatus, error) {
+	return &A1CEGraduationStatus{
+		RequiredCompetencies: s.profile.RequiredCompetencies,
+		A1CECreditStatus: A1CECreditStatus{
+			DistributionCredits: s.profile.DistributionCredits,
+			TotalCredits:        s.profile.TotalCredits,
+		},
+	}, nil
+}
+
+func (s *profileSource) GetCourseCatalog(ctx context.Context, semester string, curriculumVersion int) (*CourseCatalogResponse, error) {
+	return copyCatalog(s.catalog), nil
+}
+
+// ---------------------------------------------------------
+// Baselines
+// ---------------------------------------------------------
+
+// popularityRecommender offers the courses most training students took that
+// the student has not, ignoring prerequisites.
+type popularityRecommender struct {
+	takenBy  map[string]int
+	students int
+}
+
+func newPopularityRecommender(env *RecommenderEnv) (Recommender, error) {
+	ctx := newRankingContext(0, nil, env.Train, 0)
+	return &popularityRecommender{takenBy: ctx.popularity, students: ctx.students}, nil
+}
+
+func (r *popularityRecommender) Recommend(profile *StudentProfile, catalog *CourseCatalogResponse, opts RecommendOptions) []RecommendedCourse {
+	var ranked []RecommendedCourse
+	for _, course := range untakenCourses(profile, catalog) {
+		share := 0.0
+		if r.students > 0 {
+			share = float64(r.takenBy[course.CourseCode]) / float64(r.students)
+		}
+		ranked = append(ranked, RecommendedCourse{
+			Course:   course,
+			FitScore: share,
+			Reason:   fmt.Sprintf("Taken by %.0f%% of students", share*100),
+		})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].FitScore != ranked[j].FitScore {
+			return ranked[i].FitScore > ranked[j].FitScore
+		}
+		return ranked[i].Course.CourseCode < ranked[j].Course.CourseCode
+	})
+	return withinCreditLoad(ranked, opts.MaxCreditLoad)
+}
+
+// randomRecommender offers untaken courses in random order. Each student's
+// order depends only on the seed and their ID, so runs are repeatable.
+type randomRecommender struct {
+	seed int64
+}
+
+func newRandomRecommender(env *RecommenderEnv) (Recommender, error) {
+	return &randomRecommender{seed: env.Seed}, nil
+}
+
+func (r *randomRecommender) Recommend(profile *StudentProfile, catalog *CourseCatalogResponse, opts RecommendOptions) []RecommendedCourse {
+	h := fnv.New64a()
+	h.Write([]byte(profile.StudentID))
+	rng := rand.New(rand.NewSource(r.seed ^ int64(h.Sum64())))
+
+	courses := untakenCourses(profile, catalog)
+	sort.Slice(courses, func(i, j int) bool { return courses[i].CourseCode < courses[j].CourseCode })
+	rng.Shuffle(len(courses), func(i, j int) { courses[i], courses[j] = courses[j], courses[i] })
+
+	ranked := make([]RecommendedCourse, 0, len(courses))
+	for _, course := range courses {
+		ranked = append(ranked, RecommendedCourse{Course: course, Reason: "Random baseline"})
+	}
+	return withinCreditLoad(ranked, opts.MaxCreditLoad)
+}
+
+// untakenCourses returns the catalog courses with no record in the profile.
+func untakenCourses(profile *StudentProfile, catalog *CourseCatalogResponse) []Course {
+	var out []Course
+	for _, course := range catalog.Courses {
+		if _, taken := profile.Competencies[course.CourseCode]; !taken {
+			out = append(out, course)
+		}
+	}
+	return out
+}
+
+func catalogByCode(catalog *CourseCatalogResponse) map[string]Course {
+	courses := make(map[string]Course, len(catalog.Courses))
+	for _, c := range catalog.Courses {
+		courses[c.CourseCode] = c
+	}
+	return courses
+}
+
+// withinCreditLoad keeps, in order, every course that still fits in the
+// credit load.
+func withinCreditLoad(ranked []RecommendedCourse, maxCredits float64) []RecommendedCourse {
+	total := 0.0
+	var selected []RecommendedCourse
+	for _, c := range ranked {
+		if total+c.Course.CreditHours <= maxCredits {
+			selected = append(selected, c)
+			total += c.Course.CreditHours
+		}
+	}
+	return selected
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Error kinds a data source can fail with. Handlers map them to HTTP status
+// codes with errors.Is.
+var (
+	ErrUnauthorized        = errors.New("unauthorized")
+	ErrNotFound            = errors.New("not found")
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+)
+
+// UpstreamError describes a failed A1CE call.
+type UpstreamError struct {
+	URL        string
+	StatusCode int
+	Body       string
+	Kind       error
+	Err        error
+}
+
+func (e *UpstreamError) Error() string {
+	switch {
+	case e.Err != nil && e.StatusCode != 0:
+		return fmt.Sprintf("status %d: %v", e.StatusCode, e.Err)
+	case e.Err != nil:
+		return e.Err.Error()
+	case e.StatusCode != 0:
+		return fmt.Sprintf("status %d: %s", e.StatusCode, e.Body)
+	default:
+		return e.Kind.Error()
+	}
+}
+
+func (e *UpstreamError) Unwrap() []error {
+	errs := []error{}
+	if e.Kind != nil {
+		errs = append(errs, e.Kind)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// classifyStatus maps an A1CE status code to an error kind; nil means the
+// status is a plain failure with no special handling.
+func classifyStatus(code int) error {
+	switch {
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return ErrUnauthorized
+	case code == http.StatusNotFound:
+		return ErrNotFound
+	case code >= 500 || code == http.StatusTooManyRequests:
+		return ErrUpstreamUnavailable
+	default:
+		return nil
+	}
+}
+
+// retryable reports whether another attempt may succeed.
+func retryable(err error) bool {
+	return errors.Is(err, ErrUpstreamUnavailable)
+}
+
+// RetryPolicy is exponential backoff with full jitter.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// backoff returns the delay before retry n (0-based).
+func (p RetryPolicy) backoff(n int) time.Duration {
+	ceiling := p.BaseDelay << n
+	if ceiling <= 0 || (p.MaxDelay > 0 && ceiling > p.MaxDelay) {
+		ceiling = p.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	return [...]string{"closed", "open", "half-open"}[s]
+}
+
+// CircuitBreaker fails fast once an upstream has failed Threshold times in a
+// row, then lets a single trial call through after Cooldown.
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu          sync.Mutex
+	state       breakerState
+	failures    int
+	openedAt    time.Time
+	trialActive bool
+}
+
+var errCircuitOpen = fmt.Errorf("%w: circuit open", ErrUpstreamUnavailable)
+
+// Allow reports whether a call may proceed.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.Cooldown {
+			return errCircuitOpen
+		}
+		b.state = breakerHalfOpen
+		b.trialActive = true
+		return nil
+	case breakerHalfOpen:
+		if b.trialActive {
+			return errCircuitOpen
+		}
+		b.trialActive = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Record updates the breaker with a call's outcome. Only upstream
+// unavailability counts as a failure; 4xx answers prove A1CE is up.
+func (b *CircuitBreaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trialActive = false
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if err == nil || !retryable(err) {
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.Threshold {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state.String()
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = make(map[string]*CircuitBreaker)
+)
+
+// breakerFor returns the breaker shared by every client of baseURL; clients
+// are built per request, but A1CE's health is not.
+func breakerFor(baseURL string, threshold int, cooldown time.Duration) *CircuitBreaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+
+	if b, ok := breakers[baseURL]; ok {
+		return b
+	}
+	b := &CircuitBreaker{Threshold: threshold, Cooldown: cooldown}
+	breakers[baseURL] = b
+	return b
+}
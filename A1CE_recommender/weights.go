@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultWeightProfile reproduces the weights used before profiles existed,
+// with the fit weights scaled to make room for the gateway weight.
+const DefaultWeightProfile = "default"
+
+// ErrUnknownWeightProfile is returned when a request names a profile that is
+// not defined.
+var ErrUnknownWeightProfile = errors.New("unknown weight profile")
+
+// WeightProfile holds every weight used to score courses and sets. The
+// weights within each of the fit, competency_match, program_progress and
+// goodness groups must sum to 1.
+type WeightProfile struct {
+	Fit               FitWeights               `json:"fit"`
+	CompetencyMatch   CompetencyMatchWeights   `json:"competency_match"`
+	InterestAlignment InterestAlignmentWeights `json:"interest_alignment"`
+	ProgramProgress   ProgramProgressWeights   `json:"program_progress"`
+	Goodness          GoodnessWeights          `json:"goodness"`
+	Preferences       PreferenceWeights        `json:"preferences"`
+}
+
+// FitWeights combine the three course scores and the unlock score into the
+// fit score. Gateway weighs the unlock score, so courses that open up much
+// of the curriculum are taken early.
+type FitWeights struct {
+	Competency float64 `json:"competency"`
+	Interest   float64 `json:"interest"`
+	Progress   float64 `json:"progress"`
+	Gateway    float64 `json:"gateway"`
+}
+
+// CompetencyMatchWeights are used by CalculateCompetencyMatchScore.
+type CompetencyMatchWeights struct {
+	Prerequisites float64 `json:"prerequisites"`
+	Grades        float64 `json:"grades"`
+	SkillGap      float64 `json:"skill_gap"`
+}
+
+// InterestAlignmentWeights are used by CalculateInterestScore. Unexplored,
+// from 0 to 1, is the interest of a subdomain the student has taken nothing
+// in.
+type InterestAlignmentWeights struct {
+	Unexplored float64 `json:"unexplored"`
+}
+
+// ProgramProgressWeights are used by CalculateProgramProgressScore.
+type ProgramProgressWeights struct {
+	Required     float64 `json:"required"`
+	Distribution float64 `json:"distribution"`
+	Overall      float64 `json:"overall"`
+}
+
+// GoodnessWeights are used by EvaluateRecommendationSet.
+type GoodnessWeights struct {
+	SkillCoverage          float64 `json:"skill_coverage"`
+	PrerequisiteCompliance float64 `json:"prerequisite_compliance"`
+	ProgramProgress        float64 `json:"program_progress"`
+}
+
+// PreferenceWeights are used by applyPreferences. PreferredSubdomain is
+// added to the fit of courses in a preferred subdomain; TimeMismatch is taken
+// off courses none of whose sections suit the student's time preferences.
+type PreferenceWeights struct {
+	PreferredSubdomain float64 `json:"preferred_subdomain"`
+	TimeMismatch       float64 `json:"time_mismatch"`
+}
+
+var (
+	defaultCompetencyMatch   = CompetencyMatchWeights{Prerequisites: 0.4, Grades: 0.3, SkillGap: 0.3}
+	defaultInterestAlignment = InterestAlignmentWeights{Unexplored: 0.1}
+	defaultPreferences       = PreferenceWeights{PreferredSubdomain: 0.1, TimeMismatch: 0.1}
+)
+
+// builtinWeightProfiles are always available; the weight profiles file can
+// add to them or redefine them.
+var builtinWeightProfiles = map[string]WeightProfile{
+	DefaultWeightProfile: {
+		Fit:               FitWeights{Competency: 0.18, Interest: 0.54, Progress: 0.18, Gateway: 0.1},
+		CompetencyMatch:   defaultCompetencyMatch,
+		InterestAlignment: defaultInterestAlignment,
+		ProgramProgress:   ProgramProgressWeights{Required: 0.5, Distribution: 0.4, Overall: 0.1},
+		Goodness:          GoodnessWeights{SkillCoverage: 0.3, PrerequisiteCompliance: 0.3, ProgramProgress: 0.4},
+		Preferences:       defaultPreferences,
+	},
+	"balanced": {
+		Fit:               FitWeights{Competency: 0.36, Interest: 0.27, Progress: 0.27, Gateway: 0.1},
+		CompetencyMatch:   defaultCompetencyMatch,
+		InterestAlignment: defaultInterestAlignment,
+		ProgramProgress:   ProgramProgressWeights{Required: 0.5, Distribution: 0.4, Overall: 0.1},
+		Goodness:          GoodnessWeights{SkillCoverage: 0.3, PrerequisiteCompliance: 0.3, ProgramProgress: 0.4},
+		Preferences:       defaultPreferences,
+	},
+	"graduation-first": {
+		Fit:               FitWeights{Competency: 0.18, Interest: 0.18, Progress: 0.54, Gateway: 0.1},
+		CompetencyMatch:   defaultCompetencyMatch,
+		InterestAlignment: defaultInterestAlignment,
+		ProgramProgress:   ProgramProgressWeights{Required: 0.6, Distribution: 0.3, Overall: 0.1},
+		Goodness:          GoodnessWeights{SkillCoverage: 0.2, PrerequisiteCompliance: 0.3, ProgramProgress: 0.5},
+		Preferences:       defaultPreferences,
+	},
+	"explore": {
+		Fit:               FitWeights{Competency: 0.09, Interest: 0.72, Progress: 0.09, Gateway: 0.1},
+		CompetencyMatch:   CompetencyMatchWeights{Prerequisites: 0.3, Grades: 0.2, SkillGap: 0.5},
+		InterestAlignment: defaultInterestAlignment,
+		ProgramProgress:   ProgramProgressWeights{Required: 0.5, Distribution: 0.4, Overall: 0.1},
+		Goodness:          GoodnessWeights{SkillCoverage: 0.4, PrerequisiteCompliance: 0.3, ProgramProgress: 0.3},
+		Preferences:       defaultPreferences,
+	},
+}
+
+// validate checks that no weight is negative, that each summed group sums to
+// 1 and that the unexplored interest is at most 1.
+func (p *WeightProfile) validate() error {
+	if p.InterestAlignment.Unexplored < 0 || p.InterestAlignment.Unexplored > 1 {
+		return fmt.Errorf("interest_alignment unexplored must be between 0 and 1")
+	}
+	if p.Preferences.PreferredSubdomain < 0 || p.Preferences.TimeMismatch < 0 {
+		return fmt.Errorf("preferences weights must not be negative")
+	}
+	groups := []struct {
+		name    string
+		weights []float64
+	}{
+		{"fit", []float64{p.Fit.Competency, p.Fit.Interest, p.Fit.Progress, p.Fit.Gateway}},
+		{"competency_match", []float64{p.CompetencyMatch.Prerequisites, p.CompetencyMatch.Grades, p.CompetencyMatch.SkillGap}},
+		{"program_progress", []float64{p.ProgramProgress.Required, p.ProgramProgress.Distribution, p.ProgramProgress.Overall}},
+		{"goodness", []float64{p.Goodness.SkillCoverage, p.Goodness.PrerequisiteCompliance, p.Goodness.ProgramProgress}},
+	}
+	for _, g := range groups {
+		sum := 0.0
+		for _, w := range g.weights {
+			if w < 0 {
+				return fmt.Errorf("%s weights must not be negative", g.name)
+			}
+			sum += w
+		}
+		if math.Abs(sum-1) > 1e-6 {
+			return fmt.Errorf("%s weights sum to %g, not 1", g.name, sum)
+		}
+	}
+	return nil
+}
+
+// WeightProfiles are the profiles by name.
+type WeightProfiles map[string]WeightProfile
+
+// Names lists the profiles alphabetically.
+func (w WeightProfiles) Names() []string {
+	names := make([]string, 0, len(w))
+	for name := range w {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get looks up a profile, reporting ErrUnknownWeightProfile for a missing
+// one.
+func (w WeightProfiles) Get(name string) (WeightProfile, error) {
+	p, ok := w[name]
+	if !ok {
+		return WeightProfile{}, fmt.Errorf("%w %q (have %s)", ErrUnknownWeightProfile, name, strings.Join(w.Names(), ", "))
+	}
+	return p, nil
+}
+
+// loadWeightProfiles returns the built-in profiles together with those in
+// path, a JSON object of profile name to profile:
+//
+//	{"advising": {"fit": {"competency": 0.3, "interest": 0.3, "progress": 0.3, "gateway": 0.1},
+//	              "competency_match": {...}, "interest_alignment": {"unexplored": 0.1},
+//	              "program_progress": {...}, "goodness": {...},
+//	              "preferences": {"preferred_subdomain": 0.1, "time_mismatch": 0.1}}}
+//
+// A missing file gives the built-in profiles alone.
+func loadWeightProfiles(path string) (WeightProfiles, error) {
+	profiles := make(WeightProfiles)
+	for name, p := range builtinWeightProfiles {
+		profiles[name] = p
+	}
+	if path == "" {
+		return profiles, nil
+	}
+
+	var fromFile map[string]WeightProfile
+	if err := readJSONFile(path, &fromFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return profiles, nil
+		}
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	for name, p := range fromFile {
+		if name == "" {
+			return nil, fmt.Errorf("%s: profile names must not be empty", path)
+		}
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("%s: profile %s: %w", path, name, err)
+		}
+		profiles[name] = p
+	}
+	return profiles, nil
+}
+
+var (
+	weightProfilesMu sync.Mutex
+	weightProfiles   = make(map[string]WeightProfiles)
+)
+
+// weightProfilesFor loads the profiles in path once. An invalid file is
+// logged and only the built-in profiles are used; runServer refuses to start
+// on one instead.
+func weightProfilesFor(path string) WeightProfiles {
+	weightProfilesMu.Lock()
+	defer weightProfilesMu.Unlock()
+
+	if p, ok := weightProfiles[path]; ok {
+		return p
+	}
+	p, err := loadWeightProfiles(path)
+	if err != nil {
+		log.Printf("(!) WARNING: Weight profiles not used: %v", err)
+		p, _ = loadWeightProfiles("")
+	}
+	weightProfiles[path] = p
+	return p
+}
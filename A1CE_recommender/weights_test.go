@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestWeightProfileValidate(t *testing.T) {
+	for name, p := range builtinWeightProfiles {
+		if err := p.validate(); err != nil {
+			t.Errorf("built-in %s: %v", name, err)
+		}
+	}
+
+	base := builtinWeightProfiles[DefaultWeightProfile]
+	tests := []struct {
+		name   string
+		change func(p *WeightProfile)
+	}{
+		{"gateway on top of fit summing to 1", func(p *WeightProfile) {
+			p.Fit = FitWeights{Competency: 0.2, Interest: 0.6, Progress: 0.2, Gateway: 0.1}
+		}},
+		{"negative preferred subdomain boost", func(p *WeightProfile) { p.Preferences.PreferredSubdomain = -0.1 }},
+		{"negative time mismatch penalty", func(p *WeightProfile) { p.Preferences.TimeMismatch = -0.1 }},
+		{"unexplored interest above 1", func(p *WeightProfile) { p.InterestAlignment.Unexplored = 1.5 }},
+	}
+	for _, tt := range tests {
+		p := base
+		tt.change(&p)
+		if err := p.validate(); err == nil {
+			t.Errorf("%s: validated", tt.name)
+		}
+	}
+}